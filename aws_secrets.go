@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsSecretsManager talks to AWS Secrets Manager with the credentials of
+// the AWS CLI environment variables: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN and AWS_REGION.
+type awsSecretsManager struct {
+	endpoint     string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+}
+
+func newAwsSecretsManager(options storeOptions) (*awsSecretsManager, error) {
+
+	region := firstNonEmpty(options.awsRegion, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+
+	if region == "" {
+		return nil, errors.New("The AWS region must be given with --aws-region or AWS_REGION")
+	}
+
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+
+	if accessKey == "" || secretKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+
+	endpoint := firstNonEmpty(options.endpoint, os.Getenv("AWS_ENDPOINT_URL"), "https://secretsmanager."+region+".amazonaws.com")
+
+	return &awsSecretsManager{
+		endpoint:     strings.TrimSuffix(endpoint, "/"),
+		region:       region,
+		accessKey:    accessKey,
+		secretKey:    secretKey,
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       &http.Client{Timeout: globals.timeout},
+	}, nil
+}
+
+func (a *awsSecretsManager) writeVars(name string, vars map[string]string) error {
+
+	encoded, err := json.Marshal(vars)
+
+	if err != nil {
+		return err
+	}
+
+	return a.writeValue(name, string(encoded))
+}
+
+// writeValue adds a version to the secret, creating the secret if it
+// doesn't exist yet.
+func (a *awsSecretsManager) writeValue(name string, value string) error {
+
+	_, err := a.call("PutSecretValue", map[string]string{"SecretId": name, "SecretString": value})
+
+	if awsErr, ok := err.(*awsError); ok && awsErr.isType("ResourceNotFoundException") {
+		_, err = a.call("CreateSecret", map[string]string{"Name": name, "SecretString": value})
+	}
+
+	return err
+}
+
+// readVars returns the variables of the current version of the secret,
+// which must be a JSON object like the ones written by writeVars.
+func (a *awsSecretsManager) readVars(name string) (map[string]string, error) {
+
+	data, err := a.call("GetSecretValue", map[string]string{"SecretId": name})
+
+	if awsErr, ok := err.(*awsError); ok && awsErr.isType("ResourceNotFoundException") {
+		return nil, &ccclient.NotFoundError{Kind: "Secret", Name: name}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var secret struct {
+		SecretString string
+	}
+
+	if err := json.Unmarshal(data, &secret); err != nil {
+		return nil, err
+	}
+
+	return decodeSecretVars(name, []byte(secret.SecretString))
+}
+
+func (a *awsSecretsManager) keyName(name string, key string) string {
+	return name + "/" + key
+}
+
+// awsError is an error response of an AWS JSON API.
+type awsError struct {
+	Type       string `json:"__type"`
+	Message    string `json:"message"`
+	StatusCode int    `json:"-"`
+}
+
+func (e *awsError) Error() string {
+	return fmt.Sprintf("AWS responded with status %d: %s %s", e.StatusCode, e.Type, e.Message)
+}
+
+// isType compares the error type without the namespace that some endpoints
+// prefix it with.
+func (e *awsError) isType(name string) bool {
+	return e.Type == name || strings.HasSuffix(e.Type, "#"+name)
+}
+
+func (a *awsSecretsManager) call(action string, input interface{}) ([]byte, error) {
+
+	body, err := json.Marshal(input)
+
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest("POST", a.endpoint+"/", bytes.NewReader(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	request.Header.Set("X-Amz-Target", "secretsmanager."+action)
+	a.sign(request, body, time.Now().UTC())
+
+	verbosef("POST %s %s", a.endpoint, action)
+
+	response, err := a.client.Do(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	data, err := ioutil.ReadAll(response.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode >= 400 {
+		awsErr := &awsError{StatusCode: response.StatusCode}
+		json.Unmarshal(data, awsErr)
+		return nil, awsErr
+	}
+
+	return data, nil
+}
+
+// sign adds the headers of AWS Signature Version 4 to request.
+func (a *awsSecretsManager) sign(request *http.Request, body []byte, now time.Time) {
+
+	const service = "secretsmanager"
+
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := strings.Join([]string{date, a.region, service, "aws4_request"}, "/")
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	if a.sessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", a.sessionToken)
+	}
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(request.Header.Get(name))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders bytes.Buffer
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := request.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		path,
+		canonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSha256([]byte("AWS4"+a.secretKey), date)
+	for _, part := range []string{a.region, service, "aws4_request"} {
+		key = hmacSha256(key, part)
+	}
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		a.accessKey, scope, signedHeaders, hex.EncodeToString(hmacSha256(key, stringToSign))))
+}
+
+func canonicalQuery(query url.Values) string {
+	return strings.Replace(query.Encode(), "+", "%20", -1)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
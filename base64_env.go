@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+)
+
+// base64Encodings are tried in order by decodeBase64, since values are
+// encoded with and without padding, and sometimes URL-safe.
+var base64Encodings = []*base64.Encoding{
+	base64.StdEncoding,
+	base64.RawStdEncoding,
+	base64.URLEncoding,
+	base64.RawURLEncoding,
+}
+
+// decodeBase64 decodes value, ignoring the line breaks of wrapped base64
+// like the output of `base64` without -w 0.
+func decodeBase64(value string) ([]byte, bool) {
+
+	value = strings.Join(strings.Fields(value), "")
+
+	for _, encoding := range base64Encodings {
+		if decoded, err := encoding.DecodeString(value); err == nil {
+			return decoded, true
+		}
+	}
+
+	return nil, false
+}
+
+// writeDecodedKey writes the base64-decoded value of the variable to stdout
+// or the --out file. Binary values like keystores are refused on a
+// terminal, they are meant for files.
+func (p *GetEnvPlugin) writeDecodedKey(env map[string]interface{}) {
+
+	value, err := p.lookupKey(env)
+	fatalIf(err)
+
+	decoded, ok := decodeBase64(value)
+	if !ok {
+		fatalIf(fmt.Errorf("Value of '%s' is not valid base64", p.key))
+	}
+
+	if p.out == "" && outputIsTerminal(p.ui) && !utf8.Valid(decoded) {
+		failUsage("Decoded value of '%s' is binary, use --out FILE to write it to a file", p.key)
+	}
+
+	if p.shouldRedact() && secretKey.MatchString(p.key) {
+		decoded = []byte(mask(string(decoded)) + "\n")
+	}
+
+	out, done := p.openOut()
+	defer done()
+
+	_, err = out.Write(decoded)
+	fatalIf(err)
+
+	if p.out != "" {
+		fmt.Fprintf(p.ui.Out(), "Wrote %d bytes of decoded '%s' to %s\n", len(decoded), p.key, p.out)
+	}
+}
+
+// encodeBase64Vars base64-encodes the variables of `--encode-base64 KEY`.
+// `--encode-base64 KEY=PATH` sets KEY to the encoded content of the file
+// PATH instead, for binaries like keystores that don't fit an env file.
+func encodeBase64Vars(vars map[string]string, keys []string) error {
+
+	for _, key := range keys {
+		if parts := strings.SplitN(key, "=", 2); len(parts) == 2 {
+			content, err := ioutil.ReadFile(parts[1])
+			if err != nil {
+				return err
+			}
+
+			vars[parts[0]] = base64.StdEncoding.EncodeToString(content)
+			continue
+		}
+
+		value, ok := vars[key]
+		if !ok {
+			return usageErrorf("Variable '%s' of --encode-base64 is not in the file", key)
+		}
+
+		vars[key] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+
+	return nil
+}
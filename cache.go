@@ -0,0 +1,220 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// The plugin caches app GUIDs and the app listings of spaces in
+// ~/.cf/plugins/get-env-cache.json for --cache-ttl, so that shell loops
+// running it for app after app don't look up the same apps every time.
+// Entries are keyed by API endpoint and space. --no-cache turns the cache
+// off, `cf get-env --refresh` replaces the entries it would use.
+
+type cacheEntry struct {
+	Value   json.RawMessage `json:"value"`
+	Expires time.Time       `json:"expires"`
+}
+
+type diskCache struct {
+	lock    sync.Mutex
+	loaded  bool
+	entries map[string]cacheEntry
+
+	// refresh ignores the cached entries, which are replaced by fresh ones.
+	refresh bool
+
+	// served are the keys of the GUIDs taken from the cache, by GUID, so
+	// that a stale one can be forgotten.
+	served map[string]string
+}
+
+var cache = &diskCache{served: map[string]string{}}
+
+func cachePath() string {
+	return filepath.Join(cfDir(), "plugins", "get-env-cache.json")
+}
+
+// load reads the cache file once. An unreadable file is an empty cache, it
+// is only an optimization.
+func (c *diskCache) load() {
+
+	if c.loaded {
+		return
+	}
+
+	c.loaded = true
+	c.entries = map[string]cacheEntry{}
+
+	data, err := ioutil.ReadFile(cachePath())
+	if err != nil {
+		return
+	}
+
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		verbosef("Ignoring the cache %s: %s", cachePath(), err)
+		c.entries = map[string]cacheEntry{}
+	}
+}
+
+// get decodes the entry of key into value, unless it is missing or expired.
+func (c *diskCache) get(key string, value interface{}) bool {
+
+	if globals.noCache || c.refresh {
+		return false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.load()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.Expires) {
+		return false
+	}
+
+	return json.Unmarshal(entry.Value, value) == nil
+}
+
+func (c *diskCache) put(key string, value interface{}) {
+
+	if globals.noCache || globals.cacheTTL <= 0 {
+		return
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.load()
+	c.entries[key] = cacheEntry{Value: encoded, Expires: time.Now().Add(globals.cacheTTL)}
+	c.save()
+}
+
+func (c *diskCache) forget(key string) {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.load()
+	delete(c.entries, key)
+	c.save()
+}
+
+// save writes the entries that haven't expired. Another invocation of the
+// plugin may be saving at the same time, so the file is replaced rather
+// than rewritten.
+func (c *diskCache) save() {
+
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.Expires) {
+			delete(c.entries, key)
+		}
+	}
+
+	encoded, err := json.Marshal(c.entries)
+	if err != nil {
+		return
+	}
+
+	path := cachePath()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		verbosef("Failed to save the cache: %s", err)
+		return
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), "get-env-cache")
+	if err != nil {
+		verbosef("Failed to save the cache: %s", err)
+		return
+	}
+
+	_, err = file.Write(encoded)
+	file.Close()
+
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(file.Name())
+		verbosef("Failed to save the cache: %s", err)
+	}
+}
+
+// cacheKey scopes name to the API endpoint and the space with spaceGuid, or
+// the targeted space. Without an endpoint, nothing is cached.
+func cacheKey(cliConnection plugin.CliConnection, spaceGuid string, name string) (string, bool) {
+
+	if globals.noCache {
+		return "", false
+	}
+
+	endpoint, err := cliConnection.ApiEndpoint()
+	if err != nil || endpoint == "" {
+		return "", false
+	}
+
+	if spaceGuid == "" {
+		space, err := cliConnection.GetCurrentSpace()
+		if err != nil || space.Guid == "" {
+			return "", false
+		}
+		spaceGuid = space.Guid
+	}
+
+	return endpoint + " " + spaceGuid + " " + name, true
+}
+
+// cachedAppGuid returns the cached GUID of the app in the targeted space.
+func cachedAppGuid(cliConnection plugin.CliConnection, appName string) (string, bool) {
+
+	key, ok := cacheKey(cliConnection, "", "app:"+appName)
+
+	var guid string
+	if !ok || !cache.get(key, &guid) {
+		return "", false
+	}
+
+	verbosef("Using the cached GUID %s of app %s", guid, appName)
+
+	cache.lock.Lock()
+	cache.served[guid] = key
+	cache.lock.Unlock()
+
+	return guid, true
+}
+
+func cacheAppGuid(cliConnection plugin.CliConnection, appName string, guid string) {
+	if key, ok := cacheKey(cliConnection, "", "app:"+appName); ok {
+		cache.put(key, guid)
+	}
+}
+
+// forgetCachedGuid drops guid from the cache if it came from there, and
+// reports whether it did.
+func forgetCachedGuid(guid string) bool {
+
+	cache.lock.Lock()
+	key, ok := cache.served[guid]
+	delete(cache.served, guid)
+	cache.lock.Unlock()
+
+	if ok {
+		cache.forget(key)
+	}
+
+	return ok
+}
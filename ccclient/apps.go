@@ -0,0 +1,353 @@
+package ccclient
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AppFilters narrows app listings to an org or space. The zero value lists
+// all apps the user can see.
+type AppFilters struct {
+	OrgGuid   string
+	SpaceGuid string
+
+	// LabelSelector is a v3 label selector like "team=payments,env!=dev".
+	// Only the v3 API knows labels, so listings with a selector use it.
+	LabelSelector string
+}
+
+func (f AppFilters) Empty() bool {
+	return f.OrgGuid == "" && f.SpaceGuid == ""
+}
+
+// V2Filters returns the `q` filters of the v2 API.
+func (f AppFilters) V2Filters() []string {
+	switch {
+	case f.SpaceGuid != "":
+		return []string{"space_guid:" + f.SpaceGuid}
+	case f.OrgGuid != "":
+		return []string{"organization_guid:" + f.OrgGuid}
+	}
+	return nil
+}
+
+// V3Query returns the query parameters of the v3 API.
+func (f AppFilters) V3Query() url.Values {
+	query := url.Values{}
+	switch {
+	case f.SpaceGuid != "":
+		query.Set("space_guids", f.SpaceGuid)
+	case f.OrgGuid != "":
+		query.Set("organization_guids", f.OrgGuid)
+	}
+	if f.LabelSelector != "" {
+		query.Set("label_selector", f.LabelSelector)
+	}
+	return query
+}
+
+// V2Path appends the `q` filters to path.
+func V2Path(path string, filters []string) string {
+
+	if len(filters) == 0 {
+		return path
+	}
+
+	query := url.Values{"q": filters}
+
+	return path + "?" + query.Encode()
+}
+
+const appsPathV3 = "v3/apps"
+
+// ListApps follows the v2 pagination of the apps endpoint, falling back to
+// the v3 API on foundations where v2 has been removed or when filtering by
+// labels. When the first page reports the number of pages, the remaining
+// ones are fetched by c.Concurrency workers. On failure, the apps of the
+// pages fetched until then are returned with the error, e.g. to show them
+// when interrupted.
+func (c *Client) ListApps(filters AppFilters) ([]AppModel, error) {
+
+	if filters.LabelSelector != "" {
+		return c.listAppsV3(filters)
+	}
+
+	path := V2Path("v2/apps", filters.V2Filters())
+	c.announce(path)
+
+	response, err := c.request("GET", path, nil)
+
+	if v2Unavailable(response) {
+		return c.listAppsV3(filters)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var first AppsModel
+	if err := json.Unmarshal([]byte(response), &first); err != nil {
+		return nil, err
+	}
+
+	c.deliver(first.Resources)
+
+	if first.TotalPages > 1 && first.NextURL != "" && c.Concurrency > 1 {
+		rest, err := c.fetchPages(first)
+		return append(first.Resources, rest...), err
+	}
+
+	apps := first.Resources
+	nextURL := first.NextURL
+
+	for nextURL != "" {
+		var page AppsModel
+		if err := c.get(nextURL, &page); err != nil {
+			return apps, err
+		}
+
+		c.deliver(page.Resources)
+		apps = append(apps, page.Resources...)
+		nextURL = page.NextURL
+	}
+
+	return apps, nil
+}
+
+// fetchPages requests pages 2 to first.TotalPages and returns their apps in
+// page order, without those of failed pages. A CurlTransport takes the
+// requests of the workers one at a time, see curlLock.
+func (c *Client) fetchPages(first AppsModel) ([]AppModel, error) {
+
+	pages := make([][]AppModel, first.TotalPages+1)
+	errs := make([]error, first.TotalPages+1)
+
+	numbers := make(chan int)
+	var wg sync.WaitGroup
+
+	for i := 0; i < c.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for number := range numbers {
+				var page AppsModel
+				errs[number] = c.get(pageURL(first.NextURL, number), &page)
+				pages[number] = page.Resources
+
+				if errs[number] == nil {
+					c.deliver(page.Resources)
+				}
+			}
+		}()
+	}
+
+	for number := 2; number <= first.TotalPages; number++ {
+		numbers <- number
+	}
+	close(numbers)
+	wg.Wait()
+
+	var apps []AppModel
+	var err error
+
+	for number := 2; number <= first.TotalPages; number++ {
+		if errs[number] != nil && err == nil {
+			err = errs[number]
+		}
+		apps = append(apps, pages[number]...)
+	}
+
+	return apps, err
+}
+
+// pageURL rewrites the page parameter of a v2 next_url, keeping the other
+// query parameters (results-per-page, order-direction) intact.
+func pageURL(nextURL string, number int) string {
+
+	parsed, err := url.Parse(nextURL)
+
+	if err != nil {
+		return nextURL
+	}
+
+	query := parsed.Query()
+	query.Set("page", strconv.Itoa(number))
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
+
+func (c *Client) listAppsV3(filters AppFilters) ([]AppModel, error) {
+
+	var apps []AppModel
+	nextURL := appsPathV3
+
+	if query := filters.V3Query(); len(query) > 0 {
+		nextURL += "?" + query.Encode()
+	}
+
+	c.announce(nextURL)
+
+	for nextURL != "" {
+		var page V3AppsModel
+		if err := c.get(nextURL, &page); err != nil {
+			return apps, err
+		}
+
+		var converted []AppModel
+		for _, app := range page.Resources {
+			converted = append(converted, app.toAppModel())
+		}
+
+		c.deliver(converted)
+		apps = append(apps, converted...)
+
+		nextURL = ""
+		if page.Pagination.Next != nil {
+			nextURL = relativeURL(page.Pagination.Next.Href)
+		}
+	}
+
+	return apps, nil
+}
+
+func (app V3AppModel) toAppModel() AppModel {
+	return AppModel{
+		Metadata: MetadataModel{Guid: app.Guid, UpdatedAt: app.UpdatedAt},
+		Entity: EntityModel{
+			Name:          app.Name,
+			State:         app.State,
+			Buildpack:     strings.Join(app.Lifecycle.Data.Buildpacks, ","),
+			StackName:     app.Lifecycle.Data.Stack,
+			Labels:        app.Metadata.Labels,
+			LifecycleType: app.Lifecycle.Type,
+			SpaceGuid:     app.Relationships.Space.Data.Guid,
+		},
+	}
+}
+
+// Lifecycle returns "docker" for apps running a Docker image and
+// "buildpack" for the others.
+func (entity EntityModel) Lifecycle() string {
+	if entity.DockerImage != "" || entity.LifecycleType == "docker" {
+		return "docker"
+	}
+	return "buildpack"
+}
+
+// relativeURL strips scheme and host from v3 links, since `cf curl` expects
+// a path relative to the targeted API endpoint.
+func relativeURL(href string) string {
+
+	parsed, err := url.Parse(href)
+
+	if err != nil || parsed.Host == "" {
+		return href
+	}
+
+	return strings.TrimPrefix(parsed.RequestURI(), "/")
+}
+
+// InstanceStates returns the state of every instance of the app, e.g.
+// RUNNING, CRASHED or DOWN. Stopped apps have no instances.
+func (c *Client) InstanceStates(guid string) ([]string, error) {
+
+	stats, err := c.instanceStats(guid)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]string, 0, len(stats))
+	for _, instance := range stats {
+		states = append(states, instance.State)
+	}
+
+	return states, nil
+}
+
+// InstanceUptimes returns for how long every running instance of the app
+// has been running.
+func (c *Client) InstanceUptimes(guid string) ([]time.Duration, error) {
+
+	stats, err := c.instanceStats(guid)
+	if err != nil {
+		return nil, err
+	}
+
+	var uptimes []time.Duration
+	for _, instance := range stats {
+		if instance.State == "RUNNING" {
+			uptimes = append(uptimes, time.Duration(instance.Uptime+instance.Stats.Uptime)*time.Second)
+		}
+	}
+
+	return uptimes, nil
+}
+
+func (c *Client) instanceStats(guid string) ([]InstanceStatsModel, error) {
+
+	response, err := c.request("GET", "/v2/apps/"+guid+"/stats", nil)
+
+	if v2Unavailable(response) {
+		return c.v3InstanceStats(guid)
+	}
+
+	if apiErr, ok := err.(*APIError); ok && apiErr.ErrorCode == "CF-AppStoppedStatsError" {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var stats map[string]InstanceStatsModel
+	if err := json.Unmarshal([]byte(response), &stats); err != nil {
+		return nil, err
+	}
+
+	instances := make([]InstanceStatsModel, 0, len(stats))
+	for _, instance := range stats {
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+func (c *Client) v3InstanceStats(guid string) ([]InstanceStatsModel, error) {
+
+	var stats V3ProcessStatsModel
+	if err := c.get("/v3/apps/"+guid+"/processes/web/stats", &stats); err != nil {
+		return nil, err
+	}
+
+	return stats.Resources, nil
+}
+
+// GetApp fetches a single app, falling back to the v3 API on foundations
+// where v2 has been removed.
+func (c *Client) GetApp(guid string) (AppModel, error) {
+
+	response, err := c.request("GET", "/v2/apps/"+guid, nil)
+
+	if v2Unavailable(response) {
+		var app V3AppModel
+		if err := c.get("/v3/apps/"+guid, &app); err != nil {
+			return AppModel{}, err
+		}
+		return app.toAppModel(), nil
+	}
+
+	if err != nil {
+		return AppModel{}, err
+	}
+
+	var app AppModel
+	err = json.Unmarshal([]byte(response), &app)
+
+	return app, err
+}
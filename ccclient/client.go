@@ -0,0 +1,170 @@
+// Package ccclient wraps the Cloud Controller requests of the plugin in typed
+// methods on top of a plugin.CliConnection, so other plugins and tools can
+// reuse them.
+package ccclient
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+type Client struct {
+	transport Transport
+
+	// Concurrency is the number of pages fetched at the same time by ListApps.
+	// Only the direct HTTP transport runs them at the same time; through
+	// `cf curl` they still go one after the other.
+	Concurrency int
+
+	// OnList, if set, is called with the path of every listing before it
+	// is fetched.
+	OnList func(path string)
+
+	// OnPage, if set, is called with the apps of every page of ListApps as
+	// soon as it arrives. Pages fetched in parallel may arrive out of
+	// order, but the calls never overlap.
+	OnPage func(apps []AppModel)
+
+	pageLock sync.Mutex
+}
+
+// New returns a client using the transport chosen by NewTransport.
+func New(connection plugin.CliConnection) *Client {
+	return NewWithTransport(NewTransport(connection))
+}
+
+func NewWithTransport(transport Transport) *Client {
+	return &Client{transport: transport, Concurrency: 1}
+}
+
+// Raw requests path with GET and returns the response body, which may be
+// an error response of the Cloud Controller.
+func (c *Client) Raw(path string) (string, error) {
+
+	response, err := c.transport.Do("GET", path, nil)
+
+	if statusErr, ok := err.(*StatusError); ok && !IsRetryable(err) {
+		return statusErr.Body, nil
+	}
+
+	return response, err
+}
+
+// request sends a request and turns error responses of the Cloud Controller
+// into an *APIError. The response is returned along with it, so callers can
+// inspect errors they know how to handle.
+func (c *Client) request(method string, path string, body []byte) (string, error) {
+
+	response, err := c.transport.Do(method, path, body)
+
+	status := 0
+	if statusErr, ok := err.(*StatusError); ok && !IsRetryable(err) {
+		response, status, err = statusErr.Body, statusErr.StatusCode, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	apiErr := responseError(response)
+
+	if apiErr == nil && status == 0 {
+		return response, nil
+	}
+
+	if apiErr == nil {
+		apiErr = &APIError{Description: fmt.Sprintf("Cloud Controller responded with status %d", status), Body: response}
+	}
+
+	apiErr.Method = method
+	apiErr.Path = path
+	apiErr.StatusCode = status
+
+	return response, apiErr
+}
+
+// SendJson sends body as JSON with the given method and reports error
+// responses of the Cloud Controller.
+func (c *Client) SendJson(method string, path string, body interface{}) (string, error) {
+
+	encoded, err := json.Marshal(body)
+
+	if err != nil {
+		return "", err
+	}
+
+	return c.request(method, path, encoded)
+}
+
+// get fetches path and decodes the response into result.
+func (c *Client) get(path string, result interface{}) error {
+
+	response, err := c.request("GET", path, nil)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(response), result)
+}
+
+func (c *Client) deliver(apps []AppModel) {
+	if c.OnPage != nil {
+		c.pageLock.Lock()
+		defer c.pageLock.Unlock()
+
+		c.OnPage(apps)
+	}
+}
+
+func (c *Client) announce(path string) {
+	if c.OnList != nil {
+		c.OnList(path)
+	}
+}
+
+// ResponseError extracts the error from a v2 or v3 error response, if it is one.
+func ResponseError(response string) error {
+
+	if err := responseError(response); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func responseError(response string) *APIError {
+
+	var v2 V2ErrorModel
+	if json.Unmarshal([]byte(response), &v2) == nil && v2.ErrorCode != "" {
+		return &APIError{Code: v2.Code, ErrorCode: v2.ErrorCode, Description: v2.Description, Body: response}
+	}
+
+	var v3 V3ErrorsModel
+	if json.Unmarshal([]byte(response), &v3) == nil && len(v3.Errors) > 0 {
+		first := v3.Errors[0]
+		return &APIError{Code: first.Code, ErrorCode: first.Title, Description: first.Detail, Body: response}
+	}
+
+	return nil
+}
+
+// v2Unavailable reports whether response is the answer of a foundation that
+// has removed the v2 API.
+func v2Unavailable(response string) bool {
+
+	var envelope V3ErrorsModel
+	if json.Unmarshal([]byte(response), &envelope) != nil {
+		return false
+	}
+
+	for _, err := range envelope.Errors {
+		if err.Title == "CF-NotFound" || err.Title == "CF-V2Disabled" {
+			return true
+		}
+	}
+
+	return false
+}
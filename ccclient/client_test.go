@@ -0,0 +1,105 @@
+package ccclient_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+
+	var (
+		connection *pluginfakes.FakeCliConnection
+		client     *Client
+		responses  map[string]string
+	)
+
+	BeforeEach(func() {
+		connection = new(pluginfakes.FakeCliConnection)
+		client = New(connection)
+		responses = map[string]string{}
+
+		connection.CliCommandWithoutTerminalOutputStub = func(args ...string) ([]string, error) {
+			return []string{responses[args[1]]}, nil
+		}
+	})
+
+	Describe("ListApps", func() {
+		It("follows next_url", func() {
+			responses["v2/apps"] = `{"next_url":"/v2/apps?page=2","resources":[{"entity":{"name":"app1"}}]}`
+			responses["/v2/apps?page=2"] = `{"resources":[{"entity":{"name":"app2"}}]}`
+
+			apps, err := client.ListApps(AppFilters{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(apps).To(HaveLen(2))
+			Expect(apps[1].Entity.Name).To(Equal("app2"))
+		})
+
+		It("filters by space", func() {
+			responses["v2/apps?q=space_guid%3Aspace-guid"] = `{"resources":[{"entity":{"name":"app1"}}]}`
+
+			apps, err := client.ListApps(AppFilters{SpaceGuid: "space-guid"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(apps).To(HaveLen(1))
+		})
+
+		It("lists apps through the v3 API with a label selector", func() {
+			responses["v3/apps?label_selector=team%3Dpayments%2Cenv%21%3Dsandbox&space_guids=space-guid"] = `{"resources":[{"name":"app1","metadata":{"labels":{"team":"payments"}}}]}`
+
+			apps, err := client.ListApps(AppFilters{SpaceGuid: "space-guid", LabelSelector: "team=payments,env!=sandbox"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(apps).To(HaveLen(1))
+			Expect(apps[0].Entity.Labels).To(Equal(map[string]string{"team": "payments"}))
+		})
+
+		It("returns errors of the Cloud Controller", func() {
+			responses["v2/apps"] = `{"code":10002,"description":"Authentication error","error_code":"CF-NotAuthenticated"}`
+
+			_, err := client.ListApps(AppFilters{})
+			Expect(err).To(MatchError("CF-NotAuthenticated: Authentication error"))
+		})
+
+		It("returns the apps of the pages before a failing one", func() {
+			responses["v2/apps"] = `{"next_url":"/v2/apps?page=2","resources":[{"entity":{"name":"app1"}}]}`
+			responses["/v2/apps?page=2"] = `{"code":10002,"description":"Authentication error","error_code":"CF-NotAuthenticated"}`
+
+			apps, err := client.ListApps(AppFilters{})
+			Expect(err).To(HaveOccurred())
+			Expect(apps).To(HaveLen(1))
+			Expect(apps[0].Entity.Name).To(Equal("app1"))
+		})
+
+		It("returns errors of the connection", func() {
+			connection.CliCommandWithoutTerminalOutputStub = nil
+			connection.CliCommandWithoutTerminalOutputReturns(nil, errors.New("connection refused"))
+
+			_, err := client.ListApps(AppFilters{})
+			Expect(err).To(MatchError("connection refused"))
+		})
+	})
+
+	Describe("GetAppEnv", func() {
+		It("decodes the env document", func() {
+			responses["/v2/apps/1234/env"] = `{"environment_json":{"KEY":"value"}}`
+
+			env, err := client.GetAppEnv("1234")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(HaveKeyWithValue("environment_json", map[string]interface{}{"KEY": "value"}))
+		})
+	})
+
+	Describe("SetAppEnv", func() {
+		It("replaces environment_json in a single PUT", func() {
+			err := client.SetAppEnv("1234", map[string]interface{}{"KEY": "value"})
+			Expect(err).NotTo(HaveOccurred())
+
+			args := connection.CliCommandWithoutTerminalOutputArgsForCall(0)
+			Expect(args[:5]).To(Equal([]string{"curl", "/v2/apps/1234", "-X", "PUT", "-d"}))
+			Expect(args[5]).To(MatchJSON(`{"environment_json":{"KEY":"value"}}`))
+		})
+	})
+})
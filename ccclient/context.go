@@ -0,0 +1,56 @@
+package ccclient
+
+import (
+	"context"
+	"net/url"
+)
+
+// WithContext cancels the requests of transport once ctx is done. Requests of
+// the direct HTTP transport are aborted, the others are given up on like
+// WithTimeout does.
+func WithContext(transport Transport, ctx context.Context) Transport {
+
+	if direct, ok := transport.(*HTTPTransport); ok {
+		direct.Context = ctx
+		return direct
+	}
+
+	return &contextTransport{transport: transport, ctx: ctx}
+}
+
+type contextTransport struct {
+	transport Transport
+	ctx       context.Context
+}
+
+func (t *contextTransport) Do(method string, path string, body []byte) (string, error) {
+
+	if err := t.ctx.Err(); err != nil {
+		return "", err
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		response, err := t.transport.Do(method, path, body)
+		done <- result{response, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.response, r.err
+	case <-t.ctx.Done():
+		return "", t.ctx.Err()
+	}
+}
+
+// IsCanceled reports whether a request failed because its context was
+// cancelled.
+func IsCanceled(err error) bool {
+
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+
+	return err == context.Canceled
+}
@@ -0,0 +1,68 @@
+package ccclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type blockingTransport struct {
+	release chan struct{}
+}
+
+func (t *blockingTransport) Do(method string, path string, body []byte) (string, error) {
+	<-t.release
+	return "{}", nil
+}
+
+var _ = Describe("WithContext", func() {
+
+	It("gives up on requests of transports that can't be cancelled", func() {
+		inner := &blockingTransport{release: make(chan struct{})}
+		defer close(inner.release)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		transport := WithContext(inner, ctx)
+
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		_, err := transport.Do("GET", "/v2/apps", nil)
+		Expect(IsCanceled(err)).To(BeTrue())
+	})
+
+	It("aborts the requests of the direct HTTP transport", func() {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer server.Close()
+		defer close(release)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		transport := WithContext(NewTokenTransport(server.URL, "my-token", false), ctx)
+
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		start := time.Now()
+		_, err := transport.Do("GET", "/v2/apps", nil)
+		Expect(IsCanceled(err)).To(BeTrue())
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+	})
+
+	It("doesn't send requests once cancelled", func() {
+		inner := &failingTransport{}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := WithContext(inner, ctx).Do("GET", "/v2/apps", nil)
+		Expect(IsCanceled(err)).To(BeTrue())
+		Expect(inner.calls).To(Equal(0))
+	})
+})
@@ -0,0 +1,131 @@
+package ccclient
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type rootModel struct {
+	Links map[string]*struct {
+		Href string `json:"href"`
+	} `json:"links"`
+}
+
+// CredHubEndpoint returns the CredHub URL linked from the root of the Cloud
+// Controller API.
+func (c *Client) CredHubEndpoint() (string, error) {
+
+	var root rootModel
+
+	if err := c.get("/", &root); err != nil {
+		return "", err
+	}
+
+	if link := root.Links["credhub"]; link != nil && link.Href != "" {
+		return strings.TrimSuffix(link.Href, "/"), nil
+	}
+
+	return "", errors.New("The foundation does not link a CredHub from its API")
+}
+
+// CredHub reads credentials with the access token of the CLI. Whether the
+// user may read a credential is up to the permissions set in CredHub.
+type CredHub struct {
+	Endpoint   string
+	Connection plugin.CliConnection
+	Client     *http.Client
+}
+
+func NewCredHub(connection plugin.CliConnection, endpoint string, timeout time.Duration) *CredHub {
+
+	skipSSLValidation, _ := connection.IsSSLDisabled()
+
+	return &CredHub{
+		Endpoint:   endpoint,
+		Connection: connection,
+		Client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: skipSSLValidation},
+			},
+		},
+	}
+}
+
+type credHubDataModel struct {
+	Data []struct {
+		Type  string      `json:"type"`
+		Value interface{} `json:"value"`
+	} `json:"data"`
+	Error string `json:"error"`
+}
+
+// Get returns the current value of the credential name: a string for
+// values and passwords, an object for the other types.
+func (c *CredHub) Get(name string) (interface{}, error) {
+
+	path := "/api/v1/data?current=true&name=" + url.QueryEscape(name)
+
+	request, err := http.NewRequest("GET", c.Endpoint+path, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := c.Connection.AccessToken()
+
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Authorization", token)
+	request.Header.Set("Accept", "application/json")
+
+	response, err := c.Client.Do(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var data credHubDataModel
+	decodeErr := json.Unmarshal(body, &data)
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, &NotFoundError{Kind: "Credential", Name: name}
+	}
+
+	if response.StatusCode >= 400 {
+		description := data.Error
+		if description == "" {
+			description = fmt.Sprintf("CredHub responded with status %d", response.StatusCode)
+		}
+		return nil, &APIError{Method: "GET", Path: c.Endpoint + path, StatusCode: response.StatusCode, Description: description, Body: string(body)}
+	}
+
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	if len(data.Data) == 0 {
+		return nil, &NotFoundError{Kind: "Credential", Name: name}
+	}
+
+	return data.Data[0].Value, nil
+}
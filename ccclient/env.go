@@ -0,0 +1,28 @@
+package ccclient
+
+import "encoding/json"
+
+// GetAppEnv returns the env document of the app with its sections
+// environment_json, staging_env_json, running_env_json and system_env_json.
+func (c *Client) GetAppEnv(guid string) (map[string]interface{}, error) {
+
+	response, err := c.request("GET", "/v2/apps/"+guid+"/env", nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]interface{})
+	json.Unmarshal([]byte(response), &env)
+
+	return env, nil
+}
+
+// SetAppEnv replaces the user-provided environment of the app with vars in a
+// single request.
+func (c *Client) SetAppEnv(guid string, vars map[string]interface{}) error {
+
+	_, err := c.SendJson("PUT", "/v2/apps/"+guid, map[string]interface{}{"environment_json": vars})
+
+	return err
+}
@@ -0,0 +1,61 @@
+package ccclient
+
+import "encoding/json"
+
+// EnvGroups are the names of the environment variable groups, which apply
+// to all apps of the foundation.
+var EnvGroups = []string{"running", "staging"}
+
+// GetEnvGroup returns the variables of the running or staging environment
+// variable group.
+func (c *Client) GetEnvGroup(group string) (map[string]interface{}, error) {
+
+	response, err := c.request("GET", "/v2/config/environment_variable_groups/"+group, nil)
+
+	if v2Unavailable(response) {
+		var v3 V3EnvGroupModel
+		err := c.get("/v3/environment_variable_groups/"+group, &v3)
+		return v3.Var, err
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	vars := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(response), &vars); err != nil {
+		return nil, err
+	}
+
+	return vars, nil
+}
+
+// SetEnvGroup replaces the variables of the running or staging environment
+// variable group with vars.
+func (c *Client) SetEnvGroup(group string, vars map[string]interface{}) error {
+
+	response, err := c.SendJson("PUT", "/v2/config/environment_variable_groups/"+group, vars)
+
+	if !v2Unavailable(response) {
+		return err
+	}
+
+	// The v3 endpoint merges, removed variables have to be set to null.
+	current, err := c.GetEnvGroup(group)
+
+	if err != nil {
+		return err
+	}
+
+	patch := map[string]interface{}{}
+	for key := range current {
+		patch[key] = nil
+	}
+	for key, value := range vars {
+		patch[key] = value
+	}
+
+	_, err = c.SendJson("PATCH", "/v3/environment_variable_groups/"+group, V3EnvGroupModel{Var: patch})
+
+	return err
+}
@@ -0,0 +1,49 @@
+package ccclient
+
+import "fmt"
+
+// APIError is an error response of the Cloud Controller.
+type APIError struct {
+	Method string
+	Path   string
+
+	// StatusCode is the HTTP status of the response. It is 0 when the
+	// request went through cf curl, which doesn't report it.
+	StatusCode int
+
+	// Code and ErrorCode are the numeric and symbolic error code of the
+	// Cloud Controller, e.g. 100004 and CF-AppNotFound.
+	Code        int
+	ErrorCode   string
+	Description string
+
+	// Body is the unparsed response.
+	Body string
+}
+
+func (e *APIError) Error() string {
+
+	if e.ErrorCode == "" {
+		return e.Description
+	}
+
+	return fmt.Sprintf("%s: %s", e.ErrorCode, e.Description)
+}
+
+// Endpoint returns the method and path of the failed request.
+func (e *APIError) Endpoint() string {
+	if e.Path == "" {
+		return ""
+	}
+	return e.Method + " " + e.Path
+}
+
+// NotFoundError is returned when a resource looked up by name doesn't exist.
+type NotFoundError struct {
+	Kind string
+	Name string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s '%s' not found", e.Kind, e.Name)
+}
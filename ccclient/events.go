@@ -0,0 +1,102 @@
+package ccclient
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// appEventTypes are the events that explain why an app stopped.
+var appEventTypes = []string{"app.crash", "audit.app.stop", "audit.app.update"}
+
+// EventModel is the latest crash, stop or update of an app.
+type EventModel struct {
+	Type      string `json:"type"`
+	Actor     string `json:"actor"`
+	Reason    string `json:"reason,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// LatestAppEvent returns the latest crash, stop or update of the app, or nil
+// if there is none, falling back to the v3 audit events on foundations where
+// v2 has been removed.
+func (c *Client) LatestAppEvent(guid string) (*EventModel, error) {
+
+	path := V2Path("/v2/events", []string{"actee:" + guid, "type IN " + strings.Join(appEventTypes, ",")}) +
+		"&order-direction=desc&results-per-page=1"
+
+	response, err := c.request("GET", path, nil)
+
+	if v2Unavailable(response) {
+		return c.latestAppEventV3(guid)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var events V2EventsModel
+	if err := json.Unmarshal([]byte(response), &events); err != nil {
+		return nil, err
+	}
+
+	if len(events.Resources) == 0 {
+		return nil, nil
+	}
+
+	event := events.Resources[0].Entity
+
+	return &EventModel{
+		Type:      event.Type,
+		Actor:     firstNonEmpty(event.ActorName, event.Actor),
+		Reason:    eventReason(event.Metadata),
+		Timestamp: event.Timestamp,
+	}, nil
+}
+
+func (c *Client) latestAppEventV3(guid string) (*EventModel, error) {
+
+	types := "audit.app.process.crash,audit.app.stop,audit.app.update"
+
+	var events V3AuditEventsModel
+	if err := c.get("/v3/audit_events?target_guids="+guid+"&types="+types+"&order_by=-created_at&per_page=1", &events); err != nil {
+		return nil, err
+	}
+
+	if len(events.Resources) == 0 {
+		return nil, nil
+	}
+
+	event := events.Resources[0]
+
+	return &EventModel{
+		Type:      event.Type,
+		Actor:     firstNonEmpty(event.Actor.Name, event.Actor.Guid),
+		Reason:    eventReason(event.Data),
+		Timestamp: event.CreatedAt,
+	}, nil
+}
+
+// eventReason describes an event by its metadata: the exit description of
+// a crash or the state an update requested.
+func eventReason(metadata EventMetadataModel) string {
+
+	switch {
+	case metadata.ExitDescription != "":
+		return metadata.ExitDescription
+	case metadata.Reason != "":
+		return metadata.Reason
+	case metadata.Request.State != "":
+		return "state " + metadata.Request.State
+	}
+
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+package ccclient
+
+import (
+	"time"
+)
+
+// LogTransport logs every request of Transport with the outcome and how long
+// it took. With Bodies, the request and response bodies are logged too.
+type LogTransport struct {
+	Transport Transport
+	Log       func(format string, args ...interface{})
+	Bodies    bool
+}
+
+func (t *LogTransport) Do(method string, path string, body []byte) (string, error) {
+
+	if t.Bodies && body != nil {
+		t.Log("%s %s request body: %s", method, path, body)
+	}
+
+	start := time.Now()
+	response, err := t.Transport.Do(method, path, body)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	switch typed := err.(type) {
+	case nil:
+		t.Log("%s %s: ok in %s", method, path, elapsed)
+	case *StatusError:
+		t.Log("%s %s: status %d in %s", method, path, typed.StatusCode, elapsed)
+	default:
+		t.Log("%s %s: %s after %s", method, path, err, elapsed)
+	}
+
+	if t.Bodies && response != "" {
+		t.Log("%s %s response body: %s", method, path, response)
+	}
+
+	return response, err
+}
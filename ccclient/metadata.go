@@ -0,0 +1,28 @@
+package ccclient
+
+// GetAppAnnotations returns the v3 metadata annotations of the app.
+func (c *Client) GetAppAnnotations(guid string) (map[string]string, error) {
+
+	var app V3MetadataResourceModel
+
+	if err := c.get("/v3/apps/"+guid, &app); err != nil {
+		return nil, err
+	}
+
+	if app.Metadata.Annotations == nil {
+		return map[string]string{}, nil
+	}
+
+	return app.Metadata.Annotations, nil
+}
+
+// SetAppAnnotation sets a single annotation of the app. The v3 API merges
+// annotations, so the others are left alone.
+func (c *Client) SetAppAnnotation(guid string, key string, value string) error {
+
+	_, err := c.SendJson("PATCH", "/v3/apps/"+guid, V3MetadataResourceModel{
+		Metadata: V3MetadataModel{Annotations: map[string]string{key: value}},
+	})
+
+	return err
+}
@@ -0,0 +1,296 @@
+package ccclient
+
+type AppsModel struct {
+	TotalPages int        `json:"total_pages,omitempty"`
+	NextURL    string     `json:"next_url,omitempty"`
+	Resources  []AppModel `json:"resources"`
+}
+
+type AppModel struct {
+	Metadata MetadataModel `json:"metadata"`
+	Entity   EntityModel   `json:"entity"`
+}
+
+type MetadataModel struct {
+	Guid      string `json:"guid"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type EntityModel struct {
+	Name              string `json:"name"`
+	State             string `json:"state"`
+	Instances         int    `json:"instances"`
+	Memory            int    `json:"memory"`
+	DiskQuota         int    `json:"disk_quota"`
+	Buildpack         string `json:"buildpack,omitempty"`
+	DetectedBuildpack string `json:"detected_buildpack,omitempty"`
+	StackGuid         string `json:"stack_guid,omitempty"`
+	RoutesURL         string `json:"routes_url,omitempty"`
+	DockerImage       string `json:"docker_image,omitempty"`
+	SpaceGuid         string `json:"space_guid,omitempty"`
+	PackageUpdatedAt  string `json:"package_updated_at,omitempty"`
+
+	// LifecycleType is only known for apps listed through the v3 API, which
+	// doesn't report the image of Docker apps.
+	LifecycleType string `json:"-"`
+
+	// StackName is only known for apps listed through the v3 API, which
+	// reports stacks by name.
+	StackName string `json:"-"`
+
+	// Labels are only known for apps listed through the v3 API.
+	Labels map[string]string `json:"-"`
+
+	// SpaceName and OrgName are only known after looking up the space
+	// with Client.ListSpaces.
+	SpaceName string `json:"-"`
+	OrgName   string `json:"-"`
+
+	// URLs are only known after fetching them with Client.AppURLs.
+	URLs []string `json:"-"`
+
+	// Processes are only known after fetching them with Client.AppProcesses.
+	Processes []ProcessModel `json:"-"`
+
+	// LastEvent is only known after fetching it with Client.LatestAppEvent.
+	LastEvent *EventModel `json:"-"`
+
+	// Foundation is set by callers listing the apps of several
+	// foundations, to tell them apart.
+	Foundation string `json:"-"`
+}
+
+type NamedResourcesModel struct {
+	NextURL   string               `json:"next_url"`
+	Resources []NamedResourceModel `json:"resources"`
+}
+
+type NamedResourceModel struct {
+	Metadata MetadataModel `json:"metadata"`
+	Entity   struct {
+		Name string `json:"name"`
+	} `json:"entity"`
+}
+
+type RoutesModel struct {
+	NextURL   string       `json:"next_url"`
+	Resources []RouteModel `json:"resources"`
+}
+
+// RouteModel is a v2 route listed with inline-relations-depth=1, which
+// includes its domain.
+type RouteModel struct {
+	Metadata MetadataModel `json:"metadata"`
+	Entity   struct {
+		Host   string             `json:"host"`
+		Path   string             `json:"path"`
+		Port   int                `json:"port"`
+		Domain NamedResourceModel `json:"domain"`
+	} `json:"entity"`
+}
+
+type V3RoutesModel struct {
+	Pagination V3PaginationModel `json:"pagination"`
+	Resources  []V3RouteModel    `json:"resources"`
+}
+
+type V3RouteModel struct {
+	URL string `json:"url"`
+}
+
+type V3EnvGroupModel struct {
+	Var map[string]interface{} `json:"var"`
+}
+
+// InstanceStatsModel is an instance of the v2 stats, which nest the uptime
+// in stats, or of the v3 process stats, which don't.
+type InstanceStatsModel struct {
+	State  string `json:"state"`
+	Uptime int    `json:"uptime"`
+	Stats  struct {
+		Uptime int `json:"uptime"`
+	} `json:"stats"`
+}
+
+type V2ErrorModel struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+	ErrorCode   string `json:"error_code"`
+}
+
+type V3AppsModel struct {
+	Pagination V3PaginationModel `json:"pagination"`
+	Resources  []V3AppModel      `json:"resources"`
+}
+
+type V3PaginationModel struct {
+	TotalResults int          `json:"total_results"`
+	TotalPages   int          `json:"total_pages"`
+	Next         *V3LinkModel `json:"next"`
+}
+
+type V3LinkModel struct {
+	Href string `json:"href"`
+}
+
+type V3AppModel struct {
+	Guid      string           `json:"guid"`
+	Name      string           `json:"name"`
+	State     string           `json:"state"`
+	UpdatedAt string           `json:"updated_at"`
+	Lifecycle V3LifecycleModel `json:"lifecycle"`
+	Metadata  V3MetadataModel  `json:"metadata"`
+
+	Relationships struct {
+		Space V3RelationshipModel `json:"space"`
+	} `json:"relationships"`
+}
+
+type V3RelationshipModel struct {
+	Data struct {
+		Guid string `json:"guid"`
+	} `json:"data"`
+}
+
+// V2SpacesModel is a v2 space listing with inline-relations-depth=1, which
+// includes the org of every space.
+type V2SpacesModel struct {
+	NextURL   string `json:"next_url"`
+	Resources []struct {
+		Metadata MetadataModel `json:"metadata"`
+		Entity   struct {
+			Name         string             `json:"name"`
+			Organization NamedResourceModel `json:"organization"`
+		} `json:"entity"`
+	} `json:"resources"`
+}
+
+// V2OrganizationModel is an org fetched with inline-relations-depth=1,
+// which includes its quota definition.
+type V2OrganizationModel struct {
+	Metadata MetadataModel `json:"metadata"`
+	Entity   struct {
+		Name            string `json:"name"`
+		QuotaDefinition struct {
+			Entity struct {
+				Name             string `json:"name"`
+				MemoryLimit      int    `json:"memory_limit"`
+				AppInstanceLimit int    `json:"app_instance_limit"`
+			} `json:"entity"`
+		} `json:"quota_definition"`
+	} `json:"entity"`
+}
+
+type V2OrganizationSummaryModel struct {
+	Spaces []struct {
+		Name         string `json:"name"`
+		MemDevTotal  int    `json:"mem_dev_total"`
+		MemProdTotal int    `json:"mem_prod_total"`
+	} `json:"spaces"`
+}
+
+type V3SpacesModel struct {
+	Pagination V3PaginationModel `json:"pagination"`
+	Resources  []struct {
+		Guid          string `json:"guid"`
+		Name          string `json:"name"`
+		Relationships struct {
+			Organization V3RelationshipModel `json:"organization"`
+		} `json:"relationships"`
+	} `json:"resources"`
+	Included struct {
+		Organizations []struct {
+			Guid string `json:"guid"`
+			Name string `json:"name"`
+		} `json:"organizations"`
+	} `json:"included"`
+}
+
+type V3LifecycleModel struct {
+	Type string `json:"type"`
+	Data struct {
+		Buildpacks []string `json:"buildpacks"`
+		Stack      string   `json:"stack"`
+	} `json:"data"`
+}
+
+// V3MetadataResourceModel is the part of a v3 resource holding its labels
+// and annotations.
+type V3MetadataResourceModel struct {
+	Metadata V3MetadataModel `json:"metadata"`
+}
+
+type V3MetadataModel struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type V2EventsModel struct {
+	Resources []struct {
+		Entity struct {
+			Type      string             `json:"type"`
+			Actor     string             `json:"actor"`
+			ActorName string             `json:"actor_name"`
+			Timestamp string             `json:"timestamp"`
+			Metadata  EventMetadataModel `json:"metadata"`
+		} `json:"entity"`
+	} `json:"resources"`
+}
+
+type V3AuditEventsModel struct {
+	Resources []struct {
+		Type      string `json:"type"`
+		CreatedAt string `json:"created_at"`
+		Actor     struct {
+			Guid string `json:"guid"`
+			Name string `json:"name"`
+		} `json:"actor"`
+		Data EventMetadataModel `json:"data"`
+	} `json:"resources"`
+}
+
+// EventMetadataModel is the part of the metadata of app events that tells
+// why an app crashed or stopped.
+type EventMetadataModel struct {
+	ExitDescription string `json:"exit_description"`
+	Reason          string `json:"reason"`
+	Request         struct {
+		State string `json:"state"`
+	} `json:"request"`
+}
+
+// ProcessModel is a process of an app, like web or worker. Apps pushed with
+// a Procfile can run several, each with its own instances and health check.
+type ProcessModel struct {
+	Type        string `json:"type"`
+	Instances   int    `json:"instances"`
+	MemoryInMb  int    `json:"memory_in_mb"`
+	DiskInMb    int    `json:"disk_in_mb"`
+	HealthCheck struct {
+		Type string `json:"type"`
+		Data struct {
+			Endpoint string `json:"endpoint"`
+		} `json:"data"`
+	} `json:"health_check"`
+}
+
+type V3ProcessesModel struct {
+	Pagination V3PaginationModel `json:"pagination"`
+	Resources  []ProcessModel    `json:"resources"`
+}
+
+type V3ProcessStatsModel struct {
+	Resources []InstanceStatsModel `json:"resources"`
+}
+
+// V3ErrorsModel is the error envelope of the v3 API. Foundations that have
+// removed the v2 API answer v2 requests with it.
+type V3ErrorsModel struct {
+	Errors []V3ErrorModel `json:"errors"`
+}
+
+type V3ErrorModel struct {
+	Code   int    `json:"code"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
@@ -0,0 +1,25 @@
+package ccclient
+
+// AppProcesses returns the processes of the app. There is no v2 equivalent,
+// v2 only knows the web process.
+func (c *Client) AppProcesses(guid string) ([]ProcessModel, error) {
+
+	var processes []ProcessModel
+	nextURL := "/v3/apps/" + guid + "/processes"
+
+	for nextURL != "" {
+		var page V3ProcessesModel
+		if err := c.get(nextURL, &page); err != nil {
+			return nil, err
+		}
+
+		processes = append(processes, page.Resources...)
+
+		nextURL = ""
+		if page.Pagination.Next != nil {
+			nextURL = relativeURL(page.Pagination.Next.Href)
+		}
+	}
+
+	return processes, nil
+}
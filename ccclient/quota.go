@@ -0,0 +1,39 @@
+package ccclient
+
+// OrgQuotaModel is the memory reserved by the started apps of an org and
+// the limits of its quota. Limits of -1 are unlimited.
+type OrgQuotaModel struct {
+	OrgName       string `json:"org"`
+	QuotaName     string `json:"quota"`
+	MemoryUsed    int    `json:"memory_used"`
+	MemoryLimit   int    `json:"memory_limit"`
+	InstanceLimit int    `json:"app_instance_limit"`
+}
+
+// OrgQuota fetches the memory usage of the org from its summary and the
+// limits from its quota definition.
+func (c *Client) OrgQuota(orgGuid string) (OrgQuotaModel, error) {
+
+	var org V2OrganizationModel
+	if err := c.get("/v2/organizations/"+orgGuid+"?inline-relations-depth=1", &org); err != nil {
+		return OrgQuotaModel{}, err
+	}
+
+	var summary V2OrganizationSummaryModel
+	if err := c.get("/v2/organizations/"+orgGuid+"/summary", &summary); err != nil {
+		return OrgQuotaModel{}, err
+	}
+
+	quota := OrgQuotaModel{
+		OrgName:       org.Entity.Name,
+		QuotaName:     org.Entity.QuotaDefinition.Entity.Name,
+		MemoryLimit:   org.Entity.QuotaDefinition.Entity.MemoryLimit,
+		InstanceLimit: org.Entity.QuotaDefinition.Entity.AppInstanceLimit,
+	}
+
+	for _, space := range summary.Spaces {
+		quota.MemoryUsed += space.MemDevTotal + space.MemProdTotal
+	}
+
+	return quota, nil
+}
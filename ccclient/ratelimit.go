@@ -0,0 +1,146 @@
+package ccclient
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxRateLimitPause bounds the pauses asked for by the Cloud Controller, in
+// case its clock is off.
+const maxRateLimitPause = 10 * time.Minute
+
+// RateLimiter spaces out the requests of all workers of a command to at
+// most PerSecond, and pauses them while the Cloud Controller throttles the
+// user: after a 429 with Retry-After, or until X-RateLimit-Reset once
+// X-RateLimit-Remaining has run out.
+type RateLimiter struct {
+	// PerSecond is the request rate, unlimited if 0.
+	PerSecond float64
+
+	// OnPause, if set, is called before waiting for the Cloud Controller.
+	OnPause func(wait time.Duration)
+
+	lock sync.Mutex
+	next time.Time
+}
+
+// Wait blocks until the next request may be sent, or fails with the error
+// of ctx once it is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+
+	l.lock.Lock()
+
+	now := time.Now()
+	start := now
+	if l.next.After(now) {
+		start = l.next
+	}
+
+	l.next = start
+	if l.PerSecond > 0 {
+		l.next = start.Add(time.Duration(float64(time.Second) / l.PerSecond))
+	}
+
+	l.lock.Unlock()
+
+	if !start.After(now) {
+		return nil
+	}
+
+	timer := time.NewTimer(start.Sub(now))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Observe pauses the following requests if the response of a request
+// says that the rate limit of the user is reached.
+func (l *RateLimiter) Observe(status int, header http.Header) {
+
+	var until time.Time
+
+	if status == http.StatusTooManyRequests {
+		if wait := RetryAfter(header); wait > 0 {
+			until = time.Now().Add(wait)
+		}
+	}
+
+	if remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining")); err == nil && remaining <= 0 {
+		if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			if resetAt := time.Unix(reset, 0); resetAt.After(until) {
+				until = resetAt
+			}
+		}
+	}
+
+	if until.IsZero() {
+		return
+	}
+
+	if limit := time.Now().Add(maxRateLimitPause); until.After(limit) {
+		until = limit
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if !until.After(l.next) {
+		return
+	}
+
+	l.next = until
+
+	if l.OnPause != nil {
+		l.OnPause(time.Until(until).Round(time.Second))
+	}
+}
+
+// RetryAfter returns the wait of the Retry-After header, given in seconds
+// or as HTTP date, or 0 without one.
+func RetryAfter(header http.Header) time.Duration {
+
+	value := header.Get("Retry-After")
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
+}
+
+// RateLimitTransport sends the requests of Transport when Limiter allows.
+type RateLimitTransport struct {
+	Transport Transport
+	Limiter   *RateLimiter
+
+	// Context, if set, ends the wait for the limiter once it is done.
+	Context context.Context
+}
+
+func (t *RateLimitTransport) Do(method string, path string, body []byte) (string, error) {
+
+	ctx := t.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err := t.Limiter.Wait(ctx); err != nil {
+		return "", err
+	}
+
+	return t.Transport.Do(method, path, body)
+}
@@ -0,0 +1,121 @@
+package ccclient_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	. "github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("RateLimiter", func() {
+
+	It("spaces out requests to the configured rate", func() {
+		inner := &failingTransport{}
+		transport := &RateLimitTransport{Transport: inner, Limiter: &RateLimiter{PerSecond: 20}}
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			_, err := transport.Do("GET", "/v2/apps", nil)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(time.Since(start)).To(BeNumerically(">=", 100*time.Millisecond))
+	})
+
+	It("does not wait without a rate", func() {
+		limiter := &RateLimiter{}
+
+		start := time.Now()
+		for i := 0; i < 100; i++ {
+			Expect(limiter.Wait(context.Background())).To(Succeed())
+		}
+		Expect(time.Since(start)).To(BeNumerically("<", 50*time.Millisecond))
+	})
+
+	It("pauses until the reset once no requests remain", func() {
+		var paused time.Duration
+		limiter := &RateLimiter{OnPause: func(wait time.Duration) { paused = wait }}
+
+		reset := time.Now().Add(2 * time.Second).Unix()
+		limiter.Observe(http.StatusOK, http.Header{
+			"X-Ratelimit-Remaining": []string{"0"},
+			"X-Ratelimit-Reset":     []string{strconv.FormatInt(reset, 10)},
+		})
+
+		Expect(paused).To(BeNumerically(">", 0))
+
+		start := time.Now()
+		Expect(limiter.Wait(context.Background())).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 500*time.Millisecond))
+	})
+
+	It("stops waiting once the context is done", func() {
+		limiter := &RateLimiter{}
+		limiter.Observe(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"60"}})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		inner := &failingTransport{}
+		transport := &RateLimitTransport{Transport: inner, Limiter: limiter, Context: ctx}
+
+		start := time.Now()
+		_, err := transport.Do("GET", "/v2/apps", nil)
+		Expect(err).To(Equal(context.Canceled))
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		Expect(inner.calls).To(BeZero())
+	})
+
+	It("ignores responses with requests remaining", func() {
+		var paused bool
+		limiter := &RateLimiter{OnPause: func(time.Duration) { paused = true }}
+
+		limiter.Observe(http.StatusOK, http.Header{
+			"X-Ratelimit-Remaining": []string{"42"},
+			"X-Ratelimit-Reset":     []string{strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)},
+		})
+
+		Expect(paused).To(BeFalse())
+	})
+
+	It("reads Retry-After in seconds and as date", func() {
+		Expect(RetryAfter(http.Header{"Retry-After": []string{"3"}})).To(Equal(3 * time.Second))
+
+		date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+		Expect(RetryAfter(http.Header{"Retry-After": []string{date}})).To(BeNumerically("~", time.Minute, 2*time.Second))
+
+		Expect(RetryAfter(http.Header{})).To(BeZero())
+	})
+
+	Context("with the direct HTTP transport", func() {
+		var server *ghttp.Server
+
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("returns the Retry-After of throttled requests and pauses the limiter", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusTooManyRequests, `{}`, http.Header{"Retry-After": []string{"1"}}))
+
+			var paused time.Duration
+			limiter := &RateLimiter{OnPause: func(wait time.Duration) { paused = wait }}
+
+			transport := NewTokenTransport(server.URL(), "my-token", false)
+			transport.(*HTTPTransport).Limiter = limiter
+
+			_, err := transport.Do("GET", "/v2/apps", nil)
+			Expect(err).To(BeAssignableToTypeOf(&StatusError{}))
+			Expect(err.(*StatusError).RetryAfter).To(Equal(time.Second))
+			Expect(paused).To(Equal(time.Second))
+		})
+	})
+})
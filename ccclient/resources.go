@@ -0,0 +1,37 @@
+package ccclient
+
+// ListResources follows the v2 pagination of path and returns all resources.
+func (c *Client) ListResources(path string) ([]NamedResourceModel, error) {
+
+	var resources []NamedResourceModel
+
+	for path != "" {
+		c.announce(path)
+
+		var page NamedResourcesModel
+		if err := c.get(path, &page); err != nil {
+			return nil, err
+		}
+
+		resources = append(resources, page.Resources...)
+		path = page.NextURL
+	}
+
+	return resources, nil
+}
+
+// FindGuid returns the GUID of the resource called name in the v2 listing at
+// path, e.g. FindGuid("Space", "dev", "v2/spaces").
+func (c *Client) FindGuid(kind string, name string, path string, filters ...string) (string, error) {
+
+	var resources NamedResourcesModel
+	if err := c.get(V2Path(path, append([]string{"name:" + name}, filters...)), &resources); err != nil {
+		return "", err
+	}
+
+	if len(resources.Resources) == 0 {
+		return "", &NotFoundError{Kind: kind, Name: name}
+	}
+
+	return resources.Resources[0].Metadata.Guid, nil
+}
@@ -0,0 +1,149 @@
+package ccclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// StatusError is returned by HTTPTransport for responses with an error
+// status. Only some of them are worth retrying, see IsRetryable.
+type StatusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Body       string
+
+	// RetryAfter is the wait asked for by the Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Cloud Controller responded with status %d: %s", e.StatusCode, e.Body)
+}
+
+func retryableStatus(status int) bool {
+	return status == 429 || status == 502 || status == 503
+}
+
+// IsRetryable reports whether a request failing with err may succeed when
+// sent again: throttling, gateway errors and dropped connections.
+func IsRetryable(err error) bool {
+
+	if statusErr, ok := err.(*StatusError); ok {
+		return retryableStatus(statusErr.StatusCode)
+	}
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+
+	if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+		return true
+	}
+
+	return isConnectionRefused(err) || strings.Contains(err.Error(), "connection reset") || strings.HasSuffix(err.Error(), "EOF")
+}
+
+// isConnectionRefused reports whether err is a connection the Cloud
+// Controller refused, whose request it never saw.
+func isConnectionRefused(err error) bool {
+	return strings.Contains(err.Error(), "connection refused")
+}
+
+// idempotent methods can be sent again whatever became of the first attempt.
+func idempotent(method string) bool {
+
+	switch strings.ToUpper(method) {
+	case "GET", "HEAD", "PUT":
+		return true
+	}
+
+	return false
+}
+
+// retryableRequest reports whether a request with method failing with err
+// may be sent again. After a gateway error or a dropped connection, the
+// Cloud Controller may have applied a POST, PATCH or DELETE all the same,
+// like a restart or an export, so those are only sent again when it never
+// took them: when throttled or when the connection was refused.
+func retryableRequest(method string, err error) bool {
+
+	if idempotent(method) {
+		return IsRetryable(err)
+	}
+
+	if statusErr, ok := err.(*StatusError); ok {
+		return statusErr.StatusCode == 429
+	}
+
+	return isConnectionRefused(err)
+}
+
+// RetryTransport retries retryable failures of Transport up to Retries times.
+// The wait between attempts starts at Delay and doubles every attempt, with
+// random jitter so that parallel workers don't retry in lockstep. A longer
+// Retry-After of the Cloud Controller is waited for instead.
+type RetryTransport struct {
+	Transport Transport
+	Retries   int
+	Delay     time.Duration
+
+	// Context, if set, ends the wait for the next attempt once it is done.
+	Context context.Context
+}
+
+func (t *RetryTransport) Do(method string, path string, body []byte) (string, error) {
+
+	for attempt := 0; ; attempt++ {
+		response, err := t.Transport.Do(method, path, body)
+
+		if err == nil || attempt >= t.Retries || !retryableRequest(method, err) {
+			return response, err
+		}
+
+		wait := backoff(t.Delay, attempt)
+
+		if statusErr, ok := err.(*StatusError); ok && statusErr.RetryAfter > wait {
+			wait = statusErr.RetryAfter
+		}
+
+		if err := t.sleep(wait); err != nil {
+			return "", err
+		}
+	}
+}
+
+// sleep waits for wait, or fails with the error of Context once it is done.
+func (t *RetryTransport) sleep(wait time.Duration) error {
+
+	if t.Context == nil {
+		time.Sleep(wait)
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-t.Context.Done():
+		return t.Context.Err()
+	}
+}
+
+func backoff(delay time.Duration, attempt int) time.Duration {
+
+	wait := delay << uint(attempt)
+
+	if wait <= 0 {
+		return 0
+	}
+
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
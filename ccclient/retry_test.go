@@ -0,0 +1,105 @@
+package ccclient_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type failingTransport struct {
+	failures []error
+	calls    int
+}
+
+func (t *failingTransport) Do(method string, path string, body []byte) (string, error) {
+	t.calls++
+	if t.calls <= len(t.failures) {
+		return "", t.failures[t.calls-1]
+	}
+	return "{}", nil
+}
+
+var _ = Describe("RetryTransport", func() {
+
+	It("retries throttled requests until they succeed", func() {
+		inner := &failingTransport{failures: []error{&StatusError{StatusCode: 429}, &StatusError{StatusCode: 503}}}
+		transport := &RetryTransport{Transport: inner, Retries: 3}
+
+		response, err := transport.Do("GET", "/v2/apps", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response).To(Equal("{}"))
+		Expect(inner.calls).To(Equal(3))
+	})
+
+	It("gives up after the configured number of retries", func() {
+		inner := &failingTransport{failures: []error{&StatusError{StatusCode: 502}, &StatusError{StatusCode: 502}}}
+		transport := &RetryTransport{Transport: inner, Retries: 1}
+
+		_, err := transport.Do("GET", "/v2/apps", nil)
+		Expect(err).To(BeAssignableToTypeOf(&StatusError{}))
+		Expect(inner.calls).To(Equal(2))
+	})
+
+	It("waits as long as Retry-After asks before retrying", func() {
+		inner := &failingTransport{failures: []error{&StatusError{StatusCode: 429, RetryAfter: 100 * time.Millisecond}}}
+		transport := &RetryTransport{Transport: inner, Retries: 1}
+
+		start := time.Now()
+		_, err := transport.Do("GET", "/v2/apps", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically(">=", 100*time.Millisecond))
+	})
+
+	It("retries changes only when the Cloud Controller never took them", func() {
+		inner := &failingTransport{failures: []error{&StatusError{StatusCode: 502}}}
+		transport := &RetryTransport{Transport: inner, Retries: 3}
+
+		_, err := transport.Do("POST", "/v2/apps/guid/restage", nil)
+		Expect(err).To(BeAssignableToTypeOf(&StatusError{}))
+		Expect(inner.calls).To(Equal(1))
+
+		inner = &failingTransport{failures: []error{&StatusError{StatusCode: 429}, errors.New("dial tcp: connect: connection refused")}}
+		transport = &RetryTransport{Transport: inner, Retries: 3}
+
+		_, err = transport.Do("PATCH", "/v3/apps/guid", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inner.calls).To(Equal(3))
+	})
+
+	It("retries PUTs like reads", func() {
+		inner := &failingTransport{failures: []error{&StatusError{StatusCode: 502}, errors.New("read: connection reset by peer")}}
+		transport := &RetryTransport{Transport: inner, Retries: 3}
+
+		_, err := transport.Do("PUT", "/v2/apps/guid", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inner.calls).To(Equal(3))
+	})
+
+	It("stops waiting for the next attempt once its context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		inner := &failingTransport{failures: []error{&StatusError{StatusCode: 503}}}
+		transport := &RetryTransport{Transport: inner, Retries: 1, Delay: time.Minute, Context: ctx}
+
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		start := time.Now()
+		_, err := transport.Do("GET", "/v2/apps", nil)
+		Expect(err).To(Equal(context.Canceled))
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		Expect(inner.calls).To(Equal(1))
+	})
+
+	It("does not retry other errors", func() {
+		inner := &failingTransport{failures: []error{errors.New("unknown authority")}}
+		transport := &RetryTransport{Transport: inner, Retries: 3}
+
+		_, err := transport.Do("GET", "/v2/apps", nil)
+		Expect(err).To(MatchError("unknown authority"))
+		Expect(inner.calls).To(Equal(1))
+	})
+})
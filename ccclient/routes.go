@@ -0,0 +1,98 @@
+package ccclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// AppURLs returns the URLs of the routes mapped to app, e.g.
+// my-app.example.com/api or tcp.example.com:1024.
+func (c *Client) AppURLs(app AppModel) ([]string, error) {
+
+	path := app.Entity.RoutesURL
+	if path == "" {
+		path = "/v2/apps/" + app.Metadata.Guid + "/routes"
+	}
+	path += "?inline-relations-depth=1"
+
+	response, err := c.request("GET", path, nil)
+
+	if v2Unavailable(response) {
+		return c.v3AppURLs(app.Metadata.Guid)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+
+	for {
+		var page RoutesModel
+		if err := json.Unmarshal([]byte(response), &page); err != nil {
+			return nil, err
+		}
+
+		for _, route := range page.Resources {
+			urls = append(urls, route.URL())
+		}
+
+		if page.NextURL == "" {
+			return urls, nil
+		}
+
+		if response, err = c.request("GET", page.NextURL, nil); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// URL formats the route the way the v3 API does.
+func (route RouteModel) URL() string {
+
+	host := route.Entity.Domain.Entity.Name
+	if route.Entity.Host != "" {
+		host = route.Entity.Host + "." + host
+	}
+
+	if route.Entity.Port > 0 {
+		return fmt.Sprintf("%s:%d", host, route.Entity.Port)
+	}
+
+	return host + route.Entity.Path
+}
+
+func (c *Client) v3AppURLs(guid string) ([]string, error) {
+
+	var urls []string
+	nextURL := "/v3/apps/" + guid + "/routes"
+
+	for nextURL != "" {
+		var page V3RoutesModel
+		if err := c.get(nextURL, &page); err != nil {
+			return nil, err
+		}
+
+		for _, route := range page.Resources {
+			urls = append(urls, route.URL)
+		}
+
+		nextURL = ""
+		if page.Pagination.Next != nil {
+			nextURL = relativeURL(page.Pagination.Next.Href)
+		}
+	}
+
+	return urls, nil
+}
+
+// URLHost returns the host name of url, as returned by AppURLs.
+func URLHost(url string) string {
+
+	if end := strings.IndexAny(url, ":/"); end >= 0 {
+		return url[:end]
+	}
+
+	return url
+}
@@ -0,0 +1,86 @@
+package ccclient
+
+import "encoding/json"
+
+// SpaceModel names a space and the org it belongs to.
+type SpaceModel struct {
+	Guid    string
+	Name    string
+	OrgName string
+}
+
+// ListSpaces returns the spaces visible to the user by guid, falling back to
+// the v3 API on foundations where v2 has been removed.
+func (c *Client) ListSpaces() (map[string]SpaceModel, error) {
+
+	path := "v2/spaces?inline-relations-depth=1"
+	c.announce(path)
+
+	response, err := c.request("GET", path, nil)
+
+	if v2Unavailable(response) {
+		return c.listSpacesV3()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	spaces := map[string]SpaceModel{}
+
+	for {
+		var page V2SpacesModel
+		if err := json.Unmarshal([]byte(response), &page); err != nil {
+			return nil, err
+		}
+
+		for _, space := range page.Resources {
+			spaces[space.Metadata.Guid] = SpaceModel{
+				Guid:    space.Metadata.Guid,
+				Name:    space.Entity.Name,
+				OrgName: space.Entity.Organization.Entity.Name,
+			}
+		}
+
+		if page.NextURL == "" {
+			return spaces, nil
+		}
+
+		if response, err = c.request("GET", page.NextURL, nil); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) listSpacesV3() (map[string]SpaceModel, error) {
+
+	spaces := map[string]SpaceModel{}
+	nextURL := "v3/spaces?include=organization"
+
+	for nextURL != "" {
+		var page V3SpacesModel
+		if err := c.get(nextURL, &page); err != nil {
+			return nil, err
+		}
+
+		orgs := map[string]string{}
+		for _, org := range page.Included.Organizations {
+			orgs[org.Guid] = org.Name
+		}
+
+		for _, space := range page.Resources {
+			spaces[space.Guid] = SpaceModel{
+				Guid:    space.Guid,
+				Name:    space.Name,
+				OrgName: orgs[space.Relationships.Organization.Data.Guid],
+			}
+		}
+
+		nextURL = ""
+		if page.Pagination.Next != nil {
+			nextURL = relativeURL(page.Pagination.Next.Href)
+		}
+	}
+
+	return spaces, nil
+}
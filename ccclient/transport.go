@@ -0,0 +1,321 @@
+package ccclient
+
+import (
+	"bytes"
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transport sends a request to the Cloud Controller and returns the response
+// body. Error responses are returned as bodies too, like `cf curl` does.
+type Transport interface {
+	Do(method string, path string, body []byte) (string, error)
+}
+
+// DefaultTimeout bounds each request of the direct HTTP transport unless
+// changed with WithTimeout.
+const DefaultTimeout = 60 * time.Second
+
+// NewTransport returns a direct HTTP transport for the targeted API endpoint,
+// or one going through `cf curl` if the CLI can't provide an endpoint and
+// access token.
+func NewTransport(connection plugin.CliConnection) Transport {
+
+	endpoint, err := connection.ApiEndpoint()
+	if err != nil || endpoint == "" {
+		return &CurlTransport{connection: connection}
+	}
+
+	token, err := connection.AccessToken()
+	if err != nil || token == "" {
+		return &CurlTransport{connection: connection}
+	}
+
+	skipSSLValidation, _ := connection.IsSSLDisabled()
+
+	// The token is kept for the requests to come, so that they don't each
+	// ask the CLI over RPC.
+	return &HTTPTransport{
+		Endpoint:   strings.TrimSuffix(endpoint, "/"),
+		Connection: connection,
+		Token:      bearer(token),
+		Client:     newHTTPClient(skipSSLValidation),
+	}
+}
+
+// NewTokenTransport returns a direct HTTP transport for the Cloud Controller
+// at endpoint, authenticated with token, for foundations the CLI doesn't
+// target. The token isn't refreshed.
+func NewTokenTransport(endpoint string, token string, skipSSLValidation bool) Transport {
+
+	return &HTTPTransport{
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		Token:    bearer(token),
+		Client:   newHTTPClient(skipSSLValidation),
+	}
+}
+
+func bearer(token string) string {
+
+	if !strings.HasPrefix(strings.ToLower(token), "bearer ") {
+		return "bearer " + token
+	}
+
+	return token
+}
+
+func newHTTPClient(skipSSLValidation bool) *http.Client {
+	return &http.Client{
+		Timeout: DefaultTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: skipSSLValidation},
+		},
+	}
+}
+
+// The CLI collects the output of plugin commands in a single buffer, so two
+// curls in flight at the same time would see each other's output. The lock
+// is shared by all transports since they talk to the same CLI process, which
+// also means that `cf curl` doesn't get faster with --concurrency.
+var curlLock sync.Mutex
+
+// CurlTransport runs `cf curl` through the plugin RPC connection.
+type CurlTransport struct {
+	connection plugin.CliConnection
+}
+
+func (t *CurlTransport) Do(method string, path string, body []byte) (string, error) {
+
+	args := []string{"curl", path}
+
+	if method != "GET" {
+		args = append(args, "-X", method)
+	}
+
+	if body != nil {
+		args = append(args, "-d", string(body))
+	}
+
+	curlLock.Lock()
+	defer curlLock.Unlock()
+
+	output, err := t.connection.CliCommandWithoutTerminalOutput(args...)
+
+	return strings.Join(output, ""), err
+}
+
+// HTTPTransport talks to the Cloud Controller directly, authenticated with
+// the access token of the CLI.
+type HTTPTransport struct {
+	Endpoint string
+
+	// Connection, if set, provides the access token: once when Token is
+	// empty, and a new one once the Cloud Controller has rejected Token.
+	// The CLI refreshes its token when it is about to expire.
+	Connection plugin.CliConnection
+
+	// Token is the access token sent with the requests.
+	Token string
+
+	Client *http.Client
+
+	// Limiter, if set, is told about the rate limit headers of responses.
+	Limiter *RateLimiter
+
+	// Context, if set, aborts the requests in flight when it is done, see
+	// WithContext.
+	Context context.Context
+
+	// Refresh, if set, returns a new Token once the Cloud Controller has
+	// rejected it with status 401, e.g. with the refresh flow of the UAA,
+	// instead of asking Connection.
+	Refresh func() (string, error)
+
+	tokenLock sync.Mutex
+}
+
+// Do sends the request, and sends it again once with a refreshed token if
+// the token has expired, so that long scans outlive it.
+func (t *HTTPTransport) Do(method string, path string, body []byte) (string, error) {
+
+	token, err := t.token()
+	if err != nil {
+		return "", err
+	}
+
+	response, err := t.send(method, path, body, token)
+
+	if statusErr, ok := err.(*StatusError); ok && statusErr.StatusCode == http.StatusUnauthorized && t.refreshable() {
+		if token, err = t.refresh(token); err != nil {
+			return "", err
+		}
+
+		return t.send(method, path, body, token)
+	}
+
+	return response, err
+}
+
+// token returns the cached token, asking Connection for one the first time
+// if there is none.
+func (t *HTTPTransport) token() (string, error) {
+
+	t.tokenLock.Lock()
+	defer t.tokenLock.Unlock()
+
+	if t.Token == "" && t.Connection != nil {
+		token, err := t.Connection.AccessToken()
+		if err != nil {
+			return "", err
+		}
+
+		t.Token = bearer(token)
+	}
+
+	return t.Token, nil
+}
+
+func (t *HTTPTransport) refreshable() bool {
+	return t.Refresh != nil || t.Connection != nil
+}
+
+// refresh returns a new token instead of rejected. Workers getting 401 at the
+// same time share the token of the first refresh.
+func (t *HTTPTransport) refresh(rejected string) (string, error) {
+
+	t.tokenLock.Lock()
+	defer t.tokenLock.Unlock()
+
+	if t.Token != rejected {
+		return t.Token, nil
+	}
+
+	refresh := t.Refresh
+	if refresh == nil {
+		refresh = t.Connection.AccessToken
+	}
+
+	token, err := refresh()
+	if err != nil {
+		return "", fmt.Errorf("Failed to refresh the access token: %s", err)
+	}
+
+	t.Token = bearer(token)
+
+	return t.Token, nil
+}
+
+func (t *HTTPTransport) send(method string, path string, body []byte, token string) (string, error) {
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	request, err := http.NewRequest(method, t.Endpoint+"/"+strings.TrimPrefix(path, "/"), reader)
+
+	if err != nil {
+		return "", err
+	}
+
+	if t.Context != nil {
+		request = request.WithContext(t.Context)
+	}
+
+	request.Header.Set("Authorization", token)
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := t.Client.Do(request)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer response.Body.Close()
+
+	if t.Limiter != nil {
+		t.Limiter.Observe(response.StatusCode, response.Header)
+	}
+
+	data, err := ioutil.ReadAll(response.Body)
+
+	if err == nil && response.StatusCode >= 400 {
+		err = &StatusError{
+			Method:     method,
+			Path:       path,
+			StatusCode: response.StatusCode,
+			Body:       string(data),
+			RetryAfter: RetryAfter(response.Header),
+		}
+	}
+
+	return string(data), err
+}
+
+// WithTimeout bounds each request of transport by timeout.
+func WithTimeout(transport Transport, timeout time.Duration) Transport {
+
+	if direct, ok := transport.(*HTTPTransport); ok {
+		direct.Client.Timeout = timeout
+		return direct
+	}
+
+	return &timeoutTransport{transport: transport, timeout: timeout}
+}
+
+// timeoutTransport gives up waiting for transports that can't be cancelled,
+// like cf curl over RPC.
+type timeoutTransport struct {
+	transport Transport
+	timeout   time.Duration
+}
+
+type result struct {
+	response string
+	err      error
+}
+
+func (t *timeoutTransport) Do(method string, path string, body []byte) (string, error) {
+
+	done := make(chan result, 1)
+
+	go func() {
+		response, err := t.transport.Do(method, path, body)
+		done <- result{response, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.response, r.err
+	case <-time.After(t.timeout):
+		return "", &TimeoutError{Method: method, Path: path, Limit: t.timeout}
+	}
+}
+
+// TimeoutError is returned by WithTimeout for requests of transports without
+// timeout of their own. It isn't temporary: the abandoned request may still
+// be holding the CLI.
+type TimeoutError struct {
+	Method string
+	Path   string
+	Limit  time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s %s timed out after %s", e.Method, e.Path, e.Limit)
+}
+
+func (e *TimeoutError) Timeout() bool   { return true }
+func (e *TimeoutError) Temporary() bool { return false }
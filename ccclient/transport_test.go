@@ -0,0 +1,201 @@
+package ccclient_test
+
+import (
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	. "github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("NewTransport", func() {
+
+	var connection *pluginfakes.FakeCliConnection
+
+	BeforeEach(func() {
+		connection = new(pluginfakes.FakeCliConnection)
+	})
+
+	It("falls back to cf curl without an access token", func() {
+		connection.ApiEndpointReturns("https://api.example.com", nil)
+		connection.AccessTokenReturns("", nil)
+
+		Expect(NewTransport(connection)).To(BeAssignableToTypeOf(&CurlTransport{}))
+	})
+
+	Context("with an API endpoint and access token", func() {
+		var server *ghttp.Server
+
+		BeforeEach(func() {
+			server = ghttp.NewTLSServer()
+			connection.ApiEndpointReturns(server.URL(), nil)
+			connection.AccessTokenReturns("bearer my-token", nil)
+			connection.IsSSLDisabledReturns(true, nil)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("requests the Cloud Controller directly with the token", func() {
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/v2/apps/1234/env"),
+				ghttp.VerifyHeader(http.Header{"Authorization": []string{"bearer my-token"}}),
+				ghttp.RespondWith(http.StatusOK, `{"environment_json":{"KEY":"value"}}`),
+			))
+
+			env, err := New(connection).GetAppEnv("1234")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(HaveKey("environment_json"))
+			Expect(connection.CliCommandWithoutTerminalOutputCallCount()).To(Equal(0))
+		})
+
+		It("reports the endpoint and status of error responses", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusNotFound,
+				`{"code":100004,"description":"The app could not be found: 1234","error_code":"CF-AppNotFound"}`))
+
+			_, err := New(connection).GetAppEnv("1234")
+			Expect(err).To(BeAssignableToTypeOf(&APIError{}))
+
+			apiErr := err.(*APIError)
+			Expect(apiErr.Endpoint()).To(Equal("GET /v2/apps/1234/env"))
+			Expect(apiErr.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(apiErr.ErrorCode).To(Equal("CF-AppNotFound"))
+		})
+
+		It("sends JSON bodies", func() {
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("PUT", "/v2/apps/1234"),
+				ghttp.VerifyJSON(`{"environment_json":{"KEY":"value"}}`),
+				ghttp.RespondWith(http.StatusCreated, `{}`),
+			))
+
+			err := New(connection).SetAppEnv("1234", map[string]interface{}{"KEY": "value"})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("asks the CLI for a new token once the token has expired", func() {
+			connection.AccessTokenReturnsOnCall(0, "bearer my-token", nil)
+			connection.AccessTokenReturnsOnCall(1, "bearer refreshed-token", nil)
+
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusUnauthorized, `{"code":1000,"description":"Invalid Auth Token","error_code":"CF-InvalidAuthToken"}`),
+				ghttp.CombineHandlers(
+					ghttp.VerifyHeader(http.Header{"Authorization": []string{"bearer refreshed-token"}}),
+					ghttp.RespondWith(http.StatusOK, `{"environment_json":{"KEY":"value"}}`),
+				),
+			)
+
+			env, err := New(connection).GetAppEnv("1234")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(HaveKey("environment_json"))
+			Expect(connection.AccessTokenCallCount()).To(Equal(2))
+		})
+
+		It("asks the CLI for the token only once for all requests", func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusOK, `{"environment_json":{}}`),
+				ghttp.RespondWith(http.StatusOK, `{"environment_json":{}}`),
+			)
+
+			client := New(connection)
+			_, err := client.GetAppEnv("1234")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = client.GetAppEnv("5678")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(connection.AccessTokenCallCount()).To(Equal(1))
+		})
+	})
+})
+
+var _ = Describe("NewTokenTransport", func() {
+
+	It("requests the Cloud Controller with the given token", func() {
+		server := ghttp.NewTLSServer()
+		defer server.Close()
+
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("GET", "/v2/apps/1234/env"),
+			ghttp.VerifyHeader(http.Header{"Authorization": []string{"bearer other-token"}}),
+			ghttp.RespondWith(http.StatusOK, `{"environment_json":{"KEY":"value"}}`),
+		))
+
+		env, err := NewWithTransport(NewTokenTransport(server.URL()+"/", "other-token", true)).GetAppEnv("1234")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(HaveKey("environment_json"))
+	})
+
+	It("sends the request again with a refreshed token once the token has expired", func() {
+		server := ghttp.NewTLSServer()
+		defer server.Close()
+
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyHeader(http.Header{"Authorization": []string{"bearer other-token"}}),
+				ghttp.RespondWith(http.StatusUnauthorized, `{"code":1000,"description":"Invalid Auth Token","error_code":"CF-InvalidAuthToken"}`),
+			),
+			ghttp.CombineHandlers(
+				ghttp.VerifyHeader(http.Header{"Authorization": []string{"bearer refreshed-token"}}),
+				ghttp.RespondWith(http.StatusOK, `{"environment_json":{"KEY":"value"}}`),
+			),
+		)
+
+		transport := NewTokenTransport(server.URL(), "other-token", true).(*HTTPTransport)
+		transport.Refresh = func() (string, error) { return "refreshed-token", nil }
+
+		env, err := NewWithTransport(transport).GetAppEnv("1234")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(HaveKey("environment_json"))
+	})
+
+	It("reports the 401 without a way to refresh the token", func() {
+		server := ghttp.NewTLSServer()
+		defer server.Close()
+
+		server.AppendHandlers(ghttp.RespondWith(http.StatusUnauthorized,
+			`{"code":1000,"description":"Invalid Auth Token","error_code":"CF-InvalidAuthToken"}`))
+
+		_, err := NewWithTransport(NewTokenTransport(server.URL(), "other-token", true)).GetAppEnv("1234")
+		Expect(err).To(HaveOccurred())
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+})
+
+type slowTransport struct {
+	delay time.Duration
+}
+
+func (t *slowTransport) Do(method string, path string, body []byte) (string, error) {
+	time.Sleep(t.delay)
+	return "{}", nil
+}
+
+var _ = Describe("WithTimeout", func() {
+
+	It("gives up on requests that take too long", func() {
+		transport := WithTimeout(&slowTransport{delay: time.Second}, 10*time.Millisecond)
+
+		_, err := transport.Do("GET", "/v2/apps", nil)
+		Expect(err).To(MatchError("GET /v2/apps timed out after 10ms"))
+	})
+
+	It("returns responses that arrive in time", func() {
+		transport := WithTimeout(&slowTransport{}, time.Second)
+
+		response, err := transport.Do("GET", "/v2/apps", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response).To(Equal("{}"))
+	})
+
+	It("sets the client timeout of the HTTP transport", func() {
+		direct := &HTTPTransport{Client: &http.Client{}}
+
+		Expect(WithTimeout(direct, 5*time.Second)).To(BeIdenticalTo(direct))
+		Expect(direct.Client.Timeout).To(Equal(5 * time.Second))
+	})
+})
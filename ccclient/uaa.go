@@ -0,0 +1,90 @@
+package ccclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// UAARefresher gets new access tokens with the refresh token of a login, like
+// the CLI does once its token has expired. Set its Refresh as that of an
+// HTTPTransport. The new tokens aren't saved.
+type UAARefresher struct {
+	// Endpoint is the UAA of the foundation, e.g. https://uaa.example.com.
+	Endpoint     string
+	RefreshToken string
+
+	// ClientID and ClientSecret identify the OAuth client of the login,
+	// "cf" without a secret for logins of the CLI.
+	ClientID     string
+	ClientSecret string
+
+	Client *http.Client
+
+	lock sync.Mutex
+}
+
+func NewUAARefresher(endpoint string, refreshToken string, skipSSLValidation bool) *UAARefresher {
+	return &UAARefresher{
+		Endpoint:     strings.TrimSuffix(endpoint, "/"),
+		RefreshToken: refreshToken,
+		ClientID:     "cf",
+		Client:       newHTTPClient(skipSSLValidation),
+	}
+}
+
+type uaaTokenModel struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Refresh returns a new access token, with its type, e.g. "bearer eyJ...".
+// The UAA may rotate the refresh token, which is kept for the next refresh.
+func (r *UAARefresher) Refresh() (string, error) {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {r.RefreshToken}}
+
+	request, err := http.NewRequest("POST", r.Endpoint+"/oauth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	request.SetBasicAuth(r.ClientID, r.ClientSecret)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := r.Client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	// The body of failures may echo the refresh token, so only the status
+	// is reported.
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("UAA responded with status %d, log in again", response.StatusCode)
+	}
+
+	var token uaaTokenModel
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", err
+	}
+
+	if token.RefreshToken != "" {
+		r.RefreshToken = token.RefreshToken
+	}
+
+	tokenType := token.TokenType
+	if tokenType == "" {
+		tokenType = "bearer"
+	}
+
+	return tokenType + " " + token.AccessToken, nil
+}
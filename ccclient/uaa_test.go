@@ -0,0 +1,52 @@
+package ccclient_test
+
+import (
+	"net/http"
+	"net/url"
+
+	. "github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("UAARefresher", func() {
+
+	var server *ghttp.Server
+
+	BeforeEach(func() {
+		server = ghttp.NewTLSServer()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("gets a new access token with the refresh token and keeps the rotated one", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("POST", "/oauth/token"),
+				ghttp.VerifyBasicAuth("cf", ""),
+				ghttp.VerifyForm(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"first-refresh"}}),
+				ghttp.RespondWith(http.StatusOK, `{"access_token":"new-token","token_type":"bearer","refresh_token":"second-refresh"}`),
+			),
+			ghttp.CombineHandlers(
+				ghttp.VerifyForm(url.Values{"refresh_token": {"second-refresh"}}),
+				ghttp.RespondWith(http.StatusOK, `{"access_token":"newer-token","token_type":"bearer"}`),
+			),
+		)
+
+		refresher := NewUAARefresher(server.URL()+"/", "first-refresh", true)
+
+		Expect(refresher.Refresh()).To(Equal("bearer new-token"))
+		Expect(refresher.Refresh()).To(Equal("bearer newer-token"))
+	})
+
+	It("asks to log in again when the refresh token was rejected", func() {
+		server.AppendHandlers(ghttp.RespondWith(http.StatusUnauthorized, `{"error":"invalid_token"}`))
+
+		_, err := NewUAARefresher(server.URL(), "expired-refresh", true).Refresh()
+		Expect(err).To(MatchError("UAA responded with status 401, log in again"))
+	})
+})
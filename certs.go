@@ -0,0 +1,325 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const pemCertificateHeader = "-----BEGIN CERTIFICATE-----"
+
+// certInfo describes a certificate found in an env. A variable holding a
+// chain yields one per certificate.
+type certInfo struct {
+	Key      string    `json:"key"`
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	SANs     []string  `json:"sans"`
+	NotAfter time.Time `json:"not_after"`
+	DaysLeft int       `json:"days_left"`
+}
+
+// certificatesSchema is the schema of the JSON output of get-env
+// --inspect-certs.
+var certificatesSchema = formatters.Schema{Name: "certificates", Version: 1, Results: []certInfo{}}
+
+// parseCertificates returns the PEM certificates in value. Values are
+// also read with literal \n line breaks, as they are in .env files, and
+// when they are base64-encoded PEM.
+func parseCertificates(value string) []*x509.Certificate {
+
+	if !strings.Contains(value, pemCertificateHeader) {
+		decoded, ok := decodeBase64(value)
+		if !ok || !strings.Contains(string(decoded), pemCertificateHeader) {
+			return nil
+		}
+		value = string(decoded)
+	}
+
+	if !strings.Contains(value, "\n") {
+		value = strings.Replace(value, `\n`, "\n", -1)
+	}
+
+	var certs []*x509.Certificate
+	rest := []byte(value)
+
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+
+		if block == nil {
+			return certs
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+}
+
+// envCertificates finds the certificates in the variables, also in the
+// strings of JSON values like the credentials of VCAP_SERVICES, whose keys
+// are given as paths like VCAP_SERVICES.tls[0].credentials.ca.
+func envCertificates(vars map[string]interface{}, now time.Time) []certInfo {
+
+	var found []certInfo
+
+	for _, key := range sortedKeys(vars) {
+		walkStrings(key, vars[key], func(path string, value string) {
+			for _, cert := range parseCertificates(value) {
+				found = append(found, describeCertificate(path, cert, now))
+			}
+		})
+	}
+
+	return found
+}
+
+func walkStrings(path string, value interface{}, fn func(path string, value string)) {
+
+	switch value := value.(type) {
+	case string:
+		fn(path, value)
+	case map[string]interface{}:
+		for _, key := range sortedKeys(value) {
+			walkStrings(path+"."+key, value[key], fn)
+		}
+	case []interface{}:
+		for i, item := range value {
+			walkStrings(path+"["+strconv.Itoa(i)+"]", item, fn)
+		}
+	}
+}
+
+func describeCertificate(key string, cert *x509.Certificate, now time.Time) certInfo {
+
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+
+	return certInfo{
+		Key:      key,
+		Subject:  cert.Subject.String(),
+		Issuer:   cert.Issuer.String(),
+		SANs:     sans,
+		NotAfter: cert.NotAfter.UTC(),
+		DaysLeft: int(cert.NotAfter.Sub(now).Hours() / 24),
+	}
+}
+
+// certExpiry is when a certificate expires, colored when that is soon.
+func certExpiry(cert certInfo) string {
+
+	date := cert.NotAfter.Format("2006-01-02")
+
+	switch {
+	case cert.DaysLeft < 0:
+		return paint(colorRed, fmt.Sprintf("%s (expired %d days ago)", date, -cert.DaysLeft))
+	case cert.DaysLeft < 30:
+		return paint(colorYellow, fmt.Sprintf("%s (in %d days)", date, cert.DaysLeft))
+	default:
+		return fmt.Sprintf("%s (in %d days)", date, cert.DaysLeft)
+	}
+}
+
+// writeCertificates prints the certificates of `cf get-env APP
+// --inspect-certs`.
+func (p *GetEnvPlugin) writeCertificates(env map[string]interface{}) {
+
+	certs := envCertificates(p.selectedVars(env), time.Now())
+
+	out, done := p.openOut()
+	defer done()
+
+	printResult(out, reportFormatter(p.format), envCerts{app: p.appName, certs: certs})
+}
+
+// envCerts is the result of get-env --inspect-certs, the certificates in
+// the env of app.
+type envCerts struct {
+	app   string
+	certs []certInfo
+}
+
+func (e envCerts) Items() interface{} {
+	return e.certs
+}
+
+func (e envCerts) Schema() formatters.Schema {
+	return certificatesSchema
+}
+
+func (e envCerts) Print(out io.Writer) error {
+
+	if len(e.certs) == 0 {
+		_, err := fmt.Fprintf(out, "No certificates found in the env of %s\n", e.app)
+		return err
+	}
+
+	table := tabwriter.NewWriter(out, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(table, "key\tsubject\tissuer\tsans\texpires")
+	for _, cert := range e.certs {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", cert.Key, cert.Subject, cert.Issuer, strings.Join(cert.SANs, ", "), certExpiry(cert))
+	}
+
+	return table.Flush()
+}
+
+// expiringCert is a certificate listed by cert-expiry-scan.
+type expiringCert struct {
+	App string `json:"app"`
+	certInfo
+}
+
+// expiringSchema is the schema of the JSON output of cert-expiry-scan.
+var expiringSchema = formatters.Schema{Name: "expiring_certificates", Version: 1, Results: []expiringCert{}}
+
+type certExpiryScanCommand struct {
+	days        int
+	concurrency int
+	format      string
+	tmpl        listTemplate
+	failures    *scanFailures
+}
+
+func (c *certExpiryScanCommand) Name() string {
+	return "cert-expiry-scan"
+}
+
+func (c *certExpiryScanCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "cert-expiry-scan",
+		HelpText: "List the PEM certificates in the user-provided environment variables of all apps in the targeted space that expire within the given number of days, the soonest first.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf cert-expiry-scan [--days N] [--concurrency N] [--format table|json|ndjson|template [--template TEMPLATE | --template-file FILE]] [--continue-on-error=false]",
+			Options: map[string]string{
+				"days":              "List certificates expiring within N days, or already expired (default 30)",
+				"concurrency":       "Number of apps fetched at the same time (default 4)",
+				"format":            "Output format (default table)",
+				"template":          "Go text/template printed for every certificate with --format template, e.g. '{{.App}} {{.Key}} {{.NotAfter}}'",
+				"template-file":     "Read the template of --format template from FILE",
+				"continue-on-error": "Keep scanning when the env of an app can't be fetched, and list those apps at the end, exiting with 6 if others were scanned (default true)",
+			},
+		},
+	}
+}
+
+func (c *certExpiryScanCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("cert-expiry-scan")
+	flags.IntVar(&c.days, "days", 30, "")
+	flags.IntVar(&c.concurrency, "concurrency", 4, "")
+	flags.StringVar(&c.format, "format", formatTable, "")
+	c.tmpl.register(flags)
+	c.failures = addScanFailureFlags(flags)
+
+	return flags
+}
+
+func (c *certExpiryScanCommand) schema(args []string) formatters.Schema {
+	return expiringSchema
+}
+
+func (c *certExpiryScanCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 0 {
+		failUsage("cert-expiry-scan takes no arguments")
+	}
+
+	if c.days < 0 {
+		failUsage("Days must not be negative, got %d", c.days)
+	}
+
+	if c.concurrency < 1 {
+		failUsage("Concurrency must be at least 1, got %d", c.concurrency)
+	}
+
+	if c.format != formatTable && c.format != formatJson && c.format != formatNdjson && c.format != formatTemplate {
+		failUsage("Unsupported format '%s'. Supported formats: table, json, ndjson, template", c.format)
+	}
+
+	fatalIf(c.tmpl.setup(c.format))
+
+	stopInterrupts := handleInterrupts(ui)
+	defer stopInterrupts()
+
+	space, err := targetedSpace(cliConnection)
+	fatalIf(err)
+
+	apps := spaceApps(cliConnection, "", space.Name, space.Guid)
+	now := time.Now()
+
+	found := make([][]certInfo, len(apps))
+	progress := newProgress(len(apps))
+
+	defer c.failures.report(ui, len(apps))
+
+	inParallel(len(apps), c.concurrency, func(index int) {
+		if env, ok := c.failures.fetchEnv(cliConnection, apps[index]); ok {
+			found[index] = envCertificates(env, now)
+		}
+		progress.increment()
+	})
+	progress.done()
+
+	expiring := []expiringCert{}
+
+	for i, app := range apps {
+		for _, cert := range found[i] {
+			if cert.DaysLeft < c.days {
+				expiring = append(expiring, expiringCert{App: app.App.Entity.Name, certInfo: cert})
+			}
+		}
+	}
+
+	sort.SliceStable(expiring, func(i, j int) bool { return expiring[i].NotAfter.Before(expiring[j].NotAfter) })
+
+	printResult(ui.Out(), newFormatter(c.format, c.tmpl), expiringCerts{space: space.Name, days: c.days, certs: expiring})
+}
+
+// expiringCerts is the result of cert-expiry-scan, the certificates of the
+// apps of space expiring within days, the earliest first.
+type expiringCerts struct {
+	space string
+	days  int
+	certs []expiringCert
+}
+
+func (e expiringCerts) Items() interface{} {
+	return e.certs
+}
+
+func (e expiringCerts) Schema() formatters.Schema {
+	return expiringSchema
+}
+
+func (e expiringCerts) Print(out io.Writer) error {
+
+	if len(e.certs) == 0 {
+		_, err := fmt.Fprintf(out, "No certificates expiring within %d days in space %s\n", e.days, e.space)
+		return err
+	}
+
+	rows := make([][]string, len(e.certs))
+	for i, cert := range e.certs {
+		rows[i] = []string{cert.App, cert.Key, cert.Subject, certExpiry(cert.certInfo)}
+	}
+
+	return formatters.Human{}.Format(out, formatters.Table{Columns: []string{"app", "key", "subject", "expires"}, Cells: rows})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// envChangeSet is an update of the user-provided env of an app. Every
+// command changing env variables applies its update through it, so that the
+// global --dry-run works the same for all of them.
+type envChangeSet struct {
+	appName string
+	guid    string
+	before  map[string]interface{}
+	after   map[string]interface{}
+
+	// force applies updates larger than the Cloud Controller accepts, for
+	// foundations configured with a higher limit.
+	force bool
+}
+
+// envSizeLimit is the size of the environment_json the Cloud Controller
+// accepts by default. Larger updates fail with an unhelpful error, so they
+// are refused before sending them.
+const envSizeLimit = 130 * 1024
+
+// envSizeWarning is the share of envSizeLimit above which updates warn.
+const envSizeWarning = 0.8
+
+// writePreview lists the variables that the update adds (+), changes (~) or
+// removes (-).
+func (c envChangeSet) writePreview(out io.Writer) {
+
+	keys := map[string]interface{}{}
+	for key := range c.before {
+		keys[key] = nil
+	}
+	for key := range c.after {
+		keys[key] = nil
+	}
+
+	for _, key := range sortedKeys(keys) {
+		before, inBefore := c.before[key]
+		after, inAfter := c.after[key]
+
+		switch {
+		case !inBefore:
+			fmt.Fprintf(out, "+ %s\n", key)
+		case !inAfter:
+			fmt.Fprintf(out, "- %s\n", paint(colorRed, key))
+		case formatEnvValue(before) != formatEnvValue(after):
+			fmt.Fprintf(out, "~ %s\n", paint(colorYellow, key))
+		}
+	}
+}
+
+// apply updates the env of the app and reports whether it did. With
+// --dry-run, it prints the request it would send instead.
+func (c envChangeSet) apply(ui UI, cliConnection plugin.CliConnection) bool {
+
+	c.checkSize(ui.Out())
+
+	if globals.dryRun {
+		c.writeRequest(ui.Out())
+		return false
+	}
+
+	fatalIf(ccClient(cliConnection).SetAppEnv(c.guid, c.after))
+
+	return true
+}
+
+// checkSize fails if the env would exceed envSizeLimit, naming the largest
+// variables, and warns if it comes close. Updates that don't grow the env
+// only warn, since the foundation evidently accepted it before.
+func (c envChangeSet) checkSize(out io.Writer) {
+
+	size := envSize(c.after)
+
+	if size < int(envSizeLimit*envSizeWarning) {
+		return
+	}
+
+	if size <= envSizeLimit || size <= envSize(c.before) || c.force {
+		fmt.Fprintf(out, "Warning: the environment of '%s' is %d bytes, %d%% of the %d bytes the Cloud Controller accepts. Largest variables: %s\n", c.appName, size, size*100/envSizeLimit, envSizeLimit, largestVariables(c.after, 3))
+		return
+	}
+
+	fatalIf(fmt.Errorf("The environment of '%s' would be %d bytes, more than the %d bytes the Cloud Controller accepts. Largest variables: %s. Use --force if the limit of this foundation is higher", c.appName, size, envSizeLimit, largestVariables(c.after, 3)))
+}
+
+// envSize is the size of env as sent to the Cloud Controller.
+func envSize(env map[string]interface{}) int {
+
+	encoded, err := json.Marshal(map[string]interface{}{"environment_json": env})
+	fatalIf(err)
+
+	return len(encoded)
+}
+
+// largestVariables lists the count largest variables of env with their size
+// as sent to the Cloud Controller.
+func largestVariables(env map[string]interface{}, count int) string {
+
+	type variable struct {
+		key  string
+		size int
+	}
+
+	variables := make([]variable, 0, len(env))
+	for key, value := range env {
+		encoded, _ := json.Marshal(value)
+		variables = append(variables, variable{key, len(key) + len(encoded)})
+	}
+
+	sort.Slice(variables, func(i, j int) bool {
+		if variables[i].size != variables[j].size {
+			return variables[i].size > variables[j].size
+		}
+		return variables[i].key < variables[j].key
+	})
+
+	if len(variables) > count {
+		variables = variables[:count]
+	}
+
+	formatted := make([]string, len(variables))
+	for i, variable := range variables {
+		formatted[i] = fmt.Sprintf("%s (%d bytes)", variable.key, variable.size)
+	}
+
+	return strings.Join(formatted, ", ")
+}
+
+// writeRequest prints the request sent by apply, with secret values masked.
+func (c envChangeSet) writeRequest(out io.Writer) {
+	fmt.Fprintf(out, "Would send PUT /v2/apps/%s (%s):\n", c.guid, c.appName)
+	writeJson(out, map[string]interface{}{"environment_json": redactEnv(c.after)})
+}
@@ -0,0 +1,185 @@
+// Command release cross-compiles the plugin for the platforms of a release
+// and writes what publishing it needs. Run it from the root of the
+// repository:
+//
+//	go run ./cmd/release -version 1.4.0
+//
+// dist/ then holds a binary per platform, named like
+// get-env-plugin-linux-amd64, the checksums.txt that
+// `cf get-env-plugin-update` verifies downloads against, and
+// repo-index.yml, the entry of the release in the CLI plugin repository.
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	binaryName = "get-env-plugin"
+	homepage   = "https://github.com/thomaseizinger/cf-get-env-plugin"
+)
+
+// target is a platform of a release and its name in the plugin repository.
+type target struct {
+	goos     string
+	goarch   string
+	platform string
+}
+
+var targets = []target{
+	{goos: "darwin", goarch: "amd64", platform: "osx"},
+	{goos: "darwin", goarch: "arm64", platform: "osx-arm64"},
+	{goos: "linux", goarch: "amd64", platform: "linux64"},
+	{goos: "linux", goarch: "arm64", platform: "linux-arm64"},
+	{goos: "windows", goarch: "amd64", platform: "win64"},
+	{goos: "windows", goarch: "arm64", platform: "win-arm64"},
+}
+
+func (t target) binary() string {
+
+	name := fmt.Sprintf("%s-%s-%s", binaryName, t.goos, t.goarch)
+
+	if t.goos == "windows" {
+		name += ".exe"
+	}
+
+	return name
+}
+
+// The entry of repo-index.yml, see https://github.com/cloudfoundry/cli-plugin-repo.
+type repoBinary struct {
+	Checksum string `yaml:"checksum"`
+	Platform string `yaml:"platform"`
+	Url      string `yaml:"url"`
+}
+
+type repoAuthor struct {
+	Name     string `yaml:"name"`
+	Homepage string `yaml:"homepage,omitempty"`
+}
+
+type repoPlugin struct {
+	Authors     []repoAuthor `yaml:"authors"`
+	Binaries    []repoBinary `yaml:"binaries"`
+	Description string       `yaml:"description"`
+	Homepage    string       `yaml:"homepage"`
+	Name        string       `yaml:"name"`
+	Updated     string       `yaml:"updated"`
+	Version     string       `yaml:"version"`
+}
+
+func main() {
+
+	var version, out, commit string
+
+	flag.StringVar(&version, "version", "", "Semantic version of the release, e.g. 1.4.0")
+	flag.StringVar(&out, "out", "dist", "Directory to write the binaries and files to")
+	flag.StringVar(&commit, "commit", "", "Commit embedded in the binaries (default the checked out one)")
+	flag.Parse()
+
+	version = strings.TrimPrefix(version, "v")
+
+	if version == "" {
+		fmt.Fprintln(os.Stderr, "-version must be given, e.g. -version 1.4.0")
+		os.Exit(2)
+	}
+
+	if commit == "" {
+		head, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output()
+		failIf(err)
+		commit = strings.TrimSpace(string(head))
+	}
+
+	failIf(os.MkdirAll(out, 0755))
+
+	entry := repoPlugin{
+		Authors:     []repoAuthor{{Name: "Thomas Eizinger", Homepage: "https://github.com/thomaseizinger"}},
+		Description: "Read, compare, export and audit the environment of apps",
+		Homepage:    homepage,
+		Name:        "Get-Env",
+		Updated:     time.Now().UTC().Format(time.RFC3339),
+		Version:     version,
+	}
+
+	var checksums strings.Builder
+
+	for _, t := range targets {
+		path := filepath.Join(out, t.binary())
+
+		fmt.Printf("Building %s\n", path)
+		failIf(build(t, path, version, commit))
+
+		sha1Sum, sha256Sum, err := sums(path)
+		failIf(err)
+
+		fmt.Fprintf(&checksums, "%s  %s\n", sha256Sum, t.binary())
+
+		entry.Binaries = append(entry.Binaries, repoBinary{
+			Checksum: sha1Sum,
+			Platform: t.platform,
+			Url:      fmt.Sprintf("%s/releases/download/v%s/%s", homepage, version, t.binary()),
+		})
+	}
+
+	failIf(ioutil.WriteFile(filepath.Join(out, "checksums.txt"), []byte(checksums.String()), 0644))
+
+	index, err := yaml.Marshal([]repoPlugin{entry})
+	failIf(err)
+	failIf(ioutil.WriteFile(filepath.Join(out, "repo-index.yml"), index, 0644))
+
+	fmt.Printf("Wrote %d binaries, checksums.txt and repo-index.yml to %s\n", len(targets), out)
+}
+
+// build compiles the plugin in the working directory for t, with the
+// version `cf plugins` and `cf get-env --version` report.
+func build(t target, path string, version string, commit string) error {
+
+	ldflags := fmt.Sprintf("-s -w -X main.version=%s -X main.commit=%s", version, commit)
+
+	command := exec.Command("go", "build", "-trimpath", "-ldflags", ldflags, "-o", path, ".")
+	command.Env = append(os.Environ(), "GOOS="+t.goos, "GOARCH="+t.goarch, "CGO_ENABLED=0")
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	return command.Run()
+}
+
+// sums returns the SHA-1 of the file, which the plugin repository checks,
+// and its SHA-256 for checksums.txt.
+func sums(path string) (string, string, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	sha1Hash, sha256Hash := sha1.New(), sha256.New()
+
+	if _, err := io.Copy(io.MultiWriter(sha1Hash, sha256Hash), file); err != nil {
+		return "", "", err
+	}
+
+	return hex.EncodeToString(sha1Hash.Sum(nil)), hex.EncodeToString(sha256Hash.Sum(nil)), nil
+}
+
+func failIf(err error) {
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
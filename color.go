@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// ANSI colors of the human output. colorDefault resets the foreground, for
+// table cells that need the same width as their colored neighbours.
+const (
+	colorRed     = "31"
+	colorGreen   = "32"
+	colorYellow  = "33"
+	colorDefault = "39"
+)
+
+// colorEnabled tells whether the output of the running command is colored,
+// see setupColor.
+var colorEnabled bool
+
+// setupColor decides whether the output of ui is colored. --no-color and
+// NO_COLOR (https://no-color.org) turn color off, CF_COLOR forces it on or
+// off like for cf itself, and otherwise only terminals get color.
+func setupColor(ui UI) {
+
+	enabled := outputIsTerminal(ui)
+
+	switch {
+	case globals.noColor, os.Getenv("NO_COLOR") != "":
+		enabled = false
+	case strings.EqualFold(os.Getenv("CF_COLOR"), "true"):
+		enabled = true
+	case strings.EqualFold(os.Getenv("CF_COLOR"), "false"):
+		enabled = false
+	}
+
+	colorEnabled = enabled
+}
+
+func paint(color string, text string) string {
+
+	if !colorEnabled {
+		return text
+	}
+
+	return "\x1b[" + color + "m" + text + "\x1b[0m"
+}
+
+func stateColor(state string) string {
+
+	switch strings.ToUpper(state) {
+	case "STARTED", "RUNNING":
+		return colorGreen
+	case "STOPPED", "CRASHED", "DOWN":
+		return colorRed
+	default:
+		return colorDefault
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+// Command is a command of the plugin. Run binds its flags with Flags, parses
+// them and executes it with the remaining positional arguments. Each
+// command has its own file, along with its help.
+type Command interface {
+	Name() string
+
+	// Usage returns the help of the command for `cf help`. GetMetadata adds
+	// the global options and the examples.
+	Usage() plugin.Command
+
+	// Flags returns a new flag set for the flags of the command, bound to
+	// its fields.
+	Flags() *flag.FlagSet
+
+	// Execute runs the command, printing through ui. ctx is done once the
+	// command is interrupted, for commands that call handleInterrupts.
+	Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string)
+}
+
+// commandLineTaker is a Command that runs a command line given after "--",
+// like run-with-env. The command line is split off before the flags are
+// parsed, so that its own flags are left alone.
+type commandLineTaker interface {
+	setCommandLine(command []string)
+}
+
+// argsRewriter is a Command that rewrites its arguments before the flags
+// are parsed, like env-validate does for the --schema KEY=FILE it had
+// before --json-schema.
+type argsRewriter interface {
+	rewriteArgs(ui UI, args []string) []string
+}
+
+// schemaFlag prints the JSON Schema of a command instead of running it. It
+// is parsed with the flags of the command, which the schema may depend on.
+const schemaFlag = "schema"
+
+// schemaDescriber is a Command with JSON output, whose schema --schema
+// prints. schema is called with the flags parsed and the positional
+// arguments, which pick the output of some commands.
+type schemaDescriber interface {
+	schema(args []string) formatters.Schema
+}
+
+// registry returns the commands of the plugin in the order of `cf help`.
+// get-env is the plugin itself, which holds its flags.
+func (p *GetEnvPlugin) registry() []Command {
+	return []Command{
+		p,
+		&copyEnvCommand{},
+		&serviceEnvCommand{},
+		&findEnvCommand{},
+		&envMatrixCommand{},
+		&envAuditCommand{},
+		&setEnvFileCommand{},
+		&envExportCommand{},
+		&envHashCommand{},
+		&envHistoryCommand{},
+		&envImportCommand{},
+		&envApplyCommand{},
+		&envValidateCommand{},
+		&certExpiryScanCommand{},
+		&envDriftCommand{},
+		&renderEnvCommand{},
+		&runWithEnvCommand{},
+		&getEnvGroupCommand{},
+		&setEnvGroupCommand{},
+		&unsetEnvMatchingCommand{},
+		&envSnapshotCommand{},
+		&envRestoreCommand{},
+		&diffEnvCommand{},
+		&listAppsCommand{},
+		&pluginUpdateCommand{},
+	}
+}
+
+// commands returns the commands of the plugin by name.
+func (p *GetEnvPlugin) commands() map[string]Command {
+
+	commands := map[string]Command{}
+
+	for _, command := range p.registry() {
+		commands[command.Name()] = command
+	}
+
+	return commands
+}
+
+// execute runs the command named by args[0] with the rest of args.
+func (p *GetEnvPlugin) execute(ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	command, ok := p.commands()[args[0]]
+	if !ok {
+		p.failUnknownCommand(ui, args[0])
+	}
+
+	args = args[1:]
+
+	if taker, ok := command.(commandLineTaker); ok {
+		var commandLine []string
+		args, commandLine = splitCommand(args)
+		taker.setCommandLine(commandLine)
+	}
+
+	if rewriter, ok := command.(argsRewriter); ok {
+		args = rewriter.rewriteArgs(ui, args)
+	}
+
+	flags := command.Flags()
+	flags.BoolVar(&globals.schema, schemaFlag, false, "")
+
+	positional, err := parseFlags(flags, args)
+	fatalIf(err)
+
+	if globals.schema {
+		printSchema(ui, command, positional)
+		return
+	}
+
+	command.Execute(interrupt, ui, cliConnection, positional)
+}
+
+// failUnknownCommand lists the commands with the first sentence of their
+// help, like `cf help -a` does, and exits with the usage exit code.
+func (p *GetEnvPlugin) failUnknownCommand(ui UI, name string) {
+
+	if globals.errorFormat == "json" {
+		failUsage("Unknown command '%s'", name)
+	}
+
+	fmt.Fprintf(ui.Out(), "Unknown command '%s'. The commands of the plugin are:\n\n", name)
+
+	table := tabwriter.NewWriter(ui.Out(), 0, 4, 3, ' ', 0)
+	for _, command := range p.GetMetadata().Commands {
+		if command.Name != completeCommand {
+			fmt.Fprintf(table, "   %s\t%s\n", command.Name, firstSentence(command.HelpText))
+		}
+	}
+	table.Flush()
+
+	fmt.Fprintln(ui.Out(), "\nSee `cf help COMMAND` for the usage and options of a command.")
+	exit(exitUsage)
+}
+
+// printSchema prints the JSON Schema of the JSON output of command.
+func printSchema(ui UI, command Command, args []string) {
+
+	describer, ok := command.(schemaDescriber)
+	if !ok {
+		failUsage("%s has no JSON output", command.Name())
+	}
+
+	writeJson(ui.Out(), describer.schema(args).JSONSchema())
+}
+
+// sentenceEnd ends a sentence, unlike the dot of "e.g. {{.DB_HOST}}".
+var sentenceEnd = regexp.MustCompile(`\.\s+[A-Z]`)
+
+func firstSentence(text string) string {
+
+	if end := sentenceEnd.FindStringIndex(text); end != nil {
+		return text[:end[0]]
+	}
+
+	return strings.TrimSuffix(text, ".")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"flag"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"reflect"
+	"strings"
+)
+
+var _ = Describe("Commands", func() {
+
+	It("registers every command under the name of its help", func() {
+		for name, command := range new(GetEnvPlugin).commands() {
+			Expect(command.Usage().Name).To(Equal(name))
+		}
+	})
+
+	It("documents every flag of a command in its help", func() {
+		for _, command := range new(GetEnvPlugin).registry() {
+			usage := command.Usage()
+
+			command.Flags().VisitAll(func(f *flag.Flag) {
+				Expect(documents(usage, f.Name)).To(BeTrue(), "--%s of %s", f.Name, command.Name())
+			})
+		}
+	})
+
+	It("lists the commands of the registry in the metadata, followed by __complete", func() {
+		p := new(GetEnvPlugin)
+		commands := p.GetMetadata().Commands
+		registry := p.registry()
+
+		Expect(commands).To(HaveLen(len(registry) + 1))
+		for i, command := range registry {
+			Expect(commands[i].Name).To(Equal(command.Name()))
+		}
+		Expect(commands[len(registry)].Name).To(Equal(completeCommand))
+	})
+
+	It("gives the JSON results of different shapes different schema names", func() {
+		described := map[string]reflect.Type{}
+
+		for _, command := range new(GetEnvPlugin).registry() {
+			describer, ok := command.(schemaDescriber)
+			if !ok {
+				continue
+			}
+
+			// Boolean flags and more app names pick other results, like
+			// get-env --inspect-certs or list-apps --summary do.
+			for _, name := range append([]string{""}, boolFlags(command.Flags())...) {
+				for _, args := range [][]string{nil, {"app1", "app2"}} {
+					flags := command.Flags()
+					if name != "" {
+						Expect(flags.Set(name, "true")).To(Succeed())
+					}
+
+					schema := describer.schema(args)
+					results := reflect.TypeOf(schema.Results)
+
+					if known, ok := described[schema.Name]; ok {
+						Expect(results).To(Equal(known), "%s of %s --%s", schema.Name, command.Name(), name)
+					}
+					described[schema.Name] = results
+				}
+			}
+		}
+	})
+})
+
+func boolFlags(flags *flag.FlagSet) []string {
+
+	var names []string
+
+	flags.VisitAll(func(f *flag.Flag) {
+		if value, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && value.IsBoolFlag() {
+			names = append(names, f.Name)
+		}
+	})
+
+	return names
+}
+
+// documents tells whether usage has an option for the flag name, or
+// mentions it, like the --force alias of -f.
+func documents(usage plugin.Command, name string) bool {
+
+	if _, ok := usage.UsageDetails.Options[name]; ok {
+		return true
+	}
+
+	if strings.Contains(usage.UsageDetails.Usage, "--"+name) {
+		return true
+	}
+
+	for _, option := range usage.UsageDetails.Options {
+		if strings.Contains(option, "--"+name) {
+			return true
+		}
+	}
+
+	return false
+}
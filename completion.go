@@ -0,0 +1,306 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"io"
+	"sort"
+	"strings"
+)
+
+const completeCommand = "__complete"
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionScripts hand the words typed after `cf` to `cf __complete` when
+// they start with a command of this plugin, and leave other commands to
+// the completion the shell already had for cf.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for the cf get-env plugin
+# Install with: source <(cf get-env --completion bash)
+__cf_get_env_previous="$(complete -p cf 2>/dev/null | sed -n 's/.*-F \([^ ]*\) .*/\1/p')"
+
+__cf_get_env_complete() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    if [ "$COMP_CWORD" -gt 1 ] && cf __complete commands | grep -qx -- "${COMP_WORDS[1]}"; then
+        local IFS=$'\n'
+        COMPREPLY=($(cf __complete "${COMP_WORDS[@]:1:COMP_CWORD}"))
+        return
+    fi
+    if [ -n "$__cf_get_env_previous" ]; then
+        "$__cf_get_env_previous" "$@"
+        return
+    fi
+    if [ "$COMP_CWORD" -eq 1 ]; then
+        COMPREPLY=($(compgen -W "$(cf __complete commands)" -- "$cur"))
+    fi
+}
+
+complete -o default -F __cf_get_env_complete cf
+`,
+	"zsh": `#compdef cf
+# zsh completion for the cf get-env plugin
+# Install with: source <(cf get-env --completion zsh)
+__cf_get_env_complete() {
+    local -a candidates
+    if (( CURRENT > 2 )) && cf __complete commands | grep -qx -- "${words[2]}"; then
+        candidates=(${(f)"$(cf __complete "${(@)words[2,CURRENT]}")"})
+        if (( ${#candidates} )); then
+            compadd -a candidates
+        else
+            _files
+        fi
+        return
+    fi
+    if (( CURRENT == 2 )); then
+        candidates=(${(f)"$(cf __complete commands)"})
+        compadd -a candidates
+        return
+    fi
+    _files
+}
+
+compdef __cf_get_env_complete cf
+`,
+	"fish": `# fish completion for the cf get-env plugin
+# Install with: cf get-env --completion fish | source
+function __cf_get_env_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    cf __complete $tokens[2..-1]
+end
+
+complete -c cf -n '__fish_seen_subcommand_from (cf __complete commands)' -f -a '(__cf_get_env_complete)'
+complete -c cf -n '__fish_use_subcommand' -f -a '(cf __complete commands)'
+`,
+}
+
+func writeCompletion(out io.Writer, shell string) error {
+
+	script, ok := completionScripts[shell]
+
+	if !ok {
+		return usageErrorf("Unsupported shell '%s'. Supported shells: %s", shell, strings.Join(completionShells, ", "))
+	}
+
+	_, err := io.WriteString(out, script)
+	return err
+}
+
+// commandSpec describes the arguments of a command as documented by its
+// usage, so completion can never disagree with the help of the command.
+type commandSpec struct {
+	flags       map[string]flagSpec
+	positionals []string
+}
+
+type flagSpec struct {
+	takesValue bool
+	values     []string
+}
+
+// parseUsage reads a usage like `cf get-env APP_NAME [--format table|json]`.
+// A flag followed by a word takes a value; a word of lowercase alternatives
+// lists the accepted values. Options which are documented but not part of
+// the usage are the global options.
+func parseUsage(usage plugin.Usage) commandSpec {
+
+	spec := commandSpec{flags: map[string]flagSpec{}}
+	global := globalFlagSet()
+
+	for name := range usage.Options {
+		spec.flags[name] = flagSpec{takesValue: true}
+
+		if flag := global.Lookup(name); (flag != nil && isBoolFlag(flag)) || name == schemaFlag {
+			spec.flags[name] = flagSpec{}
+		}
+	}
+
+	words := strings.Fields(strings.NewReplacer("[", " ", "]", " ", "(", " ", ")", " ").Replace(usageLine(usage)))
+
+	for i := 2; i < len(words); i++ {
+		word := words[i]
+
+		switch {
+		case word == "--":
+			return spec
+		case word == "|":
+			continue
+		case strings.HasPrefix(word, "-"):
+			flag := flagSpec{}
+
+			if i+1 < len(words) && words[i+1] != "|" && !strings.HasPrefix(words[i+1], "-") {
+				i++
+				flag.takesValue = true
+				flag.values = alternatives(words[i])
+			}
+
+			spec.flags[strings.TrimLeft(word, "-")] = flag
+		default:
+			spec.positionals = append(spec.positionals, word)
+		}
+	}
+
+	return spec
+}
+
+// alternatives splits `table|json` into its values. Placeholders like FILE
+// or 30s stand for any value and have none.
+func alternatives(word string) []string {
+
+	if !strings.Contains(word, "|") || strings.ToLower(word) != word {
+		return nil
+	}
+
+	return strings.Split(word, "|")
+}
+
+func (s commandSpec) flagNames() []string {
+
+	names := make([]string, 0, len(s.flags))
+
+	for name := range s.flags {
+		if len(name) == 1 {
+			names = append(names, "-"+name)
+		} else {
+			names = append(names, "--"+name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// candidates returns the completions of the last of args, which are the
+// words typed after the command name.
+func (s commandSpec) candidates(cliConnection plugin.CliConnection, args []string) []string {
+
+	current, args := args[len(args)-1], args[:len(args)-1]
+
+	if strings.HasPrefix(current, "-") {
+		return withPrefix(s.flagNames(), current)
+	}
+
+	position := 0
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			return nil
+		}
+
+		if !strings.HasPrefix(arg, "-") {
+			position++
+			continue
+		}
+
+		flag, known := s.flags[strings.TrimLeft(arg, "-")]
+
+		if !known || !flag.takesValue || strings.Contains(arg, "=") {
+			continue
+		}
+
+		if i+1 == len(args) {
+			return withPrefix(flag.values, current)
+		}
+
+		i++
+	}
+
+	if position >= len(s.positionals) {
+		return nil
+	}
+
+	positional := s.positionals[position]
+
+	if strings.Contains(positional, "APP") {
+		return withPrefix(appNames(cliConnection), current)
+	}
+
+	return withPrefix(alternatives(positional), current)
+}
+
+func withPrefix(candidates []string, prefix string) []string {
+
+	var matching []string
+
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matching = append(matching, candidate)
+		}
+	}
+
+	return matching
+}
+
+// appNames lists the apps of the targeted space. Completion must not print
+// errors into the command line, so failures complete nothing.
+func appNames(cliConnection plugin.CliConnection) []string {
+
+	space, err := cliConnection.GetCurrentSpace()
+
+	if err != nil || space.Guid == "" {
+		return nil
+	}
+
+	apps, err := ccClient(cliConnection).ListApps(ccclient.AppFilters{SpaceGuid: space.Guid})
+
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(apps))
+	for _, app := range apps {
+		names = append(names, app.Entity.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// completeUsage is the help of completeCommand, which isn't a Command since
+// it runs before the global flags are parsed.
+var completeUsage = plugin.Command{
+	Name:     completeCommand,
+	HelpText: "Print completions of a command line of this plugin. Used by the scripts of `cf get-env --completion`.",
+	UsageDetails: plugin.Usage{
+		Usage: "cf __complete (commands | COMMAND [ARGS...] CURRENT)",
+	},
+}
+
+// completeArgsCommand prints one completion per line for
+// `cf __complete COMMAND [ARGS...] CURRENT`, or the commands of the plugin
+// for `cf __complete commands`.
+func (p *GetEnvPlugin) completeArgsCommand(ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	commands := map[string]plugin.Command{}
+	var names []string
+
+	for _, command := range p.GetMetadata().Commands {
+		if command.Name != completeCommand {
+			commands[command.Name] = command
+			names = append(names, command.Name)
+		}
+	}
+
+	if len(args) == 1 && args[0] == "commands" {
+		sort.Strings(names)
+		fmt.Fprintln(ui.Out(), strings.Join(names, "\n"))
+		return
+	}
+
+	if len(args) < 2 {
+		return
+	}
+
+	command, ok := commands[args[0]]
+
+	if !ok {
+		exit(exitUsage)
+	}
+
+	for _, candidate := range parseUsage(command.UsageDetails).candidates(cliConnection, args[1:]) {
+		fmt.Fprintln(ui.Out(), candidate)
+	}
+}
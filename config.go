@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The plugin reads defaults for its flags from ~/.cf/plugins/get-env.yml,
+// keyed by flag name:
+//
+//	redact: true
+//	timeout: 30s
+//	list-apps:
+//	  output: json
+//
+// Top-level values apply to every command with a flag of that name, values
+// under a command name only to that command. CF_GET_ENV_<FLAG> environment
+// variables, e.g. CF_GET_ENV_TIMEOUT, override the file, and flags given on
+// the command line override both.
+const configEnvPrefix = "CF_GET_ENV_"
+
+var loadedConfig map[string]interface{}
+
+// cfDir is where the CLI keeps its files, $CF_HOME/.cf.
+func cfDir() string {
+
+	home := os.Getenv("CF_HOME")
+	if home == "" {
+		home, _ = os.UserHomeDir()
+	}
+
+	return filepath.Join(home, ".cf")
+}
+
+func configPath() string {
+	return filepath.Join(cfDir(), "plugins", "get-env.yml")
+}
+
+func pluginConfig() (map[string]interface{}, error) {
+
+	if loadedConfig != nil {
+		return loadedConfig, nil
+	}
+
+	data, err := ioutil.ReadFile(configPath())
+
+	if os.IsNotExist(err) {
+		loadedConfig = map[string]interface{}{}
+		return loadedConfig, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var config map[string]interface{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, usageErrorf("Failed to parse %s: %s", configPath(), err)
+	}
+
+	loadedConfig = map[string]interface{}{}
+	for key, value := range config {
+		loadedConfig[key] = jsonCompatible(value)
+	}
+
+	return loadedConfig, nil
+}
+
+// configEnvName returns the environment variable overriding flag, e.g.
+// CF_GET_ENV_RETRY_DELAY for --retry-delay.
+func configEnvName(flag string) string {
+	return configEnvPrefix + strings.ToUpper(strings.Replace(flag, "-", "_", -1))
+}
+
+// applyConfig sets the defaults of the config file and environment on flags.
+// It runs before parsing, so that flags on the command line override them.
+func applyConfig(flags *flag.FlagSet) error {
+
+	config, err := pluginConfig()
+
+	if err != nil {
+		return err
+	}
+
+	values := map[string]string{}
+	sources := map[string]string{}
+
+	for key, value := range config {
+		if _, isCommand := value.(map[string]interface{}); !isCommand {
+			values[key], sources[key] = fmt.Sprint(value), configPath()
+		}
+	}
+
+	if command, ok := config[flags.Name()].(map[string]interface{}); ok {
+		for key, value := range command {
+			values[key], sources[key] = fmt.Sprint(value), configPath()
+		}
+	}
+
+	flags.VisitAll(func(f *flag.Flag) {
+		if value, ok := os.LookupEnv(configEnvName(f.Name)); ok {
+			values[f.Name], sources[f.Name] = value, configEnvName(f.Name)
+		}
+	})
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if flags.Lookup(name) == nil {
+			continue
+		}
+
+		if err := flags.Set(name, values[name]); err != nil {
+			return usageErrorf("Invalid value '%s' for %s in %s: %s", values[name], name, sources[name], err)
+		}
+	}
+
+	return nil
+}
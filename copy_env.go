@@ -0,0 +1,113 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"reflect"
+	"strings"
+)
+
+type copyEnvCommand struct {
+	overwrite bool
+	exclude   string
+	force     bool
+	restart   *restartOptions
+	history   *historyOptions
+}
+
+func (c *copyEnvCommand) Name() string {
+	return "copy-env"
+}
+
+func (c *copyEnvCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "copy-env",
+		HelpText: "Copy the user-provided environment variables of one app to another.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf copy-env SOURCE_APP TARGET_APP [--overwrite] [--exclude KEY,...] [-f] [--dry-run] [--restart | --restage] [--track-history]",
+			Options: map[string]string{
+				"restart":       "Restart the target app after copying",
+				"restage":       "Restage the target app after copying",
+				"overwrite":     "Replace variables that are already set on the target app",
+				"exclude":       "Comma-separated variables not to copy",
+				"f":             "Update the target app even if its env exceeds the size the Cloud Controller accepts by default. --force is an alias",
+				"track-history": "Record the names of the changed variables in the history of the app, see env-history",
+			},
+		},
+	}
+}
+
+func (c *copyEnvCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("copy-env")
+	flags.BoolVar(&c.overwrite, "overwrite", false, "")
+	flags.StringVar(&c.exclude, "exclude", "", "")
+	flags.BoolVar(&c.force, "force", false, "")
+	flags.BoolVar(&c.force, "f", false, "")
+	c.restart = addRestartFlags(flags)
+	c.history = addHistoryFlags(flags)
+
+	return flags
+}
+
+func (c *copyEnvCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 2 {
+		failUsage("Source and target app names must be provided")
+	}
+
+	sourceName, targetName := args[0], args[1]
+
+	source := userProvidedEnv(fetchEnv(cliConnection, sourceName))
+	target := resolveApp(cliConnection, targetName)
+	targetEnv := userProvidedEnv(fetchEnvByGuid(cliConnection, targetName, target.Guid))
+
+	excluded := map[string]bool{}
+	for _, key := range strings.Split(c.exclude, ",") {
+		excluded[strings.TrimSpace(key)] = true
+	}
+
+	merged := make(map[string]interface{}, len(targetEnv))
+	for key, value := range targetEnv {
+		merged[key] = value
+	}
+
+	changed := false
+
+	for _, key := range sortedKeys(source) {
+		current, exists := targetEnv[key]
+
+		switch {
+		case excluded[key]:
+			ui.Say("  %s (excluded)", key)
+		case !exists:
+			ui.Say("+ %s", key)
+			merged[key] = source[key]
+			changed = true
+		case reflect.DeepEqual(current, source[key]):
+			ui.Say("  %s (unchanged)", key)
+		case c.overwrite:
+			ui.Say("~ %s", paint(colorYellow, key))
+			merged[key] = source[key]
+			changed = true
+		default:
+			ui.Say("  %s (already set, use --overwrite to replace)", key)
+		}
+	}
+
+	if !changed {
+		return
+	}
+
+	change := envChangeSet{appName: targetName, guid: target.Guid, before: targetEnv, after: merged, force: c.force}
+	if !change.apply(ui, cliConnection) {
+		return
+	}
+
+	c.history.record(ui, cliConnection, targetName, target.Guid, "copy-env", targetEnv, merged)
+
+	ui.Say("Copied environment from '%s' to '%s'", sourceName, targetName)
+
+	c.restart.apply(ui, cliConnection, targetName, target.Guid)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"regexp"
+)
+
+// credHubInterpolation matches a CredHub reference in a value, like
+// ((/team/db-password)).
+var credHubInterpolation = regexp.MustCompile(`\(\((/?[^()\s]+)\)\)`)
+
+// credHubResolver replaces CredHub references with the credentials they
+// refer to. Unless reveal is set, the credentials are masked, so that only
+// their presence can be checked.
+type credHubResolver struct {
+	credHub *ccclient.CredHub
+	reveal  bool
+	values  map[string]interface{}
+}
+
+// resolveCredHubRefs resolves the ((name)) references and the
+// {"credhub-ref": name} objects of service credentials in env.
+func resolveCredHubRefs(cliConnection plugin.CliConnection, env map[string]interface{}, reveal bool) map[string]interface{} {
+
+	endpoint, err := ccClient(cliConnection).CredHubEndpoint()
+	fatalIf(err)
+
+	resolver := &credHubResolver{
+		credHub: ccclient.NewCredHub(cliConnection, endpoint, globals.timeout),
+		reveal:  reveal,
+		values:  map[string]interface{}{},
+	}
+
+	resolved, err := resolver.resolve(env)
+	fatalIf(err)
+
+	return resolved.(map[string]interface{})
+}
+
+func (r *credHubResolver) resolve(value interface{}) (interface{}, error) {
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		if name, ok := typed["credhub-ref"].(string); ok && len(typed) == 1 {
+			return r.credential(name)
+		}
+
+		resolved := make(map[string]interface{}, len(typed))
+		for key, nested := range typed {
+			item, err := r.resolve(nested)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = item
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, len(typed))
+		for i, nested := range typed {
+			item, err := r.resolve(nested)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = item
+		}
+		return resolved, nil
+	case string:
+		return r.interpolate(typed)
+	default:
+		return value, nil
+	}
+}
+
+// interpolate resolves the references in value. A value that is a single
+// reference takes the type of the credential, otherwise credentials are
+// formatted into the string.
+func (r *credHubResolver) interpolate(value string) (interface{}, error) {
+
+	if match := credHubInterpolation.FindStringSubmatch(value); match != nil && match[0] == value {
+		return r.credential(match[1])
+	}
+
+	var err error
+
+	interpolated := credHubInterpolation.ReplaceAllStringFunc(value, func(reference string) string {
+		credential, lookupErr := r.credential(credHubInterpolation.FindStringSubmatch(reference)[1])
+		if lookupErr != nil {
+			err = lookupErr
+			return reference
+		}
+		return formatEnvValue(credential)
+	})
+
+	return interpolated, err
+}
+
+func (r *credHubResolver) credential(name string) (interface{}, error) {
+
+	if value, ok := r.values[name]; ok {
+		return value, nil
+	}
+
+	value, err := r.credHub.Get(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !r.reveal {
+		value = redactValue(value, true)
+	}
+
+	r.values[name] = value
+	return value, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// envDiff lists the keys that differ between two environments, sorted.
+type envDiff struct {
+	OnlyInA []string
+	OnlyInB []string
+	Changed []string
+}
+
+func (d envDiff) empty() bool {
+	return len(d.OnlyInA) == 0 && len(d.OnlyInB) == 0 && len(d.Changed) == 0
+}
+
+func diffEnv(a, b map[string]interface{}) envDiff {
+
+	var diff envDiff
+
+	for key, valueA := range a {
+		valueB, ok := b[key]
+
+		switch {
+		case !ok:
+			diff.OnlyInA = append(diff.OnlyInA, key)
+		case !reflect.DeepEqual(valueA, valueB):
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			diff.OnlyInB = append(diff.OnlyInB, key)
+		}
+	}
+
+	sort.Strings(diff.OnlyInA)
+	sort.Strings(diff.OnlyInB)
+	sort.Strings(diff.Changed)
+
+	return diff
+}
+
+type diffEnvCommand struct {
+	showValues bool
+	targetA    string
+	targetB    string
+	tokenA     string
+	tokenB     string
+}
+
+func (c *diffEnvCommand) Name() string {
+	return "diff-env"
+}
+
+func (c *diffEnvCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "diff-env",
+		HelpText: "Compare the user-provided environment of two apps, or of an app on two foundations. A foundation is reached with the login of a target saved by the cf-targets plugin or of the CLI config, or with a token.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf diff-env APP_A [APP_B] [--target-a API|TARGET [--token-a TOKEN]] [--target-b API|TARGET [--token-b TOKEN]] [--show-values]",
+			Options: map[string]string{
+				"show-values": "Print the values of differing variables",
+				"target-a":    "API endpoint or saved target of the first app (default the targeted foundation)",
+				"target-b":    "API endpoint or saved target of the second app (default the targeted foundation)",
+				"token-a":     "Access token for --target-a, e.g. from `cf oauth-token`. CF_GET_ENV_TOKEN_A keeps it out of the command line",
+				"token-b":     "Access token for --target-b. CF_GET_ENV_TOKEN_B keeps it out of the command line",
+			},
+		},
+	}
+}
+
+func (c *diffEnvCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("diff-env")
+	flags.BoolVar(&c.showValues, "show-values", false, "")
+	flags.StringVar(&c.targetA, "target-a", "", "")
+	flags.StringVar(&c.targetB, "target-b", "", "")
+	flags.StringVar(&c.tokenA, "token-a", "", "")
+	flags.StringVar(&c.tokenB, "token-b", "", "")
+
+	return flags
+}
+
+func (c *diffEnvCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if c.targetA != "" || c.targetB != "" {
+		diffFoundationsCommand(ui, cliConnection, args, c.targetA, c.tokenA, c.targetB, c.tokenB, c.showValues)
+		return
+	}
+
+	if len(args) != 2 {
+		failUsage("Two app names must be provided")
+	}
+
+	nameA, nameB := args[0], args[1]
+
+	envA := userProvidedEnv(fetchEnv(cliConnection, nameA))
+	envB := userProvidedEnv(fetchEnv(cliConnection, nameB))
+
+	writeEnvDiff(ui.Out(), nameA, nameB, envA, envB, c.showValues)
+}
+
+// diffFoundationsCommand compares an app across foundations, e.g. prod
+// and staging. Without a second name, the app has the same name on both.
+// A foundation without --target-* is the targeted one.
+func diffFoundationsCommand(ui UI, cliConnection plugin.CliConnection, positional []string, targetA, tokenA, targetB, tokenB string, showValues bool) {
+
+	if len(positional) < 1 || len(positional) > 2 {
+		failUsage("The app name, or its names on either foundation, must be provided")
+	}
+
+	nameA, nameB := positional[0], positional[len(positional)-1]
+
+	foundationA, err := openFoundation(cliConnection, targetA, tokenA, "--token-a")
+	fatalIf(err)
+
+	foundationB, err := openFoundation(cliConnection, targetB, tokenB, "--token-b")
+	fatalIf(err)
+
+	envA, err := foundationA.appEnv(nameA)
+	fatalIf(err)
+
+	envB, err := foundationB.appEnv(nameB)
+	fatalIf(err)
+
+	labelA := fmt.Sprintf("%s (%s)", nameA, foundationA.name)
+	labelB := fmt.Sprintf("%s (%s)", nameB, foundationB.name)
+
+	writeEnvDiff(ui.Out(), labelA, labelB, envA, envB, showValues)
+}
+
+func writeEnvDiff(out io.Writer, nameA, nameB string, envA, envB map[string]interface{}, showValues bool) {
+
+	diff := diffEnv(envA, envB)
+
+	if diff.empty() {
+		fmt.Fprintf(out, "No differences between '%s' and '%s'\n", nameA, nameB)
+		return
+	}
+
+	value := func(env map[string]interface{}, key string) string {
+		if showValues {
+			return " = " + formatEnvValue(env[key])
+		}
+		return ""
+	}
+
+	if len(diff.OnlyInA) > 0 {
+		fmt.Fprintf(out, "Only in %s:\n", nameA)
+		for _, key := range diff.OnlyInA {
+			fmt.Fprintf(out, "  %s%s\n", key, value(envA, key))
+		}
+	}
+
+	if len(diff.OnlyInB) > 0 {
+		fmt.Fprintf(out, "Only in %s:\n", nameB)
+		for _, key := range diff.OnlyInB {
+			fmt.Fprintf(out, "  %s%s\n", key, value(envB, key))
+		}
+	}
+
+	if len(diff.Changed) > 0 {
+		fmt.Fprintln(out, "Different values:")
+		for _, key := range diff.Changed {
+			if showValues {
+				fmt.Fprintf(out, "  %s\n    %s: %s\n    %s: %s\n", paint(colorYellow, key), nameA, formatEnvValue(envA[key]), nameB, formatEnvValue(envB[key]))
+			} else {
+				fmt.Fprintf(out, "  %s\n", paint(colorYellow, key))
+			}
+		}
+	}
+}
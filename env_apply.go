@@ -0,0 +1,208 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"regexp"
+	"sort"
+)
+
+// envPlanModel is the plan of `cf env-apply`, the desired env of every app:
+//
+//	apps:
+//	  web:
+//	    set:
+//	      LOG_LEVEL: info
+//	    unset: [DEBUG]
+//	    ensure-absent: ['^LEGACY_']
+//
+// Variables the plan doesn't mention are left alone, so that applying a
+// plan twice changes nothing.
+type envPlanModel struct {
+	Apps map[string]envPlanApp `yaml:"apps"`
+}
+
+// envPlanApp sets variables, removes the variables named by unset and those
+// matching a regular expression of ensure-absent.
+type envPlanApp struct {
+	Set          map[string]interface{} `yaml:"set"`
+	Unset        []string               `yaml:"unset"`
+	EnsureAbsent []string               `yaml:"ensure-absent"`
+
+	absent []*regexp.Regexp
+}
+
+// plannedApp is the update of an app of the plan.
+type plannedApp struct {
+	name    string
+	guid    string
+	change  envChangeSet
+	changes envChanges
+}
+
+func readEnvPlan(path string) (envPlanModel, error) {
+
+	var plan envPlanModel
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return plan, err
+	}
+
+	if err := yaml.UnmarshalStrict(data, &plan); err != nil {
+		return plan, fmt.Errorf("Failed to parse plan '%s': %s", path, err)
+	}
+
+	for name, app := range plan.Apps {
+		for _, key := range app.Unset {
+			if _, ok := app.Set[key]; ok {
+				return plan, usageErrorf("Plan of '%s' both sets and unsets %s", name, key)
+			}
+		}
+
+		for _, expression := range app.EnsureAbsent {
+			pattern, err := regexp.Compile(expression)
+			if err != nil {
+				return plan, usageErrorf("Invalid ensure-absent pattern of '%s': %s", name, err)
+			}
+			app.absent = append(app.absent, pattern)
+		}
+
+		plan.Apps[name] = app
+	}
+
+	return plan, nil
+}
+
+// desiredEnv applies the plan of an app to its current env.
+func (app envPlanApp) desiredEnv(current map[string]interface{}) (map[string]interface{}, envChanges) {
+
+	removed := map[string]bool{}
+	for _, key := range app.Unset {
+		removed[key] = true
+	}
+
+	kept := map[string]interface{}{}
+	for key, value := range current {
+		absent := removed[key]
+		for _, pattern := range app.absent {
+			absent = absent || pattern.MatchString(key)
+		}
+
+		if !absent {
+			kept[key] = value
+		}
+	}
+
+	vars := make(map[string]string, len(app.Set))
+	for key, value := range app.Set {
+		vars[key] = formatEnvValue(jsonCompatible(value))
+	}
+
+	desired, changes := mergeEnv(kept, vars, false)
+	changes.removed = len(current) - len(kept)
+
+	return desired, changes
+}
+
+type envApplyCommand struct {
+	force   bool
+	restart *restartOptions
+	history *historyOptions
+}
+
+func (c *envApplyCommand) Name() string {
+	return "env-apply"
+}
+
+func (c *envApplyCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "env-apply",
+		HelpText: "Bring the user-provided environment variables of several apps to the state declared in a YAML plan, listing the changes first. The plan maps app names under `apps` to the variables to `set`, the names to `unset` and the patterns of variables to `ensure-absent`. Variables the plan doesn't mention are left alone.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf env-apply PLAN [-f] [--dry-run] [--restart | --restage] [--track-history]",
+			Options: map[string]string{
+				"f":             "Apply the changes without confirmation, even if an env exceeds the size the Cloud Controller accepts by default. --force is an alias",
+				"restart":       "Restart the changed apps",
+				"restage":       "Restage the changed apps",
+				"track-history": "Record the names of the changed variables in the history of the apps, see env-history",
+			},
+		},
+	}
+}
+
+func (c *envApplyCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("env-apply")
+	flags.BoolVar(&c.force, "force", false, "")
+	flags.BoolVar(&c.force, "f", false, "")
+	c.restart = addRestartFlags(flags)
+	c.history = addHistoryFlags(flags)
+
+	return flags
+}
+
+func (c *envApplyCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 1 {
+		failUsage("Plan file must be provided")
+	}
+
+	plan, err := readEnvPlan(args[0])
+	fatalIf(err)
+
+	names := make([]string, 0, len(plan.Apps))
+	for name := range plan.Apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var changing []plannedApp
+
+	for _, name := range names {
+		app := resolveApp(cliConnection, name)
+		current := userProvidedEnv(fetchEnvByGuid(cliConnection, name, app.Guid))
+		desired, changes := plan.Apps[name].desiredEnv(current)
+
+		if !changes.any() {
+			ui.Say("%s: up to date", name)
+			continue
+		}
+
+		change := envChangeSet{appName: name, guid: app.Guid, before: current, after: desired, force: c.force}
+
+		ui.Say("%s:", name)
+		change.writePreview(ui.Out())
+
+		changing = append(changing, plannedApp{change: change, changes: changes})
+	}
+
+	if len(changing) == 0 {
+		return
+	}
+
+	if !c.force && !globals.dryRun && !confirm(ui, fmt.Sprintf("Apply the changes to %d apps?", len(changing))) {
+		ui.Say("Nothing applied")
+		return
+	}
+
+	for _, app := range changing {
+		change := app.change
+
+		if !change.apply(ui, cliConnection) {
+			continue
+		}
+
+		c.history.record(ui, cliConnection, change.appName, change.guid, "env-apply", change.before, change.after)
+
+		fmt.Fprintf(ui.Out(), "Applied the plan to '%s': ", change.appName)
+		app.changes.print(ui.Out(), true)
+
+		c.restart.apply(ui, cliConnection, change.appName, change.guid)
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Severities of audit findings, from the most to the least severe.
+var auditSeverities = []string{"high", "medium", "low"}
+
+// auditChecks are the checks of env-audit, with their severity and whether
+// they find secrets.
+var auditChecks = []struct {
+	check    string
+	severity string
+	secret   bool
+}{
+	{"shared-secret", "high", true},
+	{"plaintext-credential", "medium", true},
+	{"empty-value", "low", false},
+}
+
+// auditFinding is a problem found by env-audit. It never contains values,
+// so that the report can be shared.
+type auditFinding struct {
+	Severity string   `json:"severity"`
+	Check    string   `json:"check"`
+	Key      string   `json:"key"`
+	Apps     []string `json:"apps"`
+	Message  string   `json:"message"`
+}
+
+// findingsSchema is the schema of the JSON output of env-audit.
+var findingsSchema = formatters.Schema{Name: "findings", Version: 1, Results: []auditFinding{}}
+
+// plaintextCredentials match values that are credentials whatever the name
+// of their key.
+var plaintextCredentials = []struct {
+	pattern *regexp.Regexp
+	kind    string
+}{
+	{regexp.MustCompile(`^[a-z][a-z0-9+.-]*://[^/:@\s]+:[^/@\s]+@`), "URL with a password"},
+	{regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`), "private key"},
+	{regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`), "AWS access key"},
+	{regexp.MustCompile(`^eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+$`), "JSON web token"},
+}
+
+type envAuditCommand struct {
+	concurrency int
+	format      string
+	failOn      string
+	process     string
+	notify      notifier
+	tmpl        listTemplate
+	failures    *scanFailures
+}
+
+func (c *envAuditCommand) Name() string {
+	return "env-audit"
+}
+
+func (c *envAuditCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "env-audit",
+		HelpText: "Check the user-provided environment variables of all apps in the targeted space for secrets shared between apps, credentials in variables that aren't redacted and empty values. The report never contains values.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf env-audit [--concurrency N] [--format table|json|ndjson|prometheus|template [--template TEMPLATE | --template-file FILE]] [--fail-on high|medium|low] [--process TYPE] [--notify-url URL] [--notify-slack URL] [--notify-template TEMPLATE] [--continue-on-error=false]",
+			Options: map[string]string{
+				"concurrency":       "Number of apps fetched at the same time (default 4)",
+				"format":            "Output format (default table)",
+				"fail-on":           "Exit with an error for findings of this severity or a higher one",
+				"process":           "Only audit apps running a process of TYPE, e.g. worker",
+				"notify-url":        "POST the findings as JSON to the webhook URL when there are any",
+				"notify-slack":      "Post a message to the Slack incoming webhook URL when there are findings",
+				"notify-template":   "Go text/template of the message, with the fields of the JSON payload, e.g. '{{len .Findings}} problems in {{.Space}}'",
+				"template":          "Go text/template printed for every finding with --format template, e.g. '{{.Severity}} {{.Key}}: {{join .Apps \",\"}}'",
+				"template-file":     "Read the template of --format template from FILE",
+				"continue-on-error": "Keep scanning when the env of an app can't be fetched, and list those apps at the end, exiting with 6 if others were scanned (default true)",
+			},
+		},
+	}
+}
+
+func (c *envAuditCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("env-audit")
+	flags.IntVar(&c.concurrency, "concurrency", 4, "")
+	flags.StringVar(&c.format, "format", formatTable, "")
+	flags.StringVar(&c.failOn, "fail-on", "", "")
+	flags.StringVar(&c.process, "process", "", "")
+	c.notify.register(flags)
+	c.tmpl.register(flags)
+	c.failures = addScanFailureFlags(flags)
+
+	return flags
+}
+
+func (c *envAuditCommand) schema(args []string) formatters.Schema {
+	return findingsSchema
+}
+
+func (c *envAuditCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	fatalIf(c.notify.validate())
+
+	if len(args) != 0 {
+		failUsage("env-audit takes no arguments")
+	}
+
+	if c.concurrency < 1 {
+		failUsage("Concurrency must be at least 1, got %d", c.concurrency)
+	}
+
+	if c.format != formatTable && c.format != formatJson && c.format != formatNdjson && c.format != formatPrometheus && c.format != formatTemplate {
+		failUsage("Unsupported format '%s'. Supported formats: table, json, ndjson, prometheus, template", c.format)
+	}
+
+	fatalIf(c.tmpl.setup(c.format))
+
+	if c.failOn != "" && severityRank(c.failOn) < 0 {
+		failUsage("Unsupported --fail-on '%s'. Supported severities: %s", c.failOn, strings.Join(auditSeverities, ", "))
+	}
+
+	stopInterrupts := handleInterrupts(ui)
+	defer stopInterrupts()
+
+	space, err := targetedSpace(cliConnection)
+	fatalIf(err)
+
+	apps := appsWithProcess(cliConnection, spaceApps(cliConnection, "", space.Name, space.Guid), c.process, c.concurrency)
+
+	envs := map[string]map[string]interface{}{}
+	fetched := make([]map[string]interface{}, len(apps))
+	progress := newProgress(len(apps))
+
+	inParallel(len(apps), c.concurrency, func(index int) {
+		fetched[index], _ = c.failures.fetchEnv(cliConnection, apps[index])
+		progress.increment()
+	})
+	progress.done()
+
+	// Apps that failed aren't audited, rather than audited as empty.
+	for i, app := range apps {
+		if fetched[i] != nil {
+			envs[app.App.Entity.Name] = fetched[i]
+		}
+	}
+
+	findings := auditEnvs(envs)
+
+	printResult(ui.Out(), newFormatter(c.format, c.tmpl), auditReport{space: space.Name, audited: len(envs), findings: findings})
+
+	// Partial audits would notify about fewer findings than there are.
+	if len(findings) > 0 && !interrupted() {
+		fatalIf(c.notify.notify(notification{Event: "audit", Space: space.Name, Findings: findings}))
+	}
+
+	c.failures.report(ui, len(apps))
+
+	if c.failOn == "" {
+		return
+	}
+
+	failing := 0
+	for _, finding := range findings {
+		if severityRank(finding.Severity) <= severityRank(c.failOn) {
+			failing++
+		}
+	}
+
+	if failing == 0 {
+		return
+	}
+
+	err = fmt.Errorf("Problems of severity %s or higher in the environment of space %s: %d", c.failOn, space.Name, failing)
+
+	// The findings printed by the other formats are parsed, which the
+	// failure would get in the way of.
+	if c.format != formatTable {
+		writeFailure(ui.ErrOut(), err)
+		exit(exitCode(err))
+	}
+
+	fatalIf(err)
+}
+
+// auditGauges counts the findings by check, including the checks without
+// findings, so that alerts see them drop back to 0.
+func auditGauges(spaceName string, apps int, findings []auditFinding) []*gauge {
+
+	audited := &gauge{name: "cf_env_audited_apps", help: "Number of apps whose environment was audited."}
+	audited.add(float64(apps), "space", spaceName)
+
+	byCheck := &gauge{name: "cf_env_findings_total", help: "Number of problems found in the environment of the apps, by check."}
+	secrets := &gauge{name: "cf_env_secret_findings_total", help: "Number of secrets shared between apps or stored under keys that aren't redacted."}
+
+	counts := map[string]int{}
+	for _, finding := range findings {
+		counts[finding.Check]++
+	}
+
+	secretFindings := 0
+	for _, check := range auditChecks {
+		byCheck.add(float64(counts[check.check]), "space", spaceName, "check", check.check, "severity", check.severity)
+		if check.secret {
+			secretFindings += counts[check.check]
+		}
+	}
+	secrets.add(float64(secretFindings), "space", spaceName)
+
+	return []*gauge{audited, byCheck, secrets}
+}
+
+func severityRank(severity string) int {
+	for rank, known := range auditSeverities {
+		if known == severity {
+			return rank
+		}
+	}
+	return -1
+}
+
+// auditEnvs checks the envs by app name for secrets shared between apps,
+// credentials in keys that don't look secret and empty values. Findings
+// are sorted by severity, key and check.
+func auditEnvs(envs map[string]map[string]interface{}) []auditFinding {
+
+	var findings []auditFinding
+	shared := map[string]map[string][]string{}
+
+	for _, appName := range sortedEnvNames(envs) {
+		env := envs[appName]
+
+		for _, key := range sortedKeys(env) {
+			value := formatEnvValue(env[key])
+
+			switch {
+			case value == "":
+				findings = append(findings, auditFinding{
+					Severity: "low",
+					Check:    "empty-value",
+					Key:      key,
+					Apps:     []string{appName},
+					Message:  "Variable is set without a value",
+				})
+			case secretKey.MatchString(key):
+				if shared[key] == nil {
+					shared[key] = map[string][]string{}
+				}
+				shared[key][value] = append(shared[key][value], appName)
+			default:
+				for _, credential := range plaintextCredentials {
+					if credential.pattern.MatchString(value) {
+						findings = append(findings, auditFinding{
+							Severity: "medium",
+							Check:    "plaintext-credential",
+							Key:      key,
+							Apps:     []string{appName},
+							Message:  fmt.Sprintf("Value looks like a %s, but the key doesn't look secret so it isn't redacted", credential.kind),
+						})
+						break
+					}
+				}
+			}
+		}
+	}
+
+	for key, byValue := range shared {
+		for _, appNames := range byValue {
+			if len(appNames) > 1 {
+				findings = append(findings, auditFinding{
+					Severity: "high",
+					Check:    "shared-secret",
+					Key:      key,
+					Apps:     appNames,
+					Message:  fmt.Sprintf("%d apps share the same secret value", len(appNames)),
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity != b.Severity {
+			return severityRank(a.Severity) < severityRank(b.Severity)
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return strings.Join(a.Apps, ",") < strings.Join(b.Apps, ",")
+	})
+
+	return findings
+}
+
+func sortedEnvNames(envs map[string]map[string]interface{}) []string {
+	names := make([]string, 0, len(envs))
+	for name := range envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// auditReport is the result of env-audit, the findings in the env of the
+// audited apps of space.
+type auditReport struct {
+	space    string
+	audited  int
+	findings []auditFinding
+}
+
+func (r auditReport) Items() interface{} {
+	return r.findings
+}
+
+func (r auditReport) Schema() formatters.Schema {
+	return findingsSchema
+}
+
+func (r auditReport) gauges() []*gauge {
+	return auditGauges(r.space, r.audited, r.findings)
+}
+
+func (r auditReport) Print(out io.Writer) error {
+
+	if len(r.findings) == 0 {
+		_, err := fmt.Fprintf(out, "No problems found in the environment of space %s\n", r.space)
+		return err
+	}
+
+	colors := map[string]string{"high": colorRed, "medium": colorYellow, "low": colorDefault}
+
+	table := tabwriter.NewWriter(out, 0, 4, 3, ' ', 0)
+	fmt.Fprintf(table, "%s\tcheck\tkey\tapps\tmessage\n", paint(colorDefault, "severity"))
+	for _, finding := range r.findings {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", paint(colors[finding.Severity], finding.Severity), finding.Check, finding.Key, strings.Join(finding.Apps, ", "), finding.Message)
+	}
+	table.Flush()
+
+	_, err := fmt.Fprintf(out, "\n%d problems found in the environment of space %s\n", len(r.findings), r.space)
+	return err
+}
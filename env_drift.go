@@ -0,0 +1,145 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+)
+
+type manifestModel struct {
+	Applications []struct {
+		Name string                 `yaml:"name"`
+		Env  map[string]interface{} `yaml:"env"`
+	} `yaml:"applications"`
+}
+
+// readManifestEnv returns the env block of the app in the manifest at path.
+func readManifestEnv(path string, appName string) (map[string]interface{}, error) {
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest manifestModel
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("Failed to parse manifest '%s': %s", path, err)
+	}
+
+	for _, app := range manifest.Applications {
+		if app.Name == appName {
+			return app.Env, nil
+		}
+	}
+
+	return nil, fmt.Errorf("App '%s' not found in manifest '%s'", appName, path)
+}
+
+// stringValues formats every value of env the way the CLI does, so that
+// `PORT: 8080` in a manifest equals the live value "8080".
+func stringValues(env map[string]interface{}) map[string]interface{} {
+
+	formatted := make(map[string]interface{}, len(env))
+	for key, value := range env {
+		formatted[key] = formatEnvValue(jsonCompatible(value))
+	}
+
+	return formatted
+}
+
+// driftKinds are the values of `env-drift --fail-on`, from the point of view
+// of the live app: added variables aren't in the manifest, removed ones are
+// missing on the app.
+var driftKinds = map[string]func(diff envDiff) bool{
+	"added":   func(diff envDiff) bool { return len(diff.OnlyInB) > 0 },
+	"removed": func(diff envDiff) bool { return len(diff.OnlyInA) > 0 },
+	"changed": func(diff envDiff) bool { return len(diff.Changed) > 0 },
+	"any":     func(diff envDiff) bool { return !diff.empty() },
+}
+
+type envDriftCommand struct {
+	manifestPath string
+	failOn       string
+	showValues   bool
+	notify       notifier
+}
+
+func (c *envDriftCommand) Name() string {
+	return "env-drift"
+}
+
+func (c *envDriftCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "env-drift",
+		HelpText: "Compare the user-provided environment variables of an app with the env block of its manifest and fail on drift.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf env-drift APP_NAME [-f MANIFEST] [--fail-on added|removed|changed|any] [--show-values] [--notify-url URL] [--notify-slack URL] [--notify-template TEMPLATE]",
+			Options: map[string]string{
+				"f":               "Path to the manifest (default manifest.yml). --manifest is an alias",
+				"fail-on":         "Kind of drift that fails the command (default any). Added variables are missing from the manifest, removed ones from the app",
+				"show-values":     "Print the differing values",
+				"notify-url":      "POST the drifted keys as JSON to the webhook URL when there is drift. Values are never sent",
+				"notify-slack":    "Post a message to the Slack incoming webhook URL when there is drift",
+				"notify-template": "Go text/template of the message, with the fields of the JSON payload, e.g. '{{.App}} drifted: {{join .Added \", \"}}'",
+			},
+		},
+	}
+}
+
+func (c *envDriftCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("env-drift")
+	flags.StringVar(&c.manifestPath, "f", "manifest.yml", "")
+	flags.StringVar(&c.manifestPath, "manifest", "manifest.yml", "")
+	flags.StringVar(&c.failOn, "fail-on", "any", "")
+	flags.BoolVar(&c.showValues, "show-values", false, "")
+	c.notify.register(flags)
+
+	return flags
+}
+
+func (c *envDriftCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	fatalIf(c.notify.validate())
+
+	if len(args) != 1 {
+		failUsage("App name must be provided")
+	}
+
+	drifted, ok := driftKinds[c.failOn]
+
+	if !ok {
+		failUsage("Unsupported --fail-on '%s'. Supported values: added, removed, changed, any", c.failOn)
+	}
+
+	appName := args[0]
+
+	expected, err := readManifestEnv(c.manifestPath, appName)
+	fatalIf(err)
+
+	expected = stringValues(expected)
+	live := stringValues(userProvidedEnv(fetchEnv(cliConnection, appName)))
+
+	writeEnvDiff(ui.Out(), c.manifestPath, appName, expected, live, c.showValues)
+
+	diff := diffEnv(expected, live)
+
+	if !diff.empty() {
+		fatalIf(c.notify.notify(notification{
+			Event:   "drift",
+			App:     appName,
+			Source:  c.manifestPath,
+			Added:   diff.OnlyInB,
+			Removed: diff.OnlyInA,
+			Changed: diff.Changed,
+		}))
+	}
+
+	if drifted(diff) {
+		fatalIf(fmt.Errorf("Environment of '%s' drifted from %s", appName, c.manifestPath))
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"strings"
+)
+
+type envExportCommand struct {
+	to         string
+	secretName string
+	section    string
+	perKey     bool
+	options    storeOptions
+}
+
+func (c *envExportCommand) Name() string {
+	return "env-export"
+}
+
+func (c *envExportCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "env-export",
+		HelpText: "Write the environment variables of an app into an external secret store, as a new version of one secret or of a secret per variable.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf env-export APP_NAME --to vault|aws-secretsmanager|gcp-secretmanager [--vault-path PATH | --secret-name NAME] [--per-key] [--section user|staging|running|system] [--endpoint URL] [--aws-region REGION] [--gcp-project PROJECT] [--dry-run]",
+			Options: map[string]string{
+				"to":          "Secret store to write to. Credentials are read like by the CLI of the store: VAULT_ADDR and VAULT_TOKEN, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, GOOGLE_OAUTH_ACCESS_TOKEN or gcloud",
+				"vault-path":  "Path of the secret in a KV version 2 engine, starting with its mount, e.g. secret/cf/my-app",
+				"secret-name": "Name of the secret in AWS or Google Cloud (default APP_NAME)",
+				"per-key":     "Write a secret per variable, named after the secret and the variable, instead of a JSON object of all variables",
+				"section":     "Section of the environment to export (default user)",
+				"endpoint":    "API endpoint of the secret store, e.g. of a private endpoint or an emulator",
+				"aws-region":  "AWS region of the secret (default AWS_REGION)",
+				"gcp-project": "Google Cloud project of the secret (default GOOGLE_CLOUD_PROJECT)",
+			},
+		},
+	}
+}
+
+func (c *envExportCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("env-export")
+	flags.StringVar(&c.to, "to", "", "")
+	flags.StringVar(&c.secretName, "secret-name", "", "")
+	flags.StringVar(&c.secretName, "vault-path", "", "")
+	flags.StringVar(&c.section, "section", "user", "")
+	flags.BoolVar(&c.perKey, "per-key", false, "")
+	flags.StringVar(&c.options.endpoint, "endpoint", "", "")
+	flags.StringVar(&c.options.awsRegion, "aws-region", "", "")
+	flags.StringVar(&c.options.gcpProject, "gcp-project", "", "")
+
+	return flags
+}
+
+func (c *envExportCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 1 {
+		failUsage("App name must be provided")
+	}
+
+	newExporter, ok := exporters[c.to]
+
+	if !ok {
+		failUsage("Unsupported target '%s'. Supported targets: %s", c.to, strings.Join(exporterNames(), ", "))
+	}
+
+	appName := args[0]
+
+	if c.to == "vault" {
+		if c.secretName == "" {
+			failUsage("--vault-path must be provided")
+		}
+
+		_, err := vaultDataPath(c.secretName)
+		fatalIf(err)
+	}
+
+	if c.secretName == "" {
+		c.secretName = appName
+	}
+
+	envSection, ok := findEnvSection(c.section)
+
+	if !ok {
+		failUsage("Unsupported section '%s'. Supported sections: %s", c.section, strings.Join(sectionNames()[:len(envSections)], ", "))
+	}
+
+	vars := sectionVars(fetchEnv(cliConnection, appName), envSection.key)
+
+	data := make(map[string]string, len(vars))
+	for key, value := range vars {
+		data[key] = formatEnvValue(value)
+	}
+
+	keys := sortedKeys(vars)
+
+	if globals.dryRun {
+		if c.perKey {
+			store, err := newExporter(c.options)
+			fatalIf(err)
+
+			ui.Say("Would write %d variables of '%s' to %s:", len(keys), appName, c.to)
+			for _, key := range keys {
+				ui.Say("  %s", store.keyName(c.secretName, key))
+			}
+			return
+		}
+
+		ui.Say("Would write %d variables of '%s' to %s at %s:", len(keys), appName, c.to, c.secretName)
+		for _, key := range keys {
+			ui.Say("  %s", key)
+		}
+		return
+	}
+
+	store, err := newExporter(c.options)
+	fatalIf(err)
+
+	if !c.perKey {
+		fatalIf(store.writeVars(c.secretName, data))
+		ui.Say("Wrote %d variables of '%s' to %s at %s", len(keys), appName, c.to, c.secretName)
+		return
+	}
+
+	for _, key := range keys {
+		fatalIf(store.writeValue(store.keyName(c.secretName, key), data[key]))
+	}
+
+	ui.Say("Wrote %d variables of '%s' to %s, one secret per variable", len(keys), appName, c.to)
+}
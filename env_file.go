@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// readEnvFile reads variables from a JSON or YAML object, or from a .env file
+// for any other extension. Values which aren't strings are JSON-encoded, the
+// way they would be seen by the app.
+func readEnvFile(path string) (map[string]string, error) {
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(data, &values)
+	default:
+		return parseDotenv(data)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse '%s': %s", path, err)
+	}
+
+	env := make(map[string]string, len(values))
+	for key, value := range values {
+		env[key] = formatEnvValue(jsonCompatible(value))
+	}
+
+	return env, nil
+}
+
+// jsonCompatible converts the map[interface{}]interface{} produced by the YAML
+// decoder into map[string]interface{}, which encoding/json can marshal.
+func jsonCompatible(value interface{}) interface{} {
+
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(typed))
+		for key, nested := range typed {
+			converted[fmt.Sprint(key)] = jsonCompatible(nested)
+		}
+		return converted
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(typed))
+		for key, nested := range typed {
+			converted[key] = jsonCompatible(nested)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(typed))
+		for i, nested := range typed {
+			converted[i] = jsonCompatible(nested)
+		}
+		return converted
+	default:
+		return value
+	}
+}
+
+var dotenvUnescaper = strings.NewReplacer(
+	`\\`, `\`,
+	`\"`, `"`,
+	`\$`, "$",
+	`\n`, "\n",
+	`\r`, "\r",
+)
+
+// parseDotenv reads KEY=VALUE lines as written by `get-env --format dotenv`.
+// Blank lines and comments are skipped, an `export ` prefix is allowed.
+// Double-quoted values are unescaped, single-quoted values are taken literally.
+func parseDotenv(data []byte) (map[string]string, error) {
+
+	env := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		separator := strings.Index(line, "=")
+
+		if separator < 1 {
+			return nil, fmt.Errorf("Line %d: expected KEY=VALUE", number)
+		}
+
+		key := strings.TrimSpace(line[:separator])
+		value := strings.TrimSpace(line[separator+1:])
+
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			value = dotenvUnescaper.Replace(value[1 : len(value)-1])
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		default:
+			if comment := strings.Index(value, " #"); comment >= 0 {
+				value = strings.TrimSpace(value[:comment])
+			}
+		}
+
+		env[key] = value
+	}
+
+	return env, scanner.Err()
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	formatTable   = "table"
+	formatDotenv  = "dotenv"
+	formatShell   = "shell"
+	formatYaml    = "yaml"
+	formatJson    = "json"
+	formatCsv     = "csv"
+	formatCompose = "compose"
+)
+
+var envFormats = []string{formatTable, formatDotenv, formatShell, formatYaml, formatJson, formatCompose, formatK8sSecret, formatK8sConfigMap, formatTfvars, formatTfMap, formatManifest}
+
+func isEnvFormat(format string) bool {
+	for _, supported := range envFormats {
+		if format == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// envSection is one of the sections of the env document, selected with
+// `get-env --section`.
+type envSection struct {
+	name  string
+	key   string
+	title string
+}
+
+const sectionAll = "all"
+
+// envSections are listed in the order of `get-env --section all`.
+var envSections = []envSection{
+	{name: "user", key: "environment_json", title: "User-Provided"},
+	{name: "staging", key: "staging_env_json", title: "Staging Environment Variable Groups"},
+	{name: "running", key: "running_env_json", title: "Running Environment Variable Groups"},
+	{name: "system", key: "system_env_json", title: "System-Provided"},
+}
+
+func findEnvSection(name string) (envSection, bool) {
+	for _, section := range envSections {
+		if section.name == name {
+			return section, true
+		}
+	}
+	return envSection{}, false
+}
+
+func sectionNames() []string {
+	names := make([]string, 0, len(envSections)+1)
+	for _, section := range envSections {
+		names = append(names, section.name)
+	}
+	return append(names, sectionAll)
+}
+
+// sectionVars returns the variables of the section with the given key of the
+// env document.
+func sectionVars(env map[string]interface{}, key string) map[string]interface{} {
+
+	vars, ok := env[key].(map[string]interface{})
+
+	if !ok {
+		return map[string]interface{}{}
+	}
+
+	return vars
+}
+
+// userProvidedEnv returns the variables set through `cf set-env` or the manifest,
+// which the env endpoint reports in the "environment_json" section.
+func userProvidedEnv(env map[string]interface{}) map[string]interface{} {
+	return sectionVars(env, "environment_json")
+}
+
+// writeEnv prints the env document in the selected format, to the --out file
+// if given.
+func (p *GetEnvPlugin) writeEnv(env map[string]interface{}) {
+
+	out, done := p.openOut()
+	defer done()
+
+	p.writeEnvTo(out, env)
+}
+
+// openOut returns the --out file, or stdout, and a function closing it.
+func (p *GetEnvPlugin) openOut() (io.Writer, func()) {
+
+	if p.out == "" {
+		return p.ui.Out(), func() {}
+	}
+
+	file, err := os.OpenFile(p.out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+
+	if err != nil {
+		fmt.Fprintf(p.ui.Out(), "Failed to open '%s' for writing: %s\n", p.out, err)
+		exit(1)
+	}
+
+	return file, func() { file.Close() }
+}
+
+// writeEnvTo prints the env document in the selected format. Structured
+// formats keep all sections of the document, the others show the
+// user-provided variables.
+func (p *GetEnvPlugin) writeEnvTo(out io.Writer, env map[string]interface{}) {
+
+	if isK8sFormat(p.format) {
+		name := p.name
+		if name == "" {
+			name = k8sName(p.appName)
+		}
+		writeK8sManifest(out, p.format, name, p.namespace, p.selectedVars(env))
+		return
+	}
+
+	if p.format == formatManifest {
+		writeManifest(out, p.appName, p.selectedVars(env))
+		return
+	}
+
+	if p.format == formatTfMap {
+		name := p.name
+		if name == "" {
+			name = "env"
+		}
+		writeTfMap(out, name, p.selectedVars(env))
+		return
+	}
+
+	switch {
+	case p.format == formatYaml:
+		writeYaml(out, p.document(env))
+	case p.format == formatJson:
+		writeJson(out, envSchema.Of(p.document(env)))
+	case p.section == "":
+		writeVars(out, p.format, userProvidedEnv(env))
+	case p.section == sectionAll:
+		writeAllSections(out, p.format, env)
+	default:
+		writeVars(out, p.format, p.selectedVars(env))
+	}
+}
+
+// envSchema is the schema of the JSON output of get-env and get-env-group,
+// the env or the variables of the sections. envsSchema is that of several
+// apps, their envs by app name.
+var (
+	envSchema  = formatters.Schema{Name: "env", Version: 1, Results: map[string]interface{}{}}
+	envsSchema = formatters.Schema{Name: "envs", Version: 1, Results: map[string]map[string]interface{}{}}
+)
+
+// schema is that of the env, of the envs of several apps, or of the reports
+// of --inspect-certs and --summarize-urls.
+func (p *GetEnvPlugin) schema(args []string) formatters.Schema {
+
+	switch {
+	case p.certs:
+		return certificatesSchema
+	case p.urls:
+		return urlsSchema
+	case p.appsFile != "" || p.fromStdin || len(args) > 1 && !isKeyOrPath(args[1]):
+		return envsSchema
+	}
+
+	return envSchema
+}
+
+// document returns what the structured formats print of env: the whole
+// document, the variables of the --section, or all sections by name.
+func (p *GetEnvPlugin) document(env map[string]interface{}) interface{} {
+
+	switch p.section {
+	case "":
+		return env
+	case sectionAll:
+		return nestedSections(env)
+	default:
+		return p.selectedVars(env)
+	}
+}
+
+func nestedSections(env map[string]interface{}) map[string]interface{} {
+
+	nested := make(map[string]interface{}, len(envSections))
+	for _, section := range envSections {
+		nested[section.name] = sectionVars(env, section.key)
+	}
+
+	return nested
+}
+
+// selectedVars returns the variables of the section given with --section,
+// by default the user-provided ones.
+func (p *GetEnvPlugin) selectedVars(env map[string]interface{}) map[string]interface{} {
+
+	if section, ok := findEnvSection(p.section); ok {
+		return sectionVars(env, section.key)
+	}
+
+	return userProvidedEnv(env)
+}
+
+// writeVars prints the variables of a section in one of the flat formats.
+func writeVars(out io.Writer, format string, vars map[string]interface{}) {
+	switch format {
+	case formatDotenv:
+		writeDotenv(out, vars)
+	case formatShell:
+		writeShell(out, vars)
+	case formatCompose:
+		writeCompose(out, vars)
+	case formatTfvars:
+		writeTfvars(out, vars)
+	default:
+		writeEnvTable(out, vars)
+	}
+}
+
+// singleSectionFormat reports whether format can't hold several sections
+// one after another, because the result wouldn't be valid.
+func singleSectionFormat(format string) bool {
+	return format == formatCompose || format == formatTfvars || format == formatTfMap || format == formatManifest || isK8sFormat(format)
+}
+
+type composeEnvironment struct {
+	Environment []string `yaml:"environment"`
+}
+
+// composeEscaper escapes "$", which docker-compose would interpolate.
+var composeEscaper = strings.NewReplacer("$", "$$")
+
+// writeCompose prints the environment block of a docker-compose service. The
+// YAML encoder quotes entries that contain colons or start with characters
+// YAML would otherwise interpret.
+func writeCompose(out io.Writer, vars map[string]interface{}) {
+
+	environment := composeEnvironment{Environment: []string{}}
+
+	for _, key := range sortedKeys(vars) {
+		environment.Environment = append(environment.Environment, key+"="+composeEscaper.Replace(formatEnvValue(vars[key])))
+	}
+
+	writeYaml(out, environment)
+}
+
+// writeAllSections prints every section under a header, see nestedSections
+// for the structured formats. Headers are comments in dotenv and shell
+// output, so that it stays loadable.
+func writeAllSections(out io.Writer, format string, env map[string]interface{}) {
+
+	for i, section := range envSections {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+
+		if format == formatTable {
+			fmt.Fprintf(out, "%s:\n", section.title)
+		} else {
+			fmt.Fprintf(out, "# %s\n", section.title)
+		}
+
+		writeVars(out, format, sectionVars(env, section.key))
+	}
+}
+
+func sortedKeys(env map[string]interface{}) []string {
+
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// writeEnvTable prints a variable per row. The lines of multi-line values
+// are aligned below each other, see truncate.go for long ones.
+func writeEnvTable(out io.Writer, env map[string]interface{}) {
+
+	keys := sortedKeys(env)
+
+	rows := make([][]string, len(keys))
+	for i, key := range keys {
+		rows[i] = []string{key}
+	}
+	width := remainingWidth(outputWidth(out), columnsWidth(rows, 1)+len("= "))
+
+	table := tabwriter.NewWriter(out, 0, 4, 1, ' ', 0)
+	for _, key := range keys {
+		lines := valueLines(formatEnvValue(env[key]), width)
+
+		fmt.Fprintf(table, "%s\t= %s\n", key, lines[0])
+		for _, line := range lines[1:] {
+			fmt.Fprintf(table, "\t  %s\n", line)
+		}
+	}
+	table.Flush()
+}
+
+// envVars are the variables of a section, for the formatters.
+type envVars map[string]interface{}
+
+func (vars envVars) Vars() map[string]string {
+
+	formatted := make(map[string]string, len(vars))
+	for key, value := range vars {
+		formatted[key] = formatEnvValue(value)
+	}
+
+	return formatted
+}
+
+func writeDotenv(out io.Writer, env map[string]interface{}) {
+	fatalIf(formatters.Dotenv{}.Format(out, envVars(env)))
+}
+
+func writeJson(out io.Writer, value interface{}) {
+	fatalIf(formatters.Json{Partial: interrupted}.Format(out, value))
+}
+
+func writeYaml(out io.Writer, value interface{}) {
+	fatalIf(formatters.Yaml{}.Format(out, value))
+}
+
+var posixIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// writeShell prints `export` statements meant to be eval'ed. Keys the shell
+// can't take as variable names are skipped with a warning on stderr so that
+// the output stays evaluable.
+func writeShell(out io.Writer, env map[string]interface{}) {
+	for _, key := range sortedKeys(env) {
+		if !posixIdentifier.MatchString(key) {
+			fmt.Fprintf(os.Stderr, "Skipping '%s': not a valid shell variable name\n", key)
+			continue
+		}
+
+		fmt.Fprintf(out, "export %s=%s\n", key, quoteShell(formatEnvValue(env[key])))
+	}
+}
+
+// quoteShell single-quotes value, which disables all expansion. A single
+// quote inside the value closes the quoting, adds an escaped quote and reopens it.
+func quoteShell(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+func formatEnvValue(value interface{}) string {
+
+	if str, ok := value.(string); ok {
+		return str
+	}
+
+	encoded, err := json.Marshal(value)
+
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(encoded)
+}
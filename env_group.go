@@ -0,0 +1,173 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"io"
+	"strings"
+)
+
+// parseEnvGroup checks that group is the name of an environment variable group.
+func parseEnvGroup(group string) {
+	for _, name := range ccclient.EnvGroups {
+		if group == name {
+			return
+		}
+	}
+	failUsage("Unknown group '%s'. Supported groups: %s", group, strings.Join(ccclient.EnvGroups, ", "))
+}
+
+type getEnvGroupCommand struct {
+	format      string
+	redact      bool
+	showSecrets bool
+}
+
+func (c *getEnvGroupCommand) Name() string {
+	return "get-env-group"
+}
+
+func (c *getEnvGroupCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "get-env-group",
+		HelpText: "List the variables of the running or staging environment variable group.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf get-env-group running|staging [--format table|dotenv|shell|yaml|json] [--redact | --show-secrets]",
+			Options: map[string]string{
+				"format":       "Output format (default table)",
+				"redact":       "Mask secret values. Default when printing to a terminal",
+				"show-secrets": "Print secret values on a terminal",
+			},
+		},
+	}
+}
+
+func (c *getEnvGroupCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("get-env-group")
+	flags.StringVar(&c.format, "format", formatTable, "")
+	flags.BoolVar(&c.redact, "redact", false, "")
+	flags.BoolVar(&c.showSecrets, "show-secrets", false, "")
+
+	return flags
+}
+
+func (c *getEnvGroupCommand) schema(args []string) formatters.Schema {
+	return envSchema
+}
+
+func (c *getEnvGroupCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 1 {
+		failUsage("Group must be provided")
+	}
+
+	group := args[0]
+	parseEnvGroup(group)
+
+	if !isEnvFormat(c.format) {
+		failUsage("Unsupported format '%s'. Supported formats: %s", c.format, strings.Join(envFormats, ", "))
+	}
+
+	vars, err := ccClient(cliConnection).GetEnvGroup(group)
+	fatalIf(err)
+
+	if shouldRedact(ui, c.redact, c.showSecrets, false) {
+		vars = redactEnv(vars)
+	}
+
+	printResult(ui.Out(), envFormatter(c.format), groupVars{format: c.format, vars: vars})
+}
+
+// groupVars are the variables of a group, printed for people in one of the
+// env formats, like dotenv.
+type groupVars struct {
+	format string
+	vars   map[string]interface{}
+}
+
+func (v groupVars) Items() interface{} {
+	return v.vars
+}
+
+func (v groupVars) Schema() formatters.Schema {
+	return envSchema
+}
+
+func (v groupVars) Print(out io.Writer) error {
+	writeVars(out, v.format, v.vars)
+	return nil
+}
+
+// setEnvGroupCommand sets a single variable of a group, or all variables of
+// a file with --file.
+type setEnvGroupCommand struct {
+	file  string
+	prune bool
+}
+
+func (c *setEnvGroupCommand) Name() string {
+	return "set-env-group"
+}
+
+func (c *setEnvGroupCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "set-env-group",
+		HelpText: "Set variables of the running or staging environment variable group, one at a time or from a .env, JSON or YAML file.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf set-env-group running|staging (KEY VALUE | --file FILE [--prune])",
+			Options: map[string]string{
+				"file":  "Set all variables of FILE in a single update",
+				"prune": "Remove variables that are not in FILE",
+			},
+		},
+	}
+}
+
+func (c *setEnvGroupCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("set-env-group")
+	flags.StringVar(&c.file, "file", "", "")
+	flags.BoolVar(&c.prune, "prune", false, "")
+
+	return flags
+}
+
+func (c *setEnvGroupCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	var vars map[string]string
+
+	switch {
+	case c.file != "" && len(args) == 1:
+		var err error
+		vars, err = readEnvFile(c.file)
+		fatalIf(err)
+	case c.file == "" && len(args) == 3:
+		vars = map[string]string{args[1]: args[2]}
+	default:
+		failUsage("Group and either a variable with its value or --file must be provided")
+	}
+
+	if c.prune && c.file == "" {
+		failUsage("--prune requires --file")
+	}
+
+	group := args[0]
+	parseEnvGroup(group)
+
+	client := ccClient(cliConnection)
+
+	current, err := client.GetEnvGroup(group)
+	fatalIf(err)
+
+	updated, changes := mergeEnv(current, vars, c.prune)
+
+	if changes.any() {
+		fatalIf(client.SetEnvGroup(group, updated))
+	}
+
+	changes.print(ui.Out(), c.prune)
+}
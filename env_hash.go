@@ -0,0 +1,85 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+const hashPrefix = "sha256:"
+
+// envHash fingerprints env by hashing its canonical JSON, which has sorted
+// keys and no insignificant whitespace. Values keep their JSON type, so 8080
+// and "8080" hash differently.
+func envHash(env map[string]interface{}) (string, error) {
+
+	canonical, err := json.Marshal(env)
+
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+
+	return hashPrefix + hex.EncodeToString(sum[:]), nil
+}
+
+type envHashCommand struct {
+	expect string
+}
+
+func (c *envHashCommand) Name() string {
+	return "env-hash"
+}
+
+func (c *envHashCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "env-hash",
+		HelpText: "Print a SHA-256 fingerprint of the user-provided environment of an app, to detect changes without storing the values.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf env-hash APP_NAME [--expect HASH]",
+			Options: map[string]string{
+				"expect": "Fail if the fingerprint differs from HASH",
+			},
+		},
+	}
+}
+
+func (c *envHashCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("env-hash")
+	flags.StringVar(&c.expect, "expect", "", "")
+
+	return flags
+}
+
+func (c *envHashCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 1 {
+		failUsage("App name must be provided")
+	}
+
+	appName := args[0]
+
+	hash, err := envHash(userProvidedEnv(fetchEnv(cliConnection, appName)))
+	fatalIf(err)
+
+	ui.Say("%s", hash)
+
+	if c.expect == "" {
+		return
+	}
+
+	if !strings.HasPrefix(c.expect, hashPrefix) {
+		c.expect = hashPrefix + c.expect
+	}
+
+	if !strings.EqualFold(c.expect, hash) {
+		fatalIf(fmt.Errorf("Environment of '%s' doesn't match %s", appName, c.expect))
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+type envImportCommand struct {
+	from       string
+	secretName string
+	prefix     string
+	prune      bool
+	force      bool
+	options    storeOptions
+	restart    *restartOptions
+	history    *historyOptions
+}
+
+func (c *envImportCommand) Name() string {
+	return "env-import"
+}
+
+func (c *envImportCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "env-import",
+		HelpText: "Set the environment variables of an app from a secret of an external secret store, listing the changes first.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf env-import APP_NAME --from vault|aws-secretsmanager|gcp-secretmanager --path PATH [--prefix PREFIX] [--prune [-f]] [--endpoint URL] [--aws-region REGION] [--gcp-project PROJECT] [--dry-run] [--restart | --restage] [--track-history]",
+			Options: map[string]string{
+				"from":          "Secret store to read from, with the credentials of env-export",
+				"path":          "Path of the Vault secret or name of the AWS or Google Cloud secret, which must hold a JSON object. --secret-name is an alias",
+				"prefix":        "Prefix to prepend to the imported variable names",
+				"prune":         "Remove variables that are not in the secret. With --prefix, only variables starting with the prefix are removed",
+				"f":             "Remove variables without confirmation and update the app even if its env exceeds the size the Cloud Controller accepts by default. --force is an alias",
+				"endpoint":      "API endpoint of the secret store, e.g. of a private endpoint or an emulator",
+				"aws-region":    "AWS region of the secret (default AWS_REGION)",
+				"gcp-project":   "Google Cloud project of the secret (default GOOGLE_CLOUD_PROJECT)",
+				"restart":       "Restart the app after the update",
+				"restage":       "Restage the app after the update",
+				"track-history": "Record the names of the changed variables in the history of the app, see env-history",
+			},
+		},
+	}
+}
+
+func (c *envImportCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("env-import")
+	flags.StringVar(&c.from, "from", "", "")
+	flags.StringVar(&c.secretName, "path", "", "")
+	flags.StringVar(&c.secretName, "secret-name", "", "")
+	flags.StringVar(&c.prefix, "prefix", "", "")
+	flags.BoolVar(&c.prune, "prune", false, "")
+	flags.BoolVar(&c.force, "force", false, "")
+	flags.BoolVar(&c.force, "f", false, "")
+	flags.StringVar(&c.options.endpoint, "endpoint", "", "")
+	flags.StringVar(&c.options.awsRegion, "aws-region", "", "")
+	flags.StringVar(&c.options.gcpProject, "gcp-project", "", "")
+	c.restart = addRestartFlags(flags)
+	c.history = addHistoryFlags(flags)
+
+	return flags
+}
+
+func (c *envImportCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 1 {
+		failUsage("App name must be provided")
+	}
+
+	newImporter, ok := importers[c.from]
+
+	if !ok {
+		failUsage("Unsupported source '%s'. Supported sources: %s", c.from, strings.Join(importerNames(), ", "))
+	}
+
+	if c.secretName == "" {
+		failUsage("--path must be provided")
+	}
+
+	if c.from == "vault" {
+		_, err := vaultDataPath(c.secretName)
+		fatalIf(err)
+	}
+
+	appName := args[0]
+
+	store, err := newImporter(c.options)
+	fatalIf(err)
+
+	secret, err := store.readVars(c.secretName)
+	fatalIf(err)
+
+	vars := make(map[string]string, len(secret))
+	for key, value := range secret {
+		vars[c.prefix+key] = value
+	}
+
+	app := resolveApp(cliConnection, appName)
+	current := userProvidedEnv(fetchEnvByGuid(cliConnection, appName, app.Guid))
+
+	updated, changes := importEnv(current, vars, c.prefix, c.prune)
+
+	change := envChangeSet{appName: appName, guid: app.Guid, before: current, after: updated, force: c.force}
+	change.writePreview(ui.Out())
+
+	if !changes.any() {
+		changes.print(ui.Out(), c.prune)
+		return
+	}
+
+	if changes.removed > 0 && !c.force && !globals.dryRun && !confirm(ui, fmt.Sprintf("Really remove %d variables?", changes.removed)) {
+		ui.Say("Nothing imported")
+		return
+	}
+
+	if !change.apply(ui, cliConnection) {
+		changes.print(ui.Out(), c.prune)
+		return
+	}
+
+	c.history.record(ui, cliConnection, appName, app.Guid, "env-import", current, updated)
+
+	ui.Say("Imported %s %s into '%s'", c.from, c.secretName, appName)
+	changes.print(ui.Out(), c.prune)
+
+	c.restart.apply(ui, cliConnection, appName, app.Guid)
+}
+
+// importEnv merges vars into current like set-env-file. Only the variables
+// starting with prefix are pruned, so that an import with a prefix leaves
+// the other variables of the app alone.
+func importEnv(current map[string]interface{}, vars map[string]string, prefix string, prune bool) (map[string]interface{}, envChanges) {
+
+	imported := map[string]interface{}{}
+	for key, value := range current {
+		if strings.HasPrefix(key, prefix) {
+			imported[key] = value
+		}
+	}
+
+	updated, changes := mergeEnv(imported, vars, prune)
+
+	for key, value := range current {
+		if !strings.HasPrefix(key, prefix) {
+			updated[key] = value
+		}
+	}
+
+	return updated, changes
+}
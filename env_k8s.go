@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	formatK8sSecret    = "k8s-secret"
+	formatK8sConfigMap = "k8s-configmap"
+)
+
+func isK8sFormat(format string) bool {
+	return format == formatK8sSecret || format == formatK8sConfigMap
+}
+
+type k8sManifest struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sMetadata       `yaml:"metadata"`
+	Type       string            `yaml:"type,omitempty"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type k8sMetadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+var (
+	k8sDataKey     = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+	k8sInvalidName = regexp.MustCompile(`[^a-z0-9-]+`)
+)
+
+// k8sName turns an app name into a valid Kubernetes object name.
+func k8sName(appName string) string {
+	return strings.Trim(k8sInvalidName.ReplaceAllString(strings.ToLower(appName), "-"), "-")
+}
+
+// writeK8sManifest prints vars as a Secret, with base64-encoded values, or
+// as a ConfigMap. Keys Kubernetes doesn't accept are skipped with a warning
+// on stderr, like in shell output.
+func writeK8sManifest(out io.Writer, format string, name string, namespace string, vars map[string]interface{}) {
+
+	manifest := k8sManifest{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Metadata:   k8sMetadata{Name: name, Namespace: namespace},
+		Data:       map[string]string{},
+	}
+
+	if format == formatK8sSecret {
+		manifest.Kind = "Secret"
+		manifest.Type = "Opaque"
+	}
+
+	for _, key := range sortedKeys(vars) {
+		if !k8sDataKey.MatchString(key) {
+			fmt.Fprintf(os.Stderr, "Skipping '%s': not a valid %s key\n", key, manifest.Kind)
+			continue
+		}
+
+		value := formatEnvValue(vars[key])
+		if format == formatK8sSecret {
+			value = base64.StdEncoding.EncodeToString([]byte(value))
+		}
+
+		manifest.Data[key] = value
+	}
+
+	writeYaml(out, manifest)
+}
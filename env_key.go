@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// envKeyName matches the KEY of `cf get-env APP KEY`. Variables are upper
+// case by convention, which tells them from the app names of
+// `cf get-env APP...`.
+var envKeyName = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+
+// isKeyOrPath reports whether arg, given after the app name, is a KEY or a
+// JSON path rather than the name of another app.
+func isKeyOrPath(arg string) bool {
+	return envKeyName.MatchString(arg) || strings.HasPrefix(arg, "$")
+}
+
+// keySections are searched for `cf get-env APP KEY` in the order the app
+// sees them: user-provided variables override the running group.
+var keySections = []string{"user", "system", "running"}
+
+// lookupKey returns the value of the variable key, from the --section if
+// given.
+func (p *GetEnvPlugin) lookupKey(env map[string]interface{}) (string, error) {
+
+	names := keySections
+	if p.section != "" {
+		names = []string{p.section}
+	}
+
+	for _, name := range names {
+		section, _ := findEnvSection(name)
+
+		if value, ok := sectionVars(env, section.key)[p.key]; ok {
+			return formatEnvValue(value), nil
+		}
+	}
+
+	return "", &ccclient.NotFoundError{Kind: "Variable", Name: p.key}
+}
+
+// writeKey prints the raw value of the variable, with a trailing newline
+// unless -n is given.
+func (p *GetEnvPlugin) writeKey(out io.Writer, env map[string]interface{}) {
+
+	value, err := p.lookupKey(env)
+	fatalIf(err)
+
+	if p.noNewline {
+		fmt.Fprint(out, value)
+	} else {
+		fmt.Fprintln(out, value)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const formatManifest = "manifest"
+
+// manifestEnv returns vars as a mapping with sorted keys.
+func manifestEnv(vars map[string]interface{}) yaml.MapSlice {
+
+	env := yaml.MapSlice{}
+	for _, key := range sortedKeys(vars) {
+		env = append(env, yaml.MapItem{Key: key, Value: vars[key]})
+	}
+
+	return env
+}
+
+// writeManifest prints a manifest of the app with vars as its env.
+func writeManifest(out io.Writer, appName string, vars map[string]interface{}) {
+
+	app := yaml.MapSlice{
+		{Key: "name", Value: appName},
+		{Key: "env", Value: manifestEnv(vars)},
+	}
+
+	writeYaml(out, yaml.MapSlice{{Key: "applications", Value: []interface{}{app}}})
+}
+
+// mergeIntoManifest replaces the env of the app in the manifest at path,
+// adding the app if the manifest doesn't list it. Other keys and their order
+// are kept; comments are lost, since the YAML decoder drops them.
+func mergeIntoManifest(path string, appName string, vars map[string]interface{}) error {
+
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	var manifest yaml.MapSlice
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("Failed to parse manifest '%s': %s", path, err)
+	}
+
+	applications, index := manifestApplications(manifest)
+
+	found := false
+	for i, item := range applications {
+		if app, ok := item.(yaml.MapSlice); ok && mapSliceValue(app, "name") == appName {
+			applications[i] = setMapSliceValue(app, "env", manifestEnv(vars))
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		applications = append(applications, yaml.MapSlice{
+			{Key: "name", Value: appName},
+			{Key: "env", Value: manifestEnv(vars)},
+		})
+	}
+
+	if index < 0 {
+		manifest = append(manifest, yaml.MapItem{Key: "applications", Value: applications})
+	} else {
+		manifest[index].Value = applications
+	}
+
+	encoded, err := yaml.Marshal(manifest)
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, encoded, info.Mode())
+}
+
+// manifestApplications returns the applications of manifest and the index of
+// the key listing them, or -1 if there is none.
+func manifestApplications(manifest yaml.MapSlice) ([]interface{}, int) {
+
+	for i, item := range manifest {
+		if item.Key == "applications" {
+			applications, _ := item.Value.([]interface{})
+			return applications, i
+		}
+	}
+
+	return nil, -1
+}
+
+func mapSliceValue(slice yaml.MapSlice, key string) interface{} {
+
+	for _, item := range slice {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+
+	return nil
+}
+
+func setMapSliceValue(slice yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+
+	for i, item := range slice {
+		if item.Key == key {
+			slice[i].Value = value
+			return slice
+		}
+	}
+
+	return append(slice, yaml.MapItem{Key: key, Value: value})
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// envMatrixRow holds the values of a key in every app of the matrix. Apps
+// without the key have no entry in Values.
+type envMatrixRow struct {
+	Key     string            `json:"key"`
+	Differs bool              `json:"differs"`
+	Values  map[string]string `json:"values"`
+}
+
+// matrixSchema is the schema of the JSON output of env-matrix.
+var matrixSchema = formatters.Schema{Name: "variables", Version: 1, Results: []envMatrixRow{}}
+
+type envMatrixCommand struct {
+	redact      bool
+	showSecrets bool
+	differences bool
+	concurrency int
+	format      string
+	process     string
+	tmpl        listTemplate
+	failures    *scanFailures
+}
+
+func (c *envMatrixCommand) Name() string {
+	return "env-matrix"
+}
+
+func (c *envMatrixCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "env-matrix",
+		HelpText: "Compare the user-provided environment variables of all apps in the targeted space, with a row per variable and a column per app. Variables that differ between apps are marked with *.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf env-matrix [--differences] [--redact | --show-secrets] [--concurrency N] [--format table|csv|json|ndjson|template [--template TEMPLATE | --template-file FILE]] [--process TYPE] [--continue-on-error=false]",
+			Options: map[string]string{
+				"differences":       "Only show the variables that differ between apps",
+				"redact":            "Mask secret values. Default when printing to a terminal",
+				"show-secrets":      "Print secret values on a terminal",
+				"concurrency":       "Number of apps fetched at the same time (default 4)",
+				"format":            "Output format (default table)",
+				"process":           "Only compare apps running a process of TYPE, e.g. worker",
+				"template":          "Go text/template printed for every variable with --format template, e.g. '{{.Key}} {{.Differs}}'",
+				"template-file":     "Read the template of --format template from FILE",
+				"continue-on-error": "Keep scanning when the env of an app can't be fetched, and list those apps at the end, exiting with 6 if others were scanned (default true)",
+			},
+		},
+	}
+}
+
+func (c *envMatrixCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("env-matrix")
+	flags.BoolVar(&c.redact, "redact", false, "")
+	flags.BoolVar(&c.showSecrets, "show-secrets", false, "")
+	flags.BoolVar(&c.differences, "differences", false, "")
+	flags.IntVar(&c.concurrency, "concurrency", 4, "")
+	flags.StringVar(&c.format, "format", formatTable, "")
+	flags.StringVar(&c.process, "process", "", "")
+	c.tmpl.register(flags)
+	c.failures = addScanFailureFlags(flags)
+
+	return flags
+}
+
+func (c *envMatrixCommand) schema(args []string) formatters.Schema {
+	return matrixSchema
+}
+
+func (c *envMatrixCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 0 {
+		failUsage("env-matrix takes no arguments")
+	}
+
+	if c.concurrency < 1 {
+		failUsage("Concurrency must be at least 1, got %d", c.concurrency)
+	}
+
+	if c.format != formatTable && c.format != formatCsv && c.format != formatJson && c.format != formatNdjson && c.format != formatTemplate {
+		failUsage("Unsupported format '%s'. Supported formats: table, csv, json, ndjson, template", c.format)
+	}
+
+	fatalIf(c.tmpl.setup(c.format))
+
+	stopInterrupts := handleInterrupts(ui)
+	defer stopInterrupts()
+
+	space, err := targetedSpace(cliConnection)
+	fatalIf(err)
+
+	apps := appsWithProcess(cliConnection, spaceApps(cliConnection, "", space.Name, space.Guid), c.process, c.concurrency)
+	sort.Slice(apps, func(i, j int) bool { return apps[i].App.Entity.Name < apps[j].App.Entity.Name })
+
+	names := make([]string, len(apps))
+	envs := make([]map[string]interface{}, len(apps))
+	progress := newProgress(len(apps))
+
+	defer c.failures.report(ui, len(apps))
+
+	inParallel(len(apps), c.concurrency, func(index int) {
+		names[index] = apps[index].App.Entity.Name
+		envs[index], _ = c.failures.fetchEnv(cliConnection, apps[index])
+		progress.increment()
+	})
+	progress.done()
+
+	// Apps that failed would look like they had none of the keys.
+	var fetchedNames []string
+	var fetched []map[string]interface{}
+	for i, env := range envs {
+		if env != nil {
+			fetchedNames = append(fetchedNames, names[i])
+			fetched = append(fetched, env)
+		}
+	}
+	names, envs = fetchedNames, fetched
+
+	rows := envMatrix(names, envs, shouldRedact(ui, c.redact, c.showSecrets, false))
+
+	if c.differences {
+		var differing []envMatrixRow
+		for _, row := range rows {
+			if row.Differs {
+				differing = append(differing, row)
+			}
+		}
+		rows = differing
+	}
+
+	printResult(ui.Out(), newFormatter(c.format, c.tmpl), envMatrixReport{names: names, rows: rows})
+}
+
+// envMatrixReport is the result of env-matrix, the rows of the variables of
+// the apps of names.
+type envMatrixReport struct {
+	names []string
+	rows  []envMatrixRow
+}
+
+func (r envMatrixReport) Items() interface{} {
+	return r.rows
+}
+
+func (r envMatrixReport) Schema() formatters.Schema {
+	return matrixSchema
+}
+
+// envMatrix returns a row for every key of envs, sorted by key. A key
+// differs if an app lacks it or has another value than the others.
+func envMatrix(names []string, envs []map[string]interface{}, hide bool) []envMatrixRow {
+
+	byKey := map[string]*envMatrixRow{}
+
+	for i, env := range envs {
+		for key, value := range env {
+			row, ok := byKey[key]
+			if !ok {
+				row = &envMatrixRow{Key: key, Values: map[string]string{}}
+				byKey[key] = row
+			}
+			row.Values[names[i]] = formatEnvValue(value)
+		}
+	}
+
+	rows := make([]envMatrixRow, 0, len(byKey))
+
+	for _, key := range sortedRowKeys(byKey) {
+		row := byKey[key]
+		row.Differs = len(row.Values) < len(names)
+
+		for _, value := range row.Values {
+			if value != row.Values[names[0]] {
+				row.Differs = true
+			}
+		}
+
+		if hide && secretKey.MatchString(key) {
+			for name, value := range row.Values {
+				row.Values[name] = mask(value)
+			}
+		}
+
+		rows = append(rows, *row)
+	}
+
+	return rows
+}
+
+func sortedRowKeys(rows map[string]*envMatrixRow) []string {
+	keys := make([]string, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Print prints a key per row and an app per column. Keys that differ are
+// marked with * and highlighted; every key is painted so that the escape
+// codes don't break the alignment.
+func (r envMatrixReport) Print(out io.Writer) error {
+
+	names, rows := r.names, r.rows
+
+	if len(rows) == 0 {
+		_, err := fmt.Fprintln(out, "No variables to compare")
+		return err
+	}
+
+	// The apps share the width left by the marker and the keys.
+	keys := [][]string{{" ", "key"}}
+	for _, row := range rows {
+		keys = append(keys, []string{" ", row.Key})
+	}
+
+	width := remainingWidth(outputWidth(out), columnsWidth(keys, 3))
+	if width > 0 && len(names) > 0 {
+		width = remainingWidth(width/len(names), 3)
+	}
+
+	table := tabwriter.NewWriter(out, 0, 4, 3, ' ', 0)
+	fmt.Fprintf(table, " \t%s\t%s\n", paint(colorDefault, "key"), strings.Join(names, "\t"))
+
+	for _, row := range rows {
+		marker, color := " ", colorDefault
+		if row.Differs {
+			marker, color = "*", colorYellow
+		}
+
+		cells := []string{marker, paint(color, row.Key)}
+		for _, name := range names {
+			cells = append(cells, singleLine(matrixCell(row, name), width))
+		}
+		fmt.Fprintln(table, strings.Join(cells, "\t"))
+	}
+
+	return table.Flush()
+}
+
+func (r envMatrixReport) Header() []string {
+	return append([]string{"key", "differs"}, r.names...)
+}
+
+func (r envMatrixReport) Rows() [][]string {
+
+	records := make([][]string, 0, len(r.rows))
+
+	for _, row := range r.rows {
+		record := []string{row.Key, fmt.Sprint(row.Differs)}
+		for _, name := range r.names {
+			record = append(record, row.Values[name])
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func matrixCell(row envMatrixRow, name string) string {
+	if value, ok := row.Values[name]; ok {
+		return value
+	}
+	return "-"
+}
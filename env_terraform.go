@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	formatTfvars = "tfvars"
+	formatTfMap  = "tf-map"
+)
+
+var hclIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// hclEscaper escapes quoted HCL strings, including the template sequences
+// Terraform would otherwise interpolate.
+var hclEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+	"${", "$${",
+	"%{", "%%{",
+)
+
+func quoteHcl(value string) string {
+	return `"` + hclEscaper.Replace(value) + `"`
+}
+
+// writeTfvars prints one variable assignment per key. Keys that aren't valid
+// variable names are skipped with a warning on stderr.
+func writeTfvars(out io.Writer, vars map[string]interface{}) {
+	for _, key := range sortedKeys(vars) {
+		if !hclIdentifier.MatchString(key) {
+			fmt.Fprintf(os.Stderr, "Skipping '%s': not a valid Terraform variable name\n", key)
+			continue
+		}
+
+		fmt.Fprintf(out, "%s = %s\n", key, quoteHcl(formatEnvValue(vars[key])))
+	}
+}
+
+// writeTfMap prints vars as a single map variable called name. Map keys are
+// quoted, so every key can be kept.
+func writeTfMap(out io.Writer, name string, vars map[string]interface{}) {
+
+	fmt.Fprintf(out, "%s = {\n", name)
+	for _, key := range sortedKeys(vars) {
+		fmt.Fprintf(out, "  %s = %s\n", quoteHcl(key), quoteHcl(formatEnvValue(vars[key])))
+	}
+	fmt.Fprintln(out, "}")
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// envRulesModel is the rules file of `cf env-validate`:
+//
+//	required: [DATABASE_URL]
+//	forbidden: [DEBUG]
+//	patterns:
+//	  LOG_LEVEL: '^(debug|info|warn|error)$'
+//	max-length:
+//	  FEATURE_FLAGS: 1024
+//	schemas:
+//	  FEATURE_FLAGS: flags.schema.json
+//
+// Schemas are JSON Schemas of values holding JSON, relative to the rules.
+type envRulesModel struct {
+	Required  []string          `yaml:"required"`
+	Forbidden []string          `yaml:"forbidden"`
+	Patterns  map[string]string `yaml:"patterns"`
+	MaxLength map[string]int    `yaml:"max-length"`
+	Schemas   map[string]string `yaml:"schemas"`
+
+	patterns map[string]*regexp.Regexp
+	schemas  map[string]jsonSchema
+}
+
+// envViolation is a rule broken by the env of an app. Like audit findings,
+// it never contains values.
+type envViolation struct {
+	Rule    string `json:"rule"`
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// violationsSchema is the schema of the JSON output of env-validate.
+var violationsSchema = formatters.Schema{Name: "violations", Version: 1, Results: []envViolation{}}
+
+func readEnvRules(path string) (envRulesModel, error) {
+
+	var rules envRulesModel
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return rules, err
+	}
+
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return rules, fmt.Errorf("Failed to parse rules '%s': %s", path, err)
+	}
+
+	if err := rules.compile(); err != nil {
+		return rules, err
+	}
+
+	for key, schemaPath := range rules.Schemas {
+		if !filepath.IsAbs(schemaPath) {
+			schemaPath = filepath.Join(filepath.Dir(path), schemaPath)
+		}
+		if err := rules.addSchema(key, schemaPath); err != nil {
+			return rules, err
+		}
+	}
+
+	return rules, nil
+}
+
+func (rules *envRulesModel) compile() error {
+
+	rules.patterns = map[string]*regexp.Regexp{}
+	rules.schemas = map[string]jsonSchema{}
+
+	for key, expression := range rules.Patterns {
+		pattern, err := regexp.Compile(expression)
+		if err != nil {
+			return usageErrorf("Invalid pattern of %s: %s", key, err)
+		}
+		rules.patterns[key] = pattern
+	}
+
+	return nil
+}
+
+// addSchema validates the JSON value of key against the schema at path.
+func (rules *envRulesModel) addSchema(key string, path string) error {
+
+	schema, err := readJsonSchema(path)
+	if err != nil {
+		return err
+	}
+
+	rules.schemas[key] = schema
+
+	return nil
+}
+
+// validate checks env against the rules. Values are compared the way the
+// app sees them, so structured values are checked as JSON.
+func (rules envRulesModel) validate(env map[string]interface{}) []envViolation {
+
+	var violations []envViolation
+
+	for _, key := range rules.Required {
+		if _, ok := env[key]; !ok {
+			violations = append(violations, envViolation{Rule: "required", Key: key, Message: "is not set"})
+		}
+	}
+
+	for _, key := range rules.Forbidden {
+		if _, ok := env[key]; ok {
+			violations = append(violations, envViolation{Rule: "forbidden", Key: key, Message: "must not be set"})
+		}
+	}
+
+	for key, pattern := range rules.patterns {
+		if value, ok := env[key]; ok && !pattern.MatchString(formatEnvValue(value)) {
+			violations = append(violations, envViolation{Rule: "pattern", Key: key, Message: fmt.Sprintf("doesn't match %s", pattern)})
+		}
+	}
+
+	for key, limit := range rules.MaxLength {
+		value, ok := env[key]
+		if !ok {
+			continue
+		}
+		if length := utf8.RuneCountInString(formatEnvValue(value)); length > limit {
+			violations = append(violations, envViolation{Rule: "max-length", Key: key, Message: fmt.Sprintf("is %d characters long, at most %d are allowed", length, limit)})
+		}
+	}
+
+	for key, schema := range rules.schemas {
+		value, ok := env[key]
+		if !ok {
+			continue
+		}
+
+		if encoded, isString := value.(string); isString {
+			if err := json.Unmarshal([]byte(encoded), &value); err != nil {
+				violations = append(violations, envViolation{Rule: "schema", Key: key, Message: "is not valid JSON"})
+				continue
+			}
+		}
+
+		for _, message := range schema.validate(value) {
+			violations = append(violations, envViolation{Rule: "schema", Key: key, Message: message})
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Key != violations[j].Key {
+			return violations[i].Key < violations[j].Key
+		}
+		return violations[i].Rule < violations[j].Rule
+	})
+
+	return violations
+}
+
+type envValidateCommand struct {
+	rulesPath string
+	format    string
+	schemas   stringsValue
+}
+
+func (c *envValidateCommand) Name() string {
+	return "env-validate"
+}
+
+func (c *envValidateCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "env-validate",
+		HelpText: "Check the user-provided environment variables of an app against a YAML file of rules: `required` and `forbidden` variables, `patterns` that values must match, the `max-length` of values and the JSON `schemas` of values holding JSON. Fails if a rule is violated.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf env-validate APP_NAME (--rules FILE | --json-schema KEY=SCHEMA...) [--format table|json|ndjson]",
+			Options: map[string]string{
+				"rules":       "YAML file of the rules",
+				"json-schema": "Validate the JSON value of KEY against the JSON Schema in the file SCHEMA. Can be given several times. --schema KEY=SCHEMA is a deprecated alias",
+				"format":      "Output format (default table)",
+			},
+		},
+	}
+}
+
+func (c *envValidateCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("env-validate")
+	flags.StringVar(&c.rulesPath, "rules", "", "")
+	flags.StringVar(&c.format, "format", formatTable, "")
+	flags.Var(&c.schemas, "json-schema", "")
+
+	return flags
+}
+
+func (c *envValidateCommand) schema(args []string) formatters.Schema {
+	return violationsSchema
+}
+
+// rewriteArgs turns the --schema KEY=FILE of older versions into
+// --json-schema KEY=FILE, leaving --schema on its own to print the JSON
+// Schema of the output.
+func (c *envValidateCommand) rewriteArgs(ui UI, args []string) []string {
+
+	rewritten := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name := strings.TrimLeft(arg, "-")
+
+		switch {
+		case arg == name:
+			rewritten = append(rewritten, arg)
+		case name == schemaFlag && i+1 < len(args) && isSchemaMapping(args[i+1]):
+			ui.Warn("--schema KEY=FILE is deprecated, use --json-schema KEY=FILE")
+			rewritten = append(rewritten, "--json-schema", args[i+1])
+			i++
+		case strings.HasPrefix(name, schemaFlag+"=") && isSchemaMapping(strings.TrimPrefix(name, schemaFlag+"=")):
+			ui.Warn("--schema KEY=FILE is deprecated, use --json-schema KEY=FILE")
+			rewritten = append(rewritten, "--json-"+name)
+		default:
+			rewritten = append(rewritten, arg)
+		}
+	}
+
+	return rewritten
+}
+
+// isSchemaMapping tells whether value maps a key to a schema file, rather
+// than being a value of the boolean --schema.
+func isSchemaMapping(value string) bool {
+	return strings.Contains(value, "=") && !strings.HasPrefix(value, "-")
+}
+
+func (c *envValidateCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 1 {
+		failUsage("App name must be provided")
+	}
+
+	if c.rulesPath == "" && len(c.schemas) == 0 {
+		failUsage("--rules or --json-schema must be provided")
+	}
+
+	if c.format != formatTable && c.format != formatJson && c.format != formatNdjson {
+		failUsage("Unsupported format '%s'. Supported formats: table, json, ndjson", c.format)
+	}
+
+	// The violations are followed by the failure, which has to be JSON as
+	// well for the output to stay parseable.
+	if c.format != formatTable {
+		globals.errorFormat = "json"
+	}
+
+	appName := args[0]
+
+	var rules envRulesModel
+
+	if c.rulesPath != "" {
+		var err error
+		rules, err = readEnvRules(c.rulesPath)
+		fatalIf(err)
+	} else {
+		fatalIf(rules.compile())
+	}
+
+	for _, schema := range c.schemas {
+		separator := strings.Index(schema, "=")
+
+		if separator < 1 {
+			failUsage("--json-schema must be KEY=FILE, got '%s'", schema)
+		}
+
+		fatalIf(rules.addSchema(schema[:separator], schema[separator+1:]))
+	}
+
+	source := c.rulesPath
+	if source == "" {
+		source = "the schemas"
+	}
+
+	violations := rules.validate(userProvidedEnv(fetchEnv(cliConnection, appName)))
+
+	printResult(ui.Out(), newFormatter(c.format, listTemplate{}), envValidation{app: appName, source: source, violations: violations})
+
+	if len(violations) > 0 {
+		fatalIf(fmt.Errorf("Environment of '%s' violates %d rules of %s", appName, len(violations), source))
+	}
+}
+
+// envValidation is the result of env-validate, the rules of source that the
+// env of app violates.
+type envValidation struct {
+	app        string
+	source     string
+	violations []envViolation
+}
+
+func (v envValidation) Items() interface{} {
+	return v.violations
+}
+
+func (v envValidation) Schema() formatters.Schema {
+	return violationsSchema
+}
+
+func (v envValidation) Print(out io.Writer) error {
+
+	if len(v.violations) == 0 {
+		_, err := fmt.Fprintf(out, "Environment of '%s' complies with %s\n", v.app, v.source)
+		return err
+	}
+
+	table := tabwriter.NewWriter(out, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(table, "key\trule\tmessage")
+	for _, violation := range v.violations {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", violation.Key, paint(colorRed, violation.Rule), violation.Message)
+	}
+	table.Flush()
+
+	_, err := fmt.Fprintln(out)
+	return err
+}
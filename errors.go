@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// Error codes of the JSON error report. They are part of the output
+// contract for CI tooling, so only ever add to them.
+const (
+	errorFailed       = "failed"
+	errorUsage        = "usage"
+	errorNotFound     = "not_found"
+	errorUnauthorized = "unauthorized"
+	errorUnreachable  = "unreachable"
+	errorAPI          = "api_error"
+	errorInterrupted  = "interrupted"
+)
+
+// Exit codes of the plugin, so that automation can tell retryable failures
+// from fatal ones. Like the error codes, they must not change.
+const (
+	exitFailed      = 1
+	exitUsage       = 2
+	exitNotFound    = 3
+	exitAuth        = 4
+	exitUnreachable = 5
+
+	// exitPartial is returned by scans that failed for some of the apps
+	// and scanned the others, see scanFailures.
+	exitPartial = 6
+
+	// exitInterrupted is the exit code of shells for Ctrl-C.
+	exitInterrupted = 130
+)
+
+// exit ends the plugin with an exit code. RunWithConnection replaces it to
+// only end the command.
+var exit = os.Exit
+
+var exitCodes = map[string]int{
+	errorUsage:        exitUsage,
+	errorNotFound:     exitNotFound,
+	errorUnauthorized: exitAuth,
+	errorUnreachable:  exitUnreachable,
+	errorInterrupted:  exitInterrupted,
+}
+
+// exitCode returns the exit code for failing with err.
+func exitCode(err error) int {
+
+	if code, ok := exitCodes[errorCode(err)]; ok {
+		return code
+	}
+
+	return exitFailed
+}
+
+// usageError is a failure caused by the arguments of the command.
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{fmt.Sprintf(format, args...)}
+}
+
+// failUsage ends the command with the usage error message.
+func failUsage(format string, args ...interface{}) {
+	err := usageErrorf(format, args...)
+	fail(err.Error(), err)
+}
+
+// failure ends a command, see fatalIf and fail. The plugin recovers it where
+// it runs the command, see reportFailure, and reports it through the UI of
+// the command, so that helpers deep down can end the command without
+// knowing where it prints to.
+type failure struct {
+	err error
+
+	// message, if set, is printed instead of FAILED and the message of err.
+	message string
+}
+
+// fatalIf ends the command with err, printed as FAILED and its message, if
+// err is set.
+func fatalIf(err error) {
+	if err != nil {
+		panic(failure{err: err})
+	}
+}
+
+// fail ends the command with message, printed without FAILED, and the exit
+// code of err.
+func fail(message string, err error) {
+	panic(failure{err: err, message: message})
+}
+
+// reportFailure reports the failure the command ended with through ui and
+// exits with its exit code. It has to be deferred, so that it can recover
+// the failure.
+func reportFailure(ui UI) {
+
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+
+	failed, ok := recovered.(failure)
+	if !ok {
+		panic(recovered)
+	}
+
+	// The requests aborted by interruptWith fail because of its error.
+	if err := interruptedWith(); err != nil {
+		failed = failure{err: err}
+	}
+
+	if failed.message == "" {
+		ui.Failed(failed.err)
+		return
+	}
+
+	writeError(ui.Out(), failed.message, failed.err)
+	exit(exitCode(failed.err))
+}
+
+// isEnding tells whether a recovered value ends the command, rather than
+// being a bug.
+func isEnding(recovered interface{}) bool {
+
+	switch recovered.(type) {
+	case failure, exitPanic:
+		return true
+	}
+
+	return false
+}
+
+// errorReport is printed instead of "FAILED" with `--error-format json`.
+type errorReport struct {
+	Error      string          `json:"error"`
+	Message    string          `json:"message"`
+	Endpoint   string          `json:"endpoint,omitempty"`
+	HTTPStatus int             `json:"http_status,omitempty"`
+	CCError    json.RawMessage `json:"cc_error,omitempty"`
+}
+
+func newErrorReport(err error) errorReport {
+
+	report := errorReport{Error: errorCode(err), Message: err.Error()}
+
+	switch err := err.(type) {
+	case *ccclient.APIError:
+		report.Endpoint = err.Endpoint()
+		report.HTTPStatus = err.StatusCode
+		if json.Valid([]byte(err.Body)) {
+			report.CCError = json.RawMessage(err.Body)
+		}
+	case *ccclient.StatusError:
+		report.Endpoint = strings.TrimSpace(err.Method + " " + err.Path)
+		report.HTTPStatus = err.StatusCode
+	case *ccclient.TimeoutError:
+		report.Endpoint = err.Method + " " + err.Path
+	}
+
+	return report
+}
+
+// errorCode classifies err so that automation can tell failures worth
+// retrying from fatal ones.
+func errorCode(err error) string {
+
+	if ccclient.IsCanceled(err) {
+		return errorInterrupted
+	}
+
+	switch err := err.(type) {
+	case *usageError:
+		return errorUsage
+	case *ccclient.NotFoundError:
+		return errorNotFound
+	case *ccclient.APIError:
+		switch {
+		case err.StatusCode == 401 || err.StatusCode == 403 || isAuthErrorCode(err.ErrorCode):
+			return errorUnauthorized
+		case err.StatusCode == 404 || strings.HasSuffix(err.ErrorCode, "NotFound"):
+			return errorNotFound
+		}
+		return errorAPI
+	case *ccclient.StatusError, net.Error:
+		return errorUnreachable
+	}
+
+	message := strings.ToLower(err.Error())
+
+	switch {
+	case strings.Contains(message, "not logged in") || strings.Contains(message, "access token"):
+		return errorUnauthorized
+	case strings.Contains(message, "not found"):
+		return errorNotFound
+	}
+
+	return errorFailed
+}
+
+func isAuthErrorCode(code string) bool {
+	return code == "CF-InvalidAuthToken" || code == "CF-NotAuthenticated" || code == "CF-NotAuthorized"
+}
+
+// writeFailure prints err as FAILED followed by its message, or as JSON
+// with --error-format json.
+func writeFailure(out io.Writer, err error) {
+
+	if globals.errorFormat != "json" {
+		fmt.Fprintln(out, "FAILED")
+	}
+
+	writeError(out, err.Error(), err)
+}
+
+func writeError(out io.Writer, message string, err error) {
+
+	if globals.errorFormat != "json" {
+		fmt.Fprintln(out, message)
+		return
+	}
+
+	encoded, _ := json.Marshal(newErrorReport(err))
+	fmt.Fprintln(out, string(encoded))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// exporter writes secrets into an external secret store. A secret holds
+// either all variables of an app as a JSON object or, with --per-key, the
+// value of a single variable.
+type exporter interface {
+
+	// writeVars stores vars as the secret name.
+	writeVars(name string, vars map[string]string) error
+
+	// writeValue stores value as the secret name.
+	writeValue(name string, value string) error
+
+	// keyName returns the name of the secret of key with --per-key.
+	keyName(name string, key string) string
+}
+
+// storeOptions configure the connection to a secret store. Credentials
+// come from the environment variables of the store's own CLI.
+type storeOptions struct {
+	endpoint   string
+	awsRegion  string
+	gcpProject string
+}
+
+// exporters are the stores of `env-export --to`. Adding a store means
+// implementing exporter and registering its constructor here.
+var exporters = map[string]func(options storeOptions) (exporter, error){
+	"vault": func(options storeOptions) (exporter, error) {
+		return newVaultClient(options)
+	},
+	"aws-secretsmanager": func(options storeOptions) (exporter, error) {
+		return newAwsSecretsManager(options)
+	},
+	"gcp-secretmanager": func(options storeOptions) (exporter, error) {
+		return newGcpSecretManager(options)
+	},
+}
+
+func exporterNames() []string {
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// importer reads secrets written by the exporter of the same store.
+type importer interface {
+
+	// readVars returns the variables of the secret name.
+	readVars(name string) (map[string]string, error)
+}
+
+// importers are the stores of `env-import --from`.
+var importers = map[string]func(options storeOptions) (importer, error){
+	"vault": func(options storeOptions) (importer, error) {
+		return newVaultClient(options)
+	},
+	"aws-secretsmanager": func(options storeOptions) (importer, error) {
+		return newAwsSecretsManager(options)
+	},
+	"gcp-secretmanager": func(options storeOptions) (importer, error) {
+		return newGcpSecretManager(options)
+	},
+}
+
+func importerNames() []string {
+	names := make([]string, 0, len(importers))
+	for name := range importers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// decodeSecretVars parses a secret holding a JSON object of variables.
+// Values that aren't strings are formatted like in the env of an app.
+func decodeSecretVars(name string, secret []byte) (map[string]string, error) {
+
+	var object map[string]interface{}
+
+	if err := json.Unmarshal(secret, &object); err != nil {
+		return nil, fmt.Errorf("Secret '%s' is not a JSON object of variables", name)
+	}
+
+	vars := make(map[string]string, len(object))
+	for key, value := range object {
+		vars[key] = formatEnvValue(value)
+	}
+
+	return vars, nil
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+// spaceApp is an app found while scanning, with the names of its org and
+// space. Apps of --targets also have the name and client of their
+// foundation.
+type spaceApp struct {
+	App        ccclient.AppModel
+	Org        string
+	Space      string
+	Foundation string
+	client     *ccclient.Client
+}
+
+// ccClient returns the client of the foundation of the app.
+func (a spaceApp) ccClient(cliConnection plugin.CliConnection) *ccclient.Client {
+
+	if a.client != nil {
+		return a.client
+	}
+
+	return ccClient(cliConnection)
+}
+
+// fetchEnv fetches the env of the app. Unlike fetchEnvByGuid, it returns
+// failures, for scans to carry on with the other apps.
+func (a spaceApp) fetchEnv(cliConnection plugin.CliConnection) (map[string]interface{}, error) {
+	return a.ccClient(cliConnection).GetAppEnv(a.App.Metadata.Guid)
+}
+
+// envMatch is an app defining the variable searched for, printed as JSON
+// object with --format ndjson and --stream ndjson.
+type envMatch struct {
+	App        string `json:"app"`
+	Org        string `json:"org"`
+	Space      string `json:"space"`
+	Foundation string `json:"foundation,omitempty"`
+	Value      string `json:"value"`
+}
+
+// matchesSchema is the schema of the JSON output of find-env.
+var matchesSchema = formatters.Schema{Name: "matches", Version: 1, Results: []envMatch{}}
+
+type findEnvCommand struct {
+	valueRegex  string
+	redact      bool
+	showSecrets bool
+	allSpaces   bool
+	allOrgs     bool
+	concurrency int
+	format      string
+	process     string
+	targets     string
+	stream      string
+	tmpl        listTemplate
+	failures    *scanFailures
+}
+
+func (c *findEnvCommand) Name() string {
+	return "find-env"
+}
+
+func (c *findEnvCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "find-env",
+		HelpText: "List the apps in the targeted space that define an environment variable.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf find-env KEY [--value-regex PATTERN] [--redact | --show-secrets] [--all-spaces | --all-orgs | --targets FILE] [--concurrency N] [--format table|csv|ndjson|template [--template TEMPLATE | --template-file FILE] | --stream ndjson] [--process TYPE] [--continue-on-error=false]",
+			Options: map[string]string{
+				"all-spaces":        "Search all spaces of the targeted org",
+				"all-orgs":          "Search all spaces of all orgs you can see",
+				"concurrency":       "Number of apps fetched at the same time (default 4). Apps fetched through cf curl, when the CLI provides no API endpoint or access token, are still fetched one at a time",
+				"value-regex":       "Only list apps whose value matches PATTERN",
+				"redact":            "Mask values. Default when printing to a terminal",
+				"show-secrets":      "Print values on a terminal",
+				"format":            "Output format, table (default), csv, ndjson, a JSON object per line, or template",
+				"process":           "Only search apps running a process of TYPE, e.g. worker",
+				"targets":           "Search the spaces of several foundations, listed in the YAML file FILE as entries like {api: api.example.com, org: ORG, space: SPACE}. See list-apps --targets",
+				"stream":            "Print every match as soon as it is found, as a JSON object per line (ndjson) with app, org, space and value",
+				"template":          "Go text/template printed for every match with --format template, e.g. '{{.App}}={{.Value}}'. The fields are App, Org, Space, Foundation and Value",
+				"template-file":     "Read the template of --format template from FILE",
+				"continue-on-error": "Keep scanning when the env of an app can't be fetched, and list those apps at the end, exiting with 6 if others were scanned (default true)",
+			},
+		},
+	}
+}
+
+func (c *findEnvCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("find-env")
+	flags.StringVar(&c.valueRegex, "value-regex", "", "")
+	flags.BoolVar(&c.redact, "redact", false, "")
+	flags.BoolVar(&c.showSecrets, "show-secrets", false, "")
+	flags.BoolVar(&c.allSpaces, "all-spaces", false, "")
+	flags.BoolVar(&c.allOrgs, "all-orgs", false, "")
+	flags.IntVar(&c.concurrency, "concurrency", 4, "")
+	flags.StringVar(&c.format, "format", formatTable, "")
+	flags.StringVar(&c.process, "process", "", "")
+	flags.StringVar(&c.targets, "targets", "", "")
+	flags.StringVar(&c.stream, "stream", "", "")
+	c.tmpl.register(flags)
+	c.failures = addScanFailureFlags(flags)
+
+	return flags
+}
+
+func (c *findEnvCommand) schema(args []string) formatters.Schema {
+	return matchesSchema
+}
+
+func (c *findEnvCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 1 {
+		failUsage("Variable name must be provided")
+	}
+
+	if c.concurrency < 1 {
+		fatalIf(usageErrorf("Concurrency must be at least 1, got %d", c.concurrency))
+	}
+
+	if c.format != formatTable && c.format != formatCsv && c.format != formatNdjson && c.format != formatTemplate {
+		fatalIf(usageErrorf("Unsupported format '%s'. Supported formats: table, csv, ndjson, template", c.format))
+	}
+
+	fatalIf(c.tmpl.setup(c.format))
+
+	fatalIf(validateStream(c.stream))
+
+	if c.stream != "" && c.format != formatTable {
+		fatalIf(usageErrorf("--stream can't be combined with --format"))
+	}
+
+	if c.targets != "" && (c.allSpaces || c.allOrgs) {
+		fatalIf(usageErrorf("--targets can't be combined with --all-spaces or --all-orgs"))
+	}
+
+	key := args[0]
+
+	stopInterrupts := handleInterrupts(ui)
+	defer stopInterrupts()
+
+	var valueFilter *regexp.Regexp
+	if c.valueRegex != "" {
+		var err error
+		valueFilter, err = regexp.Compile(c.valueRegex)
+		fatalIf(err)
+	}
+
+	banner := ui.Out()
+	if c.format != formatTable || c.stream != "" {
+		banner = ioutil.Discard
+	}
+
+	var apps []spaceApp
+	if c.targets != "" {
+		apps = scanTargetApps(cliConnection, banner, c.targets, key)
+	} else {
+		apps = scanApps(cliConnection, banner, c.allSpaces, c.allOrgs, key)
+	}
+
+	apps = appsWithProcess(cliConnection, apps, c.process, c.concurrency)
+	hide := shouldRedact(ui, c.redact, c.showSecrets, false)
+
+	defer c.failures.report(ui, len(apps))
+
+	if c.stream != "" {
+		out := &ndjsonWriter{out: ui.Out()}
+		findEnv(cliConnection, apps, key, valueFilter, c.concurrency, c.failures, func(match envMatch) {
+			if hide {
+				match.Value = mask(match.Value)
+			}
+			out.write(match)
+		})
+		out.markPartial()
+		return
+	}
+
+	matches := findEnv(cliConnection, apps, key, valueFilter, c.concurrency, c.failures, nil)
+
+	if hide {
+		for i := range matches {
+			matches[i].Value = mask(matches[i].Value)
+		}
+	}
+
+	printResult(ui.Out(), newFormatter(c.format, c.tmpl), envMatches{
+		key:         key,
+		matches:     matches,
+		foundations: c.targets != "",
+		wide:        c.allSpaces || c.allOrgs,
+	})
+}
+
+// envMatches are the result of find-env.
+type envMatches struct {
+	key     string
+	matches []envMatch
+
+	// foundations is set when searching --targets, and wide when
+	// searching more than one space.
+	foundations bool
+	wide        bool
+}
+
+func (m envMatches) Items() interface{} {
+	return m.matches
+}
+
+func (m envMatches) Schema() formatters.Schema {
+	return matchesSchema
+}
+
+// Print prints the matches with the columns saying where the apps are, and
+// the value in the last column where it can take the rest of the width of
+// the terminal.
+func (m envMatches) Print(out io.Writer) error {
+
+	if len(m.matches) == 0 {
+		_, err := fmt.Fprintf(out, "No apps define %s\n", m.key)
+		return err
+	}
+
+	header := []string{"app"}
+	switch {
+	case m.foundations:
+		header = []string{"foundation", "org", "space", "app"}
+	case m.wide:
+		header = []string{"org", "space", "app"}
+	}
+
+	rows := [][]string{header}
+	for _, match := range m.matches {
+		row := []string{match.Foundation, match.Org, match.Space, match.App}
+		rows = append(rows, row[len(row)-len(header):])
+	}
+
+	width := remainingWidth(outputWidth(out), columnsWidth(rows, 3))
+
+	table := tabwriter.NewWriter(out, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(table, strings.Join(header, "\t")+"\tvalue")
+
+	for i, match := range m.matches {
+		lines := valueLines(match.Value, width)
+		fmt.Fprintln(table, strings.Join(rows[i+1], "\t")+"\t"+lines[0])
+		for _, line := range lines[1:] {
+			fmt.Fprintln(table, strings.Repeat("\t", len(header))+line)
+		}
+	}
+
+	return table.Flush()
+}
+
+// Header and Rows are the CSV of the matches, every match with its org and
+// space, whatever the scope of the search, and its foundation when
+// searching --targets.
+func (m envMatches) Header() []string {
+
+	header := []string{"org", "space", "app", "value"}
+	if m.foundations {
+		header = append([]string{"foundation"}, header...)
+	}
+
+	return header
+}
+
+func (m envMatches) Rows() [][]string {
+
+	var rows [][]string
+
+	for _, match := range m.matches {
+		row := []string{match.Org, match.Space, match.App, match.Value}
+		if m.foundations {
+			row = append([]string{match.Foundation}, row...)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// scanApps lists the apps of the targeted space, of every space in the
+// targeted org with allSpaces, or of every visible org with allOrgs. What is
+// being searched is announced on banner.
+func scanApps(cliConnection plugin.CliConnection, banner io.Writer, allSpaces bool, allOrgs bool, key string) []spaceApp {
+
+	org, err := targetedOrg(cliConnection)
+	fatalIf(err)
+
+	if !allSpaces && !allOrgs {
+		space, err := targetedSpace(cliConnection)
+		fatalIf(err)
+
+		fmt.Fprintf(banner, "Searching apps in space %s for %s\n\n", space.Name, key)
+
+		return spaceApps(cliConnection, org.Name, space.Name, space.Guid)
+	}
+
+	client := ccClient(cliConnection)
+
+	orgs := []ccclient.NamedResourceModel{{Metadata: ccclient.MetadataModel{Guid: org.Guid}}}
+	orgs[0].Entity.Name = org.Name
+
+	if allOrgs {
+		fmt.Fprintf(banner, "Searching apps in all orgs for %s\n\n", key)
+		orgs, err = client.ListResources("v2/organizations")
+		fatalIf(err)
+	} else {
+		fmt.Fprintf(banner, "Searching apps in all spaces of org %s for %s\n\n", org.Name, key)
+	}
+
+	var apps []spaceApp
+
+	for _, org := range orgs {
+		spaces, err := client.ListResources(fmt.Sprintf("v2/organizations/%s/spaces", org.Metadata.Guid))
+		fatalIf(err)
+
+		for _, space := range spaces {
+			apps = append(apps, spaceApps(cliConnection, org.Entity.Name, space.Entity.Name, space.Metadata.Guid)...)
+		}
+	}
+
+	return apps
+}
+
+// scanTargetApps lists the apps of the spaces of the --targets file.
+func scanTargetApps(cliConnection plugin.CliConnection, banner io.Writer, path string, key string) []spaceApp {
+
+	spaces := targetSpaces(cliConnection, path)
+
+	fmt.Fprintf(banner, "Searching apps in %d spaces of %s for %s\n\n", len(spaces), path, key)
+
+	var apps []spaceApp
+
+	for _, space := range spaces {
+		space.foundation.client.Concurrency = 4
+
+		listed, err := space.listApps(ccclient.AppFilters{})
+		fatalIf(err)
+
+		for _, app := range listed {
+			apps = append(apps, spaceApp{App: app, Org: space.org, Space: space.space, Foundation: space.foundation.name, client: space.foundation.client})
+		}
+	}
+
+	return apps
+}
+
+// spaceApps lists the apps of the space, which are cached like app GUIDs.
+func spaceApps(cliConnection plugin.CliConnection, orgName string, spaceName string, spaceGuid string) []spaceApp {
+
+	key, cacheable := cacheKey(cliConnection, spaceGuid, "apps")
+
+	var listed []ccclient.AppModel
+
+	if !cacheable || !cache.get(key, &listed) {
+		client := ccClient(cliConnection)
+		client.Concurrency = 4
+
+		var err error
+		listed, err = client.ListApps(ccclient.AppFilters{SpaceGuid: spaceGuid})
+		fatalIf(err)
+
+		if cacheable {
+			cache.put(key, listed)
+		}
+	}
+
+	var apps []spaceApp
+
+	for _, app := range listed {
+		apps = append(apps, spaceApp{App: app, Org: orgName, Space: spaceName})
+	}
+
+	return apps
+}
+
+// findEnv fetches the env of every app with a pool of concurrency workers and
+// returns, in the order of apps, those that define key with a value matching
+// valueFilter if one is given. With onMatch, the matches are passed to it
+// as soon as they are found instead, without progress getting in between.
+// Apps whose env can't be fetched are recorded in failures. Through `cf curl`
+// the workers take turns, since the CLI runs one curl at a time.
+func findEnv(cliConnection plugin.CliConnection, apps []spaceApp, key string, valueFilter *regexp.Regexp, concurrency int, failures *scanFailures, onMatch func(match envMatch)) []envMatch {
+
+	results := make([]*envMatch, len(apps))
+
+	progress := newProgress(len(apps))
+	if onMatch != nil {
+		progress.enabled = false
+	}
+
+	inParallel(len(apps), concurrency, func(index int) {
+		results[index] = matchEnv(cliConnection, apps[index], key, valueFilter, failures)
+		if results[index] != nil && onMatch != nil {
+			onMatch(*results[index])
+		}
+		progress.increment()
+	})
+	progress.done()
+
+	if onMatch != nil {
+		return nil
+	}
+
+	var matches []envMatch
+
+	for _, result := range results {
+		if result != nil {
+			matches = append(matches, *result)
+		}
+	}
+
+	return matches
+}
+
+func matchEnv(cliConnection plugin.CliConnection, app spaceApp, key string, valueFilter *regexp.Regexp, failures *scanFailures) *envMatch {
+
+	env, ok := failures.fetchEnv(cliConnection, app)
+	if !ok {
+		return nil
+	}
+
+	value, ok := env[key]
+	if !ok {
+		return nil
+	}
+
+	formatted := formatEnvValue(value)
+	if valueFilter != nil && !valueFilter.MatchString(formatted) {
+		return nil
+	}
+
+	return &envMatch{App: app.App.Entity.Name, Org: app.Org, Space: app.Space, Foundation: app.Foundation, Value: formatted}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// newFlagSet creates a flag set for a subcommand which reports errors
+// instead of exiting, so callers can print usage in the plugin's own style.
+func newFlagSet(command string) *flag.FlagSet {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	return flags
+}
+
+// parseFlags parses args with flags allowed before, between and after the
+// positional arguments, e.g. `cf get-env APP --format dotenv`. The standard
+// library stops at the first positional argument, so we resume parsing after
+// each one. Returns the positional arguments in order. Defaults come from
+// the config file, see applyConfig.
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+
+	if err := applyConfig(flags); err != nil {
+		return nil, err
+	}
+
+	var positional []string
+
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, &usageError{err.Error()}
+		}
+
+		args = flags.Args()
+
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// splitCommand splits args at the first "--" into the arguments of the
+// plugin command and a command line it runs, e.g. for
+// `cf run-with-env APP -- ./server --port 8080`.
+func splitCommand(args []string) ([]string, []string) {
+
+	for i, arg := range args {
+		if arg == "--" {
+			return args[:i], args[i+1:]
+		}
+	}
+
+	return args, nil
+}
+
+// isBoolFlag reports whether flag is set without a value, like --redact.
+func isBoolFlag(flag *flag.Flag) bool {
+
+	boolFlag, ok := flag.Value.(interface {
+		IsBoolFlag() bool
+	})
+
+	return ok && boolFlag.IsBoolFlag()
+}
+
+// stringsValue is a flag that can be given several times, like
+// `--schema A=a.json --schema B=b.json`.
+type stringsValue []string
+
+func (s *stringsValue) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsValue) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// ageValue is a duration flag that also accepts days and weeks, like 90d or
+// 2w, since app ages are rarely counted in hours.
+type ageValue time.Duration
+
+func (a *ageValue) String() string {
+	return time.Duration(*a).String()
+}
+
+func (a *ageValue) Set(value string) error {
+
+	if value == "" {
+		return fmt.Errorf("invalid age %q", value)
+	}
+
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+
+	if unit, ok := units[value[len(value)-1]]; ok {
+		count, err := strconv.Atoi(value[:len(value)-1])
+		if err != nil || count < 0 {
+			return fmt.Errorf("invalid age %q", value)
+		}
+		*a = ageValue(time.Duration(count) * unit)
+		return nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return fmt.Errorf("invalid age %q", value)
+	}
+
+	*a = ageValue(duration)
+	return nil
+}
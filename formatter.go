@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"io"
+)
+
+// newFormatter returns the formatter of --format, which the command has
+// checked to be one of its formats. Listings printed as JSON or ndjson are
+// marked as partial after an interrupt.
+func newFormatter(format string, tmpl listTemplate) formatters.Formatter {
+
+	switch format {
+	case "", formatters.NameHuman:
+		return formatters.Human{}
+	case formatters.NameJson:
+		return formatters.Json{Partial: interrupted}
+	case formatters.NameNdjson:
+		return formatters.Ndjson{Partial: interrupted}
+	case formatPrometheus:
+		return prometheusFormatter{}
+	}
+
+	formatter, err := formatters.New(format, tmpl.parsed)
+	if err != nil {
+		fatalIf(usageErrorf("%s", err))
+	}
+
+	return formatter
+}
+
+// reportFormatter returns the formatter of the reports of get-env, like
+// --inspect-certs, which are printed as JSON with --format json and as a
+// table with any other format.
+func reportFormatter(format string) formatters.Formatter {
+
+	if format == formatJson {
+		return newFormatter(formatJson, listTemplate{})
+	}
+
+	return newFormatter(formatTable, listTemplate{})
+}
+
+// envFormatter returns the formatter of the env formats, which print YAML
+// and JSON like the other commands and leave the formats of env files, like
+// dotenv, to the result.
+func envFormatter(format string) formatters.Formatter {
+
+	if format == formatYaml || format == formatJson {
+		return newFormatter(format, listTemplate{})
+	}
+
+	return formatters.Human{}
+}
+
+// printResult prints the result of a command in the format of formatter.
+func printResult(out io.Writer, formatter formatters.Formatter, result interface{}) {
+	fatalIf(formatter.Format(out, result))
+}
@@ -0,0 +1,131 @@
+// Package formatters prints the results of the commands of the plugin in
+// the format chosen with --format, so that every command supporting a
+// format prints it the same way.
+package formatters
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// Names of the formats, as given to --format. The human format is a table
+// or whatever layout the result prints itself in, see Printable.
+const (
+	NameHuman    = "table"
+	NameJson     = "json"
+	NameYaml     = "yaml"
+	NameCsv      = "csv"
+	NameDotenv   = "dotenv"
+	NameNdjson   = "ndjson"
+	NameTemplate = "template"
+)
+
+// Names lists the formats of New.
+var Names = []string{NameHuman, NameJson, NameYaml, NameCsv, NameDotenv, NameNdjson, NameTemplate}
+
+// Formatter prints a result. Which results a formatter can print is told
+// by the interfaces they implement, the others fail.
+type Formatter interface {
+	Format(out io.Writer, result interface{}) error
+}
+
+// Tabular results have a row per element, printed aligned in columns by
+// Human and as CSV by Csv.
+type Tabular interface {
+	Header() []string
+	Rows() [][]string
+}
+
+// Printable results print themselves for people, for layouts a table can't
+// hold, like summary lines or values taking the rest of the terminal.
+type Printable interface {
+	Print(out io.Writer) error
+}
+
+// Listing results are lists of elements. Ndjson and Template print the
+// elements one per line, Json and Yaml print them as a list.
+type Listing interface {
+	Items() interface{}
+}
+
+// Variables are results holding environment variables, printed by Dotenv.
+type Variables interface {
+	Vars() map[string]string
+}
+
+// New returns the formatter of the format name. tmpl is the template of
+// Template, parsed from --template.
+func New(name string, tmpl *template.Template) (Formatter, error) {
+
+	switch name {
+	case NameHuman:
+		return Human{}, nil
+	case NameJson:
+		return Json{}, nil
+	case NameYaml:
+		return Yaml{}, nil
+	case NameCsv:
+		return Csv{}, nil
+	case NameDotenv:
+		return Dotenv{}, nil
+	case NameNdjson:
+		return Ndjson{}, nil
+	case NameTemplate:
+		if tmpl == nil {
+			return nil, fmt.Errorf("--format template needs --template or --template-file")
+		}
+		return Template{Template: tmpl}, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported format '%s'. Supported formats: %s", name, strings.Join(Names, ", "))
+}
+
+// Table is a Tabular result of fixed cells.
+type Table struct {
+	Columns []string
+	Cells   [][]string
+}
+
+func (t Table) Header() []string {
+	return t.Columns
+}
+
+func (t Table) Rows() [][]string {
+	return t.Cells
+}
+
+// unsupported is the error of printing result in a format it doesn't have.
+func unsupported(format string, result interface{}) error {
+	return fmt.Errorf("A %T can't be printed as %s", result, format)
+}
+
+// contentOf returns what is printed of result: the elements of Listing
+// results and the content of the results of Schema.Of.
+func contentOf(result interface{}) interface{} {
+
+	if wrapped, ok := result.(document); ok {
+		result = wrapped.content
+	}
+
+	if listing, ok := result.(Listing); ok {
+		return listing.Items()
+	}
+
+	return result
+}
+
+// items returns the elements of result, which is a Listing or a slice.
+func items(format string, result interface{}) (reflect.Value, error) {
+
+	result = contentOf(result)
+
+	values := reflect.ValueOf(result)
+	if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+		return reflect.Value{}, unsupported(format, result)
+	}
+
+	return values, nil
+}
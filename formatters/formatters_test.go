@@ -0,0 +1,175 @@
+package formatters_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/template"
+	"time"
+
+	. "github.com/thomaseizinger/cf-get-env-plugin/formatters"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type app struct {
+	Name  string `json:"name" yaml:"name"`
+	State string `json:"state" yaml:"state"`
+}
+
+// apps is a versioned result in all formats but dotenv.
+type apps []app
+
+func (a apps) Items() interface{} {
+	return []app(a)
+}
+
+func (a apps) Header() []string {
+	return []string{"name", "state"}
+}
+
+func (a apps) Rows() [][]string {
+
+	var rows [][]string
+	for _, app := range a {
+		rows = append(rows, []string{app.Name, app.State})
+	}
+
+	return rows
+}
+
+func (a apps) Schema() Schema {
+	return appsSchema
+}
+
+var appsSchema = Schema{Name: "apps", Version: 2, Results: []app{}}
+
+type summary struct {
+	count int
+}
+
+func (s summary) Print(out io.Writer) error {
+	_, err := fmt.Fprintf(out, "%d apps\n", s.count)
+	return err
+}
+
+type variables map[string]string
+
+func (v variables) Vars() map[string]string {
+	return v
+}
+
+func format(formatter Formatter, result interface{}) string {
+
+	out := &bytes.Buffer{}
+	Expect(formatter.Format(out, result)).To(Succeed())
+
+	return out.String()
+}
+
+var _ = Describe("Formatters", func() {
+
+	listed := apps{{Name: "web", State: "STARTED"}, {Name: "worker, eu", State: "STOPPED"}}
+
+	It("prints tabular results aligned in columns", func() {
+		Expect(format(Human{}, listed)).To(Equal("name         state\nweb          STARTED\nworker, eu   STOPPED\n"))
+	})
+
+	It("prints printable results their own way", func() {
+		Expect(format(Human{}, summary{count: 2})).To(Equal("2 apps\n"))
+	})
+
+	It("prints versioned listings as an indented JSON array under the version", func() {
+		Expect(format(Json{}, listed)).To(Equal("{\n  \"schema_version\": 2,\n  \"apps\": [\n    {\n      \"name\": \"web\",\n      \"state\": \"STARTED\"\n    },\n    {\n      \"name\": \"worker, eu\",\n      \"state\": \"STOPPED\"\n    }\n  ]\n}\n"))
+	})
+
+	It("prints other results as they are", func() {
+		Expect(format(Json{}, []app(nil))).To(Equal("[]\n"))
+		Expect(format(Json{}, map[string]int{"a": 1})).To(Equal("{\n  \"a\": 1\n}\n"))
+	})
+
+	It("prints results of a schema under the version", func() {
+		vars := Schema{Name: "env", Version: 1}.Of(map[string]string{"PORT": "8080"})
+		Expect(format(Json{}, vars)).To(Equal("{\n  \"schema_version\": 1,\n  \"env\": {\n    \"PORT\": \"8080\"\n  }\n}\n"))
+		Expect(format(Yaml{}, vars)).To(Equal("PORT: \"8080\"\n"))
+	})
+
+	It("prints empty listings as an empty JSON array", func() {
+		Expect(format(Json{}, apps(nil))).To(Equal("{\n  \"schema_version\": 2,\n  \"apps\": []\n}\n"))
+	})
+
+	It("marks partial listings as partial", func() {
+		partial := func() bool { return true }
+		Expect(format(Json{Partial: partial}, apps(nil))).To(Equal("{\n  \"schema_version\": 2,\n  \"partial\": true,\n  \"apps\": []\n}\n"))
+		Expect(format(Json{Partial: partial}, []app(nil))).To(Equal("{\n  \"partial\": true,\n  \"results\": []\n}\n"))
+		Expect(format(Ndjson{Partial: partial}, listed[:1])).To(Equal("{\"name\":\"web\",\"state\":\"STARTED\"}\n{\"partial\":true}\n"))
+	})
+
+	It("derives the JSON Schema of the output from the type of the results", func() {
+		type record struct {
+			Name    string            `json:"name"`
+			Labels  map[string]string `json:"labels,omitempty"`
+			Created time.Time         `json:"created"`
+			Ignored string            `json:"-"`
+		}
+
+		schema := Schema{Name: "apps", Version: 3, Results: []record{}}.JSONSchema()
+		Expect(schema).To(HaveKeyWithValue("required", []string{"schema_version", "apps"}))
+
+		properties := schema["properties"].(map[string]interface{})
+		Expect(properties).To(HaveKeyWithValue("schema_version", map[string]interface{}{"const": 3}))
+		Expect(properties).To(HaveKey("partial"))
+		Expect(properties).To(HaveKeyWithValue("apps", map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name":    map[string]interface{}{"type": "string"},
+					"labels":  map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}},
+					"created": map[string]interface{}{"type": "string", "format": "date-time"},
+				},
+				"required": []string{"name", "created"},
+			},
+		}))
+	})
+
+	It("prints listings as a YAML sequence", func() {
+		Expect(format(Yaml{}, listed[:1])).To(Equal("- name: web\n  state: STARTED\n"))
+	})
+
+	It("prints tabular results as CSV, quoting where needed", func() {
+		Expect(format(Csv{}, listed)).To(Equal("name,state\r\nweb,STARTED\r\n\"worker, eu\",STOPPED\r\n"))
+	})
+
+	It("prints the elements of listings as a JSON object per line", func() {
+		Expect(format(Ndjson{}, listed)).To(Equal("{\"name\":\"web\",\"state\":\"STARTED\"}\n{\"name\":\"worker, eu\",\"state\":\"STOPPED\"}\n"))
+		Expect(format(Ndjson{}, apps(nil))).To(BeEmpty())
+	})
+
+	It("executes the template for every element", func() {
+		tmpl := template.Must(template.New("app").Parse("{{.Name}}: {{.State}}"))
+		Expect(format(Template{Template: tmpl}, listed)).To(Equal("web: STARTED\nworker, eu: STOPPED\n"))
+	})
+
+	It("prints variables as sorted dotenv lines", func() {
+		Expect(format(Dotenv{}, variables{"PORT": "8080", "GREETING": "hello world"})).To(Equal("GREETING=\"hello world\"\nPORT=8080\n"))
+	})
+
+	It("fails for results that lack the format", func() {
+		Expect(Csv{}.Format(&bytes.Buffer{}, summary{count: 2})).To(MatchError("A formatters_test.summary can't be printed as csv"))
+		Expect(Dotenv{}.Format(&bytes.Buffer{}, listed)).To(HaveOccurred())
+	})
+
+	It("looks formatters up by name", func() {
+		formatter, err := New(NameCsv, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(formatter).To(Equal(Csv{}))
+
+		_, err = New("xml", nil)
+		Expect(err).To(MatchError("Unsupported format 'xml'. Supported formats: table, json, yaml, csv, dotenv, ndjson, template"))
+
+		_, err = New(NameTemplate, nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,33 @@
+package formatters
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Human prints Printable results their way and Tabular ones as a table,
+// the header above the rows.
+type Human struct{}
+
+func (Human) Format(out io.Writer, result interface{}) error {
+
+	if printable, ok := result.(Printable); ok {
+		return printable.Print(out)
+	}
+
+	tabular, ok := result.(Tabular)
+	if !ok {
+		return unsupported(NameHuman, result)
+	}
+
+	table := tabwriter.NewWriter(out, 0, 4, 3, ' ', 0)
+
+	fmt.Fprintln(table, strings.Join(tabular.Header(), "\t"))
+	for _, row := range tabular.Rows() {
+		fmt.Fprintln(table, strings.Join(row, "\t"))
+	}
+
+	return table.Flush()
+}
@@ -0,0 +1,74 @@
+package formatters
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Json prints result indented, the elements of Listing results as an array.
+// Versioned results are printed under their schema.
+type Json struct {
+	// Partial, if set, tells whether the listing was cut short, which is
+	// then marked in the output, so that tools can't take it for the whole
+	// result.
+	Partial func() bool
+}
+
+type partialResults struct {
+	Partial bool        `json:"partial"`
+	Results interface{} `json:"results"`
+}
+
+func (f Json) Format(out io.Writer, result interface{}) error {
+
+	encoded, err := json.MarshalIndent(f.document(result), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = out.Write(append(encoded, '\n'))
+	return err
+}
+
+func (f Json) document(result interface{}) interface{} {
+
+	content := contentOf(result)
+
+	values := reflect.ValueOf(content)
+	list := values.Kind() == reflect.Slice
+
+	// An empty list is an empty array rather than null.
+	if list && values.IsNil() {
+		content = []interface{}{}
+	}
+
+	partial := list && f.Partial != nil && f.Partial()
+
+	if versioned, ok := result.(Versioned); ok {
+		return envelope{schema: versioned.Schema(), partial: partial, content: content}
+	}
+
+	if partial {
+		return partialResults{Partial: true, Results: content}
+	}
+
+	return content
+}
+
+// Yaml prints result as a YAML document, the elements of Listing results
+// as a sequence.
+type Yaml struct{}
+
+func (Yaml) Format(out io.Writer, result interface{}) error {
+
+	encoded, err := yaml.Marshal(contentOf(result))
+	if err != nil {
+		return err
+	}
+
+	_, err = out.Write(encoded)
+	return err
+}
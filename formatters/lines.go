@@ -0,0 +1,157 @@
+package formatters
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Csv prints Tabular results as RFC 4180 CSV, quoting values that contain
+// commas, quotes or line breaks.
+type Csv struct{}
+
+func (Csv) Format(out io.Writer, result interface{}) error {
+
+	tabular, ok := result.(Tabular)
+	if !ok {
+		return unsupported(NameCsv, result)
+	}
+
+	writer := csv.NewWriter(out)
+	writer.UseCRLF = true
+
+	if err := writer.Write(tabular.Header()); err != nil {
+		return err
+	}
+
+	if err := writer.WriteAll(tabular.Rows()); err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
+
+// Ndjson prints the elements of Listing results and slices as a JSON object
+// per line. An empty list prints nothing.
+type Ndjson struct {
+	// Partial, if set, tells whether the listing was cut short, which is
+	// then marked by a last line of {"partial":true}.
+	Partial func() bool
+}
+
+// PartialMarker is the last line of ndjson output that was cut short, also
+// for the streams that print their lines themselves.
+type PartialMarker struct {
+	Partial bool `json:"partial"`
+}
+
+func (f Ndjson) Format(out io.Writer, result interface{}) error {
+
+	values, err := items(NameNdjson, result)
+	if err != nil {
+		return err
+	}
+
+	buffered := bufio.NewWriter(out)
+
+	for i := 0; i < values.Len(); i++ {
+		if err := writeLine(buffered, values.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+
+	if f.Partial != nil && f.Partial() {
+		if err := writeLine(buffered, PartialMarker{Partial: true}); err != nil {
+			return err
+		}
+	}
+
+	return buffered.Flush()
+}
+
+func writeLine(out io.Writer, value interface{}) error {
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	_, err = out.Write(append(encoded, '\n'))
+	return err
+}
+
+// Template executes its template for every element of Listing results and
+// slices, each on its own line.
+type Template struct {
+	Template *template.Template
+}
+
+func (f Template) Format(out io.Writer, result interface{}) error {
+
+	values, err := items(NameTemplate, result)
+	if err != nil {
+		return err
+	}
+
+	buffered := bufio.NewWriter(out)
+
+	for i := 0; i < values.Len(); i++ {
+		if err := f.Template.Execute(buffered, values.Index(i).Interface()); err != nil {
+			return err
+		}
+		buffered.WriteString("\n")
+	}
+
+	return buffered.Flush()
+}
+
+// Dotenv prints Variables as KEY=value lines, sorted by key.
+type Dotenv struct{}
+
+func (Dotenv) Format(out io.Writer, result interface{}) error {
+
+	variables, ok := result.(Variables)
+	if !ok {
+		return unsupported(NameDotenv, result)
+	}
+
+	vars := variables.Vars()
+
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if _, err := fmt.Fprintf(out, "%s=%s\n", key, QuoteDotenv(vars[key])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var dotenvEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"$", `\$`,
+	"\n", `\n`,
+	"\r", `\r`,
+)
+
+// QuoteDotenv leaves simple values bare and double-quotes everything else, so
+// that `#` is not read as a comment and multi-line values survive a round trip.
+func QuoteDotenv(value string) string {
+
+	if value != "" && !strings.ContainsAny(value, " \t\n\r#\"'`$\\=") {
+		return value
+	}
+
+	return `"` + dotenvEscaper.Replace(value) + `"`
+}
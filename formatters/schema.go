@@ -0,0 +1,227 @@
+package formatters
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is the schema of the JSON output of a command. Json prints results
+// of a schema as an object holding the version and the results under Name,
+// e.g. {"schema_version": 1, "apps": [...]}. Fields are only ever added to a
+// version; anything else a consumer could trip over raises it.
+type Schema struct {
+	Name    string
+	Version int
+
+	// Results is a value of the type of the results, from which JSONSchema
+	// takes their schema.
+	Results interface{}
+}
+
+// Versioned results are printed by Json under their schema.
+type Versioned interface {
+	Schema() Schema
+}
+
+// Of returns content as a result of the schema, for results that don't have
+// a type of their own.
+func (s Schema) Of(content interface{}) Versioned {
+	return document{schema: s, content: content}
+}
+
+type document struct {
+	schema  Schema
+	content interface{}
+}
+
+func (d document) Schema() Schema {
+	return d.schema
+}
+
+// isList tells whether the results are a list, which Json marks as partial
+// when cut short.
+func (s Schema) isList() bool {
+	kind := reflect.ValueOf(s.Results).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
+// envelope is the JSON object of versioned results. Its fields are written
+// in order, the version first.
+type envelope struct {
+	schema  Schema
+	partial bool
+	content interface{}
+}
+
+func (e envelope) MarshalJSON() ([]byte, error) {
+
+	name, err := json.Marshal(e.schema.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.Marshal(e.content)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := &bytes.Buffer{}
+	fmt.Fprintf(encoded, `{"schema_version":%d,`, e.schema.Version)
+	if e.partial {
+		encoded.WriteString(`"partial":true,`)
+	}
+	encoded.Write(name)
+	encoded.WriteString(":")
+	encoded.Write(content)
+	encoded.WriteString("}")
+
+	return encoded.Bytes(), nil
+}
+
+// JSONSchema returns the JSON Schema of the output of s, for consumers to
+// validate it with.
+func (s Schema) JSONSchema() map[string]interface{} {
+
+	properties := map[string]interface{}{
+		"schema_version": map[string]interface{}{"const": s.Version},
+		s.Name:           typeSchema(reflect.TypeOf(s.Results)),
+	}
+
+	if s.isList() {
+		properties["partial"] = map[string]interface{}{
+			"type":        "boolean",
+			"description": "Set if the command was interrupted before it listed all results",
+		}
+	}
+
+	return map[string]interface{}{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"type":       "object",
+		"properties": properties,
+		"required":   []string{"schema_version", s.Name},
+	}
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	bytesType     = reflect.TypeOf([]byte(nil))
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// typeSchema returns the JSON Schema of the values of t as encoding/json
+// encodes them. Types that encode themselves can be anything.
+func typeSchema(t reflect.Type) map[string]interface{} {
+
+	if t == nil {
+		return map[string]interface{}{}
+	}
+
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	if t.Implements(jsonMarshaler) || reflect.PtrTo(t).Implements(jsonMarshaler) {
+		return map[string]interface{}{}
+	}
+
+	if t.Implements(textMarshaler) || reflect.PtrTo(t).Implements(textMarshaler) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t == bytesType {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+
+	return map[string]interface{}{}
+}
+
+// structSchema returns the schema of the JSON object of a struct, with the
+// fields of embedded structs promoted like encoding/json does. Fields
+// without omitempty are required.
+func structSchema(t reflect.Type) map[string]interface{} {
+
+	properties := map[string]interface{}{}
+	required := []string{}
+
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+
+			tag := field.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+
+			options := strings.Split(tag, ",")
+			name := options[0]
+
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+
+			if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+				addFields(fieldType)
+				continue
+			}
+
+			if field.PkgPath != "" {
+				continue
+			}
+
+			if name == "" {
+				name = field.Name
+			}
+
+			properties[name] = typeSchema(field.Type)
+
+			if !hasOption(options[1:], "omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	addFields(t)
+
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+func hasOption(options []string, option string) bool {
+
+	for _, candidate := range options {
+		if candidate == option {
+			return true
+		}
+	}
+
+	return false
+}
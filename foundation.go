@@ -0,0 +1,190 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"encoding/json"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// foundation is a Cloud Controller to run requests against, the targeted
+// one or another one reached with a token, like the sides of
+// `cf diff-env --target-a API --target-b API APP`.
+type foundation struct {
+	// name is the host of the API endpoint, shown next to app names.
+	name     string
+	endpoint string
+	client   *ccclient.Client
+
+	// connection is set for the targeted foundation, whose apps are
+	// looked up by the CLI.
+	connection plugin.CliConnection
+
+	// scope is the space of a saved target apps are looked up in, called
+	// space in org. Without it, apps are looked up in all spaces the token
+	// can see.
+	scope      ccclient.AppFilters
+	org, space string
+}
+
+// cfConfigModel holds the fields of a CLI config.json the plugin reads.
+type cfConfigModel struct {
+	Target                string
+	AccessToken           string
+	RefreshToken          string
+	UaaEndpoint           string
+	AuthorizationEndpoint string
+	UAAOAuthClient        string
+	UAAOAuthClientSecret  string
+	SSLDisabled           bool
+	OrganizationFields    struct{ GUID, Name string }
+	SpaceFields           struct{ GUID, Name string }
+}
+
+// openFoundation returns the foundation of target, the targeted one if
+// target is empty. target is an API endpoint or the name of a target saved
+// with the cf-targets plugin. The token, if not given, comes from a saved
+// target or CLI config of that endpoint. tokenFlag names the flag of the
+// token in errors.
+func openFoundation(cliConnection plugin.CliConnection, target string, token string, tokenFlag string) (foundation, error) {
+
+	current, _ := cliConnection.ApiEndpoint()
+
+	if target == "" || (token == "" && sameEndpoint(target, current)) {
+		return foundation{name: endpointHost(current), endpoint: current, client: ccClient(cliConnection), connection: cliConnection}, nil
+	}
+
+	config, found := savedTarget(target)
+
+	if token != "" {
+		endpoint := normalizeEndpoint(target)
+		if found {
+			endpoint = config.Target
+		}
+
+		return foundation{name: endpointHost(endpoint), endpoint: endpoint, client: newClient(ccclient.NewTokenTransport(endpoint, token, config.SSLDisabled))}, nil
+	}
+
+	if !found || config.AccessToken == "" {
+		return foundation{}, usageErrorf("No saved login for %s, log in with `CF_HOME=DIR cf login -a %s` or pass %s", target, normalizeEndpoint(target), tokenFlag)
+	}
+
+	return foundation{
+		name:     endpointHost(config.Target),
+		endpoint: config.Target,
+		client:   newClient(savedLoginTransport(config)),
+		scope:    ccclient.AppFilters{OrgGuid: config.OrganizationFields.GUID, SpaceGuid: config.SpaceFields.GUID},
+		org:      config.OrganizationFields.Name,
+		space:    config.SpaceFields.Name,
+	}, nil
+}
+
+// savedLoginTransport sends requests with the token of a saved login, and
+// refreshes it with the UAA like the CLI would once it expires mid-scan.
+func savedLoginTransport(config cfConfigModel) ccclient.Transport {
+
+	transport := ccclient.NewTokenTransport(config.Target, config.AccessToken, config.SSLDisabled)
+
+	uaa := config.UaaEndpoint
+	if uaa == "" {
+		uaa = config.AuthorizationEndpoint
+	}
+
+	if direct, ok := transport.(*ccclient.HTTPTransport); ok && config.RefreshToken != "" && uaa != "" {
+		refresher := ccclient.NewUAARefresher(uaa, config.RefreshToken, config.SSLDisabled)
+		if config.UAAOAuthClient != "" {
+			refresher.ClientID = config.UAAOAuthClient
+			refresher.ClientSecret = config.UAAOAuthClientSecret
+		}
+
+		direct.Refresh = func() (string, error) {
+			verbosef("Refreshing the access token of %s", endpointHost(config.Target))
+			return refresher.Refresh()
+		}
+	}
+
+	return transport
+}
+
+// savedTarget finds the CLI config of target: the target of that name
+// saved by the cf-targets plugin in ~/.cf/targets, or a saved target or
+// the config of the CLI logged in to that endpoint.
+func savedTarget(target string) (cfConfigModel, bool) {
+
+	targetsDir := filepath.Join(cfDir(), "targets")
+
+	if config, err := readCfConfig(filepath.Join(targetsDir, target+".config.json")); err == nil {
+		return config, true
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(targetsDir, "*.config.json"))
+	paths = append(paths, filepath.Join(cfDir(), "config.json"))
+
+	for _, path := range paths {
+		if config, err := readCfConfig(path); err == nil && sameEndpoint(config.Target, target) {
+			return config, true
+		}
+	}
+
+	return cfConfigModel{}, false
+}
+
+func readCfConfig(path string) (cfConfigModel, error) {
+
+	var config cfConfigModel
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+
+	return config, json.Unmarshal(data, &config)
+}
+
+// normalizeEndpoint adds the https:// the CLI assumes for endpoints given
+// without scheme, like `cf api api.example.com`.
+func normalizeEndpoint(endpoint string) string {
+
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	return strings.TrimSuffix(endpoint, "/")
+}
+
+func sameEndpoint(a string, b string) bool {
+	return a != "" && b != "" && strings.EqualFold(normalizeEndpoint(a), normalizeEndpoint(b))
+}
+
+func endpointHost(endpoint string) string {
+
+	parsed, err := url.Parse(normalizeEndpoint(endpoint))
+	if err != nil || parsed.Host == "" {
+		return endpoint
+	}
+
+	return parsed.Host
+}
+
+// appEnv returns the user-provided variables of the app.
+func (f foundation) appEnv(appName string) (map[string]interface{}, error) {
+
+	if f.connection != nil {
+		return userProvidedEnv(fetchEnv(f.connection, appName)), nil
+	}
+
+	guid, err := f.client.FindGuid("App", appName, "v2/apps", f.scope.V2Filters()...)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := f.client.GetAppEnv(guid)
+	if err != nil {
+		return nil, err
+	}
+
+	return userProvidedEnv(env), nil
+}
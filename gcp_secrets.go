@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// gcpSecretManager talks to Google Cloud Secret Manager with the access token
+// in GOOGLE_OAUTH_ACCESS_TOKEN or, without it, the one of gcloud.
+type gcpSecretManager struct {
+	endpoint string
+	project  string
+	token    string
+	client   *http.Client
+}
+
+func newGcpSecretManager(options storeOptions) (*gcpSecretManager, error) {
+
+	project := firstNonEmpty(options.gcpProject, os.Getenv("GOOGLE_CLOUD_PROJECT"), os.Getenv("CLOUDSDK_CORE_PROJECT"))
+
+	if project == "" {
+		return nil, errors.New("The Google Cloud project must be given with --gcp-project or GOOGLE_CLOUD_PROJECT")
+	}
+
+	token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+
+	if token == "" {
+		output, err := exec.Command("gcloud", "auth", "print-access-token").Output()
+		if err != nil {
+			return nil, fmt.Errorf("Set GOOGLE_OAUTH_ACCESS_TOKEN or log in with gcloud: %s", err)
+		}
+		token = strings.TrimSpace(string(output))
+	}
+
+	return &gcpSecretManager{
+		endpoint: strings.TrimSuffix(firstNonEmpty(options.endpoint, "https://secretmanager.googleapis.com"), "/"),
+		project:  project,
+		token:    token,
+		client:   &http.Client{Timeout: globals.timeout},
+	}, nil
+}
+
+func (g *gcpSecretManager) writeVars(name string, vars map[string]string) error {
+
+	encoded, err := json.Marshal(vars)
+
+	if err != nil {
+		return err
+	}
+
+	return g.writeValue(name, string(encoded))
+}
+
+// writeValue adds a version to the secret, creating the secret with
+// automatic replication if it doesn't exist yet.
+func (g *gcpSecretManager) writeValue(name string, value string) error {
+
+	secret := fmt.Sprintf("/v1/projects/%s/secrets/%s", url.PathEscape(g.project), url.PathEscape(name))
+	version := map[string]interface{}{
+		"payload": map[string]string{"data": base64.StdEncoding.EncodeToString([]byte(value))},
+	}
+
+	status, _, err := g.do("POST", secret+":addVersion", version)
+
+	if status == http.StatusNotFound {
+		create := fmt.Sprintf("/v1/projects/%s/secrets?secretId=%s", url.PathEscape(g.project), url.QueryEscape(name))
+
+		if _, _, err = g.do("POST", create, map[string]interface{}{"replication": map[string]interface{}{"automatic": map[string]interface{}{}}}); err != nil {
+			return err
+		}
+
+		_, _, err = g.do("POST", secret+":addVersion", version)
+	}
+
+	return err
+}
+
+// readVars returns the variables of the latest version of the secret, which
+// must be a JSON object like the ones written by writeVars.
+func (g *gcpSecretManager) readVars(name string) (map[string]string, error) {
+
+	latest := fmt.Sprintf("/v1/projects/%s/secrets/%s/versions/latest:access", url.PathEscape(g.project), url.PathEscape(name))
+
+	status, data, err := g.do("GET", latest, nil)
+
+	if status == http.StatusNotFound {
+		return nil, &ccclient.NotFoundError{Kind: "Secret", Name: name}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var version struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(version.Payload.Data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeSecretVars(name, payload)
+}
+
+// keyName joins with _, because secret ids may not contain /.
+func (g *gcpSecretManager) keyName(name string, key string) string {
+	return name + "_" + key
+}
+
+func (g *gcpSecretManager) do(method string, path string, body interface{}) (int, []byte, error) {
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequest(method, g.endpoint+path, reader)
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	request.Header.Set("Authorization", "Bearer "+g.token)
+	request.Header.Set("Content-Type", "application/json")
+
+	verbosef("%s %s", method, g.endpoint+path)
+
+	response, err := g.client.Do(request)
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer response.Body.Close()
+
+	data, err := ioutil.ReadAll(response.Body)
+
+	if err != nil {
+		return response.StatusCode, nil, err
+	}
+
+	if response.StatusCode >= 400 {
+		var gcpErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(data, &gcpErr)
+		return response.StatusCode, nil, fmt.Errorf("Google Cloud responded with status %d: %s", response.StatusCode, gcpErr.Error.Message)
+	}
+
+	return response.StatusCode, data, nil
+}
@@ -2,16 +2,52 @@ package main
 
 import (
 	"code.cloudfoundry.org/cli/plugin"
+	"code.cloudfoundry.org/cli/plugin/models"
+	"context"
+	"flag"
 	"fmt"
 	"github.com/gdey/jsonpath"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
 	"os"
 	"strings"
-	"encoding/json"
+	"time"
 )
 
 type GetEnvPlugin struct {
-	appName    string
-	applicator jsonpath.Applicator
+	appName     string
+	applicator  jsonpath.Applicator
+	format      string
+	out         string
+	redact      bool
+	showSecrets bool
+	org         string
+	space       string
+	watch       bool
+	interval    time.Duration
+	section     string
+	name        string
+	namespace   string
+	mergeInto   string
+	completion  string
+	key         string
+	noNewline   bool
+	query       string
+	appsFile    string
+	fromStdin   bool
+	appNames    []string
+	concurrency int
+	credHub     bool
+	live        bool
+	failPending bool
+	decode      string
+	certs       bool
+	urls        bool
+	refresh     bool
+	version     bool
+	notify      notifier
+
+	// ui is the UI handed to Execute, for the helpers of the command.
+	ui UI
 }
 
 func main() {
@@ -20,20 +56,128 @@ func main() {
 
 func (p *GetEnvPlugin) Run(cliConnection plugin.CliConnection, args []string) {
 
+	p.run(&terminalUI{out: os.Stdout, errOut: os.Stderr}, cliConnection, args)
+}
+
+// run runs the command of args, printing through ui. Failures of the
+// command are reported through ui as well.
+func (p *GetEnvPlugin) run(ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	defer reportFailure(ui)
+
 	if len(args) > 0 && args[0] == "CLI-MESSAGE-UNINSTALL" {
 		return
 	}
 
-	switch args[0] {
-	case "get-env":
+	// Completion runs while a command line is being typed, so its arguments
+	// may be incomplete global flags.
+	if len(args) > 0 && args[0] == completeCommand {
+		p.completeArgsCommand(ui, cliConnection, args[1:])
+		return
+	}
+
+	args = extractGlobalFlags(args)
+	setupColor(ui)
+
+	stopMaxDuration := enforceMaxDuration(ui)
+	defer stopMaxDuration()
+
+	p.execute(ui, cliConnection, args)
+}
+
+// Execute runs get-env, of which the plugin holds the flags.
+func (p *GetEnvPlugin) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	p.ui = ui
+	p.setup(args)
+
+	if p.version {
+		writeVersion(ui.Out())
+		return
+	}
+
+	if p.completion != "" {
+		fatalIf(writeCompletion(ui.Out(), p.completion))
+		return
+	}
+
+	if len(p.appNames) > 1 {
+		p.getEnvs(cliConnection)
+		return
+	}
+
+	if p.appName == "" {
+		p.appName = pickApp(cliConnection, p.org, p.space)
+	}
+
+	guid := p.appGuid(cliConnection)
+
+	if p.watch {
+		p.watchEnv(cliConnection, guid)
+		return
+	}
+
+	env := p.fetchEnv(cliConnection, p.appName, guid)
+
+	if p.live {
+		live, err := liveEnv(cliConnection, p.appName)
+		fatalIf(err)
+
+		writeLiveDiff(ui.Out(), p.appName, userProvidedEnv(env), live, p.shouldRedact())
+		return
+	}
+
+	// The check costs requests, so it only runs when asked to or when
+	// someone reads the env on a terminal.
+	if p.failPending || (outputIsTerminal(ui) && !globals.quiet) {
+		warnPendingRestage(ui, cliConnection, p.appName, guid, p.failPending)
+	}
+
+	// Redacting first would mask the encoded value, so the decoded
+	// value is masked instead.
+	if p.decode != "" {
+		p.writeDecodedKey(env)
+		return
+	}
+
+	if p.certs {
+		p.writeCertificates(env)
+		return
+	}
+
+	if p.urls {
+		p.writeURLSummary(env)
+		return
+	}
+
+	if p.shouldRedact() {
+		env = redactEnv(env)
+	}
+
+	if p.mergeInto != "" {
+		fatalIf(mergeIntoManifest(p.mergeInto, p.appName, p.selectedVars(env)))
+		ui.Say("Updated env of %s in %s", p.appName, p.mergeInto)
+		return
+	}
+
+	if p.key != "" {
+		p.writeKey(ui.Out(), env)
+		return
+	}
 
-		p.setup(args)
+	if p.applicator == nil {
+		p.writeEnv(env)
+		return
+	}
 
-		env := p.fetchEnv(cliConnection)
-		selectedValue := p.selectValue(env)
+	selectedValue := p.selectValue(env)
 
-		fmt.Print(selectedValue)
+	if p.query != "" {
+		ui.Say("%s", formatEnvValue(selectedValue))
+		return
 	}
+
+	fmt.Fprint(ui.Out(), selectedValue)
 }
 
 func (p *GetEnvPlugin) selectValue(env map[string]interface{}) interface{} {
@@ -41,96 +185,409 @@ func (p *GetEnvPlugin) selectValue(env map[string]interface{}) interface{} {
 	selectedValue, jsonPathError := p.applicator.Apply(env)
 
 	if jsonPathError != nil {
-		msg, _ := fmt.Printf("Failed to apply JSON path: %s", jsonPathError)
-		fmt.Println(msg)
-		os.Exit(1)
+		msg, _ := fmt.Fprintf(p.ui.Out(), "Failed to apply JSON path: %s", jsonPathError)
+		fmt.Fprintln(p.ui.Out(), msg)
+		exit(1)
 	}
 
 	return selectedValue
 }
 
-func (p *GetEnvPlugin) setup(args []string) {
+func (p *GetEnvPlugin) Name() string {
+	return "get-env"
+}
 
-	if len(args) < 2 {
-		fmt.Println("App name must be provided")
-		os.Exit(1)
+func (p *GetEnvPlugin) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "get-env",
+		HelpText: "Get value from the environment from an env by a JSON path expression, or the raw value of the variable KEY. Without either, lists the user-provided environment variables, of several apps if more names are given. KEY is upper case and JSON_PATH starts with $, other arguments are app names. Without an app name, lets you pick the app on a terminal.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf get-env [APP_NAME...] [KEY [-n] | JSON_PATH | --query QUERY] [--apps-file FILE | --stdin] [--concurrency N] [--format table|dotenv|shell|yaml|json|compose|tfvars|tf-map|manifest|k8s-secret|k8s-configmap [--name NAME] [--namespace NAMESPACE]] [--section user|staging|running|system|all] [--out FILE | --merge-into MANIFEST] [--redact | --show-secrets] [--resolve-credhub] [--org ORG] [--space SPACE] [--watch [--interval 30s] [--notify-url URL] [--notify-slack URL] [--notify-template TEMPLATE]] [--live] [--fail-on-pending-restage] [--refresh] [--decode-base64 KEY] [--inspect-certs | --summarize-urls] [--completion bash|zsh|fish] [--version]",
+			Options: map[string]string{
+				"watch":                   "Poll the environment and print changes as they happen",
+				"notify-url":              "With --watch, POST every change as JSON to the webhook URL. Only keys are sent, never values",
+				"notify-slack":            "With --watch, post every change to the Slack incoming webhook URL",
+				"notify-template":         "Go text/template of the message of notifications, e.g. '{{.App}}: {{join .Changed \", \"}}'",
+				"interval":                "Time between polls in watch mode (default 30s)",
+				"format":                  "Output format. Without --section, yaml and json print all sections of the environment, the others the user-provided variables (default table)",
+				"section":                 "Print the variables of one section of the environment, or every section with 'all'",
+				"name":                    "Name of the Kubernetes Secret or ConfigMap (default APP_NAME) or of the Terraform map (default env)",
+				"namespace":               "Namespace of the Kubernetes Secret or ConfigMap",
+				"out":                     "Write the environment to FILE instead of stdout",
+				"merge-into":              "Replace the env of the app in the manifest file MANIFEST. Comments of the file are not kept",
+				"redact":                  "Mask secret values. Default when printing to a terminal",
+				"show-secrets":            "Print secret values on a terminal",
+				"org":                     "Look up the app in ORG instead of the targeted org",
+				"space":                   "Look up the app in SPACE instead of the targeted space",
+				"n":                       "Print the value of KEY without a trailing newline",
+				"apps-file":               "Also get the env of the apps in FILE, one name per line",
+				"resolve-credhub":         "Replace CredHub references like ((/path/to/cred)) and credhub-ref credentials with the credentials, which are masked unless --show-secrets is given",
+				"concurrency":             "Number of envs of several apps fetched at the same time (default 4)",
+				"query":                   "Print the value at the JSON path QUERY of the env document, strings raw and other values as JSON. A jq-style QUERY like .environment_json.KEY works too",
+				"live":                    "Compare the user-provided variables to the env of a running instance, read with `cf ssh APP -c env`, to find changes that need a restage",
+				"fail-on-pending-restage": "Fail if the env changed after the instances were started, so that they don't see the changes yet. Without it, this is a warning on a terminal",
+				"refresh":                 "Look up the app again instead of using its cached GUID, and cache the new one",
+				"decode-base64":           "Print the base64-decoded value of the variable KEY, e.g. a certificate. Binary values like keystores need --out FILE",
+				"inspect-certs":           "Print the subject, issuer, SANs and expiry of the PEM certificates in the variables, also base64-encoded ones and those in JSON values like VCAP_SERVICES. Takes --format json",
+				"summarize-urls":          "Print the scheme, host, port and database of the URLs and connection strings in the variables, like DATABASE_URL, without their credentials. Takes --format json",
+				"completion":              "Print the shell completion script, e.g. `source <(cf get-env --completion bash)`",
+				"stdin":                   "Also get the env of the apps named on stdin, one per line, e.g. `cat apps.txt | cf get-env --stdin`",
+				"version":                 "Print the version and commit of the plugin",
+			},
+		},
 	}
+}
+
+func (p *GetEnvPlugin) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("get-env")
+	flags.StringVar(&p.format, "format", formatTable, "")
+	flags.StringVar(&p.out, "out", "", "")
+	flags.BoolVar(&p.redact, "redact", false, "")
+	flags.BoolVar(&p.showSecrets, "show-secrets", false, "")
+	flags.StringVar(&p.org, "org", "", "")
+	flags.StringVar(&p.space, "space", "", "")
+	flags.BoolVar(&p.watch, "watch", false, "")
+	flags.DurationVar(&p.interval, "interval", 30*time.Second, "")
+	flags.StringVar(&p.section, "section", "", "")
+	flags.StringVar(&p.name, "name", "", "")
+	flags.StringVar(&p.namespace, "namespace", "", "")
+	flags.StringVar(&p.mergeInto, "merge-into", "", "")
+	flags.StringVar(&p.completion, "completion", "", "")
+	flags.BoolVar(&p.noNewline, "n", false, "")
+	flags.StringVar(&p.query, "query", "", "")
+	flags.StringVar(&p.appsFile, "apps-file", "", "")
+	flags.BoolVar(&p.fromStdin, "stdin", false, "")
+	flags.IntVar(&p.concurrency, "concurrency", 4, "")
+	flags.BoolVar(&p.credHub, "resolve-credhub", false, "")
+	flags.BoolVar(&p.live, "live", false, "")
+	flags.BoolVar(&p.failPending, "fail-on-pending-restage", false, "")
+	flags.StringVar(&p.decode, "decode-base64", "", "")
+	flags.BoolVar(&p.certs, "inspect-certs", false, "")
+	flags.BoolVar(&p.urls, "summarize-urls", false, "")
+	flags.BoolVar(&p.refresh, "refresh", false, "")
+	flags.BoolVar(&p.version, "version", false, "")
+	p.notify.register(flags)
+
+	return flags
+}
+
+// setup validates the flags and the positional arguments of get-env.
+func (p *GetEnvPlugin) setup(positional []string) {
 
-	p.appName = args[1]
+	fatalIf(p.notify.validate())
 
-	if len(args) < 3 {
-		fmt.Println("JSON-Path expression must be provided")
-		os.Exit(1)
+	if p.notify.enabled() && !p.watch {
+		failUsage("--notify-url and --notify-slack need --watch")
 	}
 
-	applicator, parseErr := jsonpath.Parse(args[2])
+	cache.refresh = p.refresh
 
-	if parseErr != nil {
-		msg, _ := fmt.Printf("Failed to parse argument '%s' as valid JSON-path: %s", args[2], parseErr)
-		fmt.Println(msg)
-		os.Exit(1)
+	if p.completion != "" || p.version {
+		return
+	}
+
+	if p.fromStdin {
+		if p.appsFile != "" {
+			failUsage("Only one of --apps-file and --stdin can be given")
+		}
+		p.appsFile = "-"
+	}
+
+	if p.appsFile != "" {
+		names, err := readList(p.appsFile)
+		fatalIf(err)
+		positional = append(positional, names...)
 	}
 
-	p.applicator = applicator
+	// With --guid, the app name is only used in messages.
+	if len(positional) < 1 && globals.guid != "" {
+		positional = []string{globals.guid}
+	}
+
+	if len(positional) < 1 && !canPick() {
+		failUsage("App name must be provided")
+	}
+
+	if len(positional) > 0 {
+		p.appName = positional[0]
+	}
+
+	if !isEnvFormat(p.format) {
+		failUsage("Unsupported format '%s'. Supported formats: %s", p.format, strings.Join(envFormats, ", "))
+	}
+
+	if _, ok := findEnvSection(p.section); p.section != "" && p.section != sectionAll && !ok {
+		failUsage("Unsupported section '%s'. Supported sections: %s", p.section, strings.Join(sectionNames(), ", "))
+	}
+
+	if p.mergeInto != "" && p.section == sectionAll {
+		failUsage("--merge-into takes a single section")
+	}
+
+	if singleSectionFormat(p.format) && p.section == sectionAll {
+		failUsage("Format '%s' takes a single section", p.format)
+	}
+
+	if p.interval <= 0 {
+		failUsage("Interval must be positive")
+	}
+
+	if p.concurrency < 1 {
+		failUsage("Concurrency must be at least 1, got %d", p.concurrency)
+	}
+
+	if p.decode != "" {
+		p.setupDecode(positional)
+		return
+	}
+
+	if p.certs && p.urls {
+		failUsage("Only one of --inspect-certs and --summarize-urls can be given")
+	}
+
+	if p.certs {
+		p.setupReport("--inspect-certs", positional)
+		return
+	}
+
+	if p.urls {
+		p.setupReport("--summarize-urls", positional)
+		return
+	}
+
+	if len(positional) > 1 && !isKeyOrPath(positional[1]) {
+		p.appNames = positional
+		p.validateMultiApp()
+		return
+	}
+
+	if p.query != "" {
+		if len(positional) > 1 {
+			failUsage("--query can't be combined with a JSON path or KEY")
+		}
+
+		p.applicator = p.parseJsonPath(queryPath(p.query))
+		return
+	}
+
+	if len(positional) < 2 {
+		return
+	}
+
+	if len(positional) > 2 {
+		failUsage("Only one KEY or JSON path can be given")
+	}
+
+	if !envKeyName.MatchString(positional[1]) {
+		p.applicator = p.parseJsonPath(positional[1])
+		return
+	}
+
+	p.key = positional[1]
+
+	if p.section == sectionAll {
+		failUsage("A variable is looked up in a single section")
+	}
+}
+
+// setupDecode validates `--decode-base64 KEY`, which looks up KEY like
+// `cf get-env APP KEY` does.
+func (p *GetEnvPlugin) setupDecode(positional []string) {
+
+	if len(positional) > 1 || p.query != "" {
+		failUsage("--decode-base64 takes a single app and can't be combined with a JSON path, KEY or --query")
+	}
+
+	if !envKeyName.MatchString(p.decode) {
+		failUsage("Invalid variable name '%s'", p.decode)
+	}
+
+	if p.section == sectionAll || p.watch || p.live || p.mergeInto != "" {
+		failUsage("--decode-base64 can't be combined with --section all, --watch, --live or --merge-into")
+	}
+
+	p.key = p.decode
+}
+
+// setupReport validates flags like --inspect-certs, which print a report
+// on the variables of a single app instead of the env.
+func (p *GetEnvPlugin) setupReport(flag string, positional []string) {
+
+	if len(positional) > 1 || p.query != "" || p.section == sectionAll || p.watch || p.live || p.mergeInto != "" {
+		failUsage("%s takes a single app and a single section, and can't be combined with a JSON path, KEY, --query, --watch, --live or --merge-into", flag)
+	}
+}
+
+// appGuid resolves the app in the targeted space, or in the space given by
+// --org and --space.
+func (p *GetEnvPlugin) appGuid(cliConnection plugin.CliConnection) string {
+
+	return findAppGuid(cliConnection, resolveScope(cliConnection, p.org, p.space), p.appName)
+}
+
+// fetchEnv fetches the env of the app, with the CredHub references resolved
+// if --resolve-credhub is given.
+func (p *GetEnvPlugin) fetchEnv(cliConnection plugin.CliConnection, appName string, guid string) map[string]interface{} {
+
+	env := fetchEnvByGuid(cliConnection, appName, guid)
+
+	if p.credHub {
+		env = resolveCredHubRefs(cliConnection, env, p.showSecrets)
+	}
+
+	return env
+}
+
+func (p *GetEnvPlugin) shouldRedact() bool {
+	return shouldRedact(p.ui, p.redact, p.showSecrets, p.out != "" || p.mergeInto != "")
+}
+
+// queryPath turns a jq-style path like `.system_env_json.VCAP_SERVICES` into
+// the JSON path of the same value.
+func queryPath(query string) string {
+
+	if query == "." {
+		return "$"
+	}
+
+	if strings.HasPrefix(query, ".") || strings.HasPrefix(query, "[") {
+		return "$" + query
+	}
+
+	return query
 }
 
 func (p *GetEnvPlugin) parseJsonPath(pathExpression string) jsonpath.Applicator {
 	applicator, parseErr := jsonpath.Parse(pathExpression)
 
 	if parseErr != nil {
-		msg, _ := fmt.Printf("Failed to parse argument '%s' as valid JSON-path: %s", pathExpression, parseErr)
-		fmt.Println(msg)
-		os.Exit(1)
+		msg, _ := fmt.Fprintf(p.ui.Out(), "Failed to parse argument '%s' as valid JSON-path: %s", pathExpression, parseErr)
+		fmt.Fprintln(p.ui.Out(), msg)
+		exit(exitUsage)
 	}
 
 	return applicator
 }
 
-func (p *GetEnvPlugin) fetchEnv(cliConnection plugin.CliConnection) map[string]interface{} {
+func fetchEnv(cliConnection plugin.CliConnection, appName string) map[string]interface{} {
+	return fetchEnvByGuid(cliConnection, appName, resolveApp(cliConnection, appName).Guid)
+}
+
+// guidAppName is the app that --guid was taken for.
+var guidAppName string
 
-	app, err := cliConnection.GetApp(p.appName)
+// resolveApp looks up the app in the targeted space. Only the GUID is
+// cached, so that is all callers may use.
+func resolveApp(cliConnection plugin.CliConnection, appName string) plugin_models.GetAppModel {
 
-	if err != nil {
-		msg := fmt.Sprintf("Failed to retrieve enviroment for '%s'. %s", p.appName, err)
-		fmt.Println(msg)
-		os.Exit(1)
+	// Commands for several apps look them all up before changing any, so
+	// a second app fails before anything happened.
+	if globals.guid != "" {
+		if guidAppName != "" && guidAppName != appName {
+			failUsage("--guid takes a single app, but '%s' and '%s' were given", guidAppName, appName)
+		}
+		guidAppName = appName
+
+		verbosef("Using the GUID %s of app %s", globals.guid, appName)
+		return plugin_models.GetAppModel{Name: appName, Guid: globals.guid}
 	}
 
-	url := fmt.Sprintf("/v2/apps/%s/env", app.Guid)
-	output, err := cliConnection.CliCommandWithoutTerminalOutput("curl", url)
+	if guid, ok := cachedAppGuid(cliConnection, appName); ok {
+		return plugin_models.GetAppModel{Name: appName, Guid: guid}
+	}
+
+	app, err := cliConnection.GetApp(appName)
 
 	if err != nil {
-		msg := fmt.Sprintf("Failed to retrieve enviroment for '%s'. %s", p.appName, err)
-		fmt.Println(msg)
-		os.Exit(1)
+		fail(fmt.Sprintf("Failed to retrieve enviroment for '%s'. %s", appName, err), err)
+	}
+
+	cacheAppGuid(cliConnection, appName, app.Guid)
+
+	return app
+}
+
+var sharedClient *ccclient.Client
+
+// ccClient returns the Cloud Controller client of the running command. Each
+// invocation of the plugin runs a single command, so it is set up once.
+func ccClient(cliConnection plugin.CliConnection) *ccclient.Client {
+
+	if sharedClient == nil {
+		sharedClient = newClient(ccclient.NewTransport(cliConnection))
 	}
 
-	envAsString := strings.Join(output, "")
+	return sharedClient
+}
+
+// newClient applies the global --timeout, --retries and --verbose to
+// requests of transport.
+func newClient(transport ccclient.Transport) *ccclient.Client {
 
-	env := make(map[string]interface{})
-	json.Unmarshal([]byte(envAsString), &env)
+	transport = ccclient.WithTimeout(ccclient.WithContext(transport, interrupt), globals.timeout)
 
-	return env;
+	// The limiter is shared by all workers of a command, so that wide scans
+	// don't get the account of the user throttled.
+	limiter := &ccclient.RateLimiter{PerSecond: globals.rateLimit, OnPause: warnRateLimited}
+	if direct, ok := transport.(*ccclient.HTTPTransport); ok {
+		direct.Limiter = limiter
+	}
+	transport = &ccclient.RateLimitTransport{Transport: transport, Limiter: limiter, Context: interrupt}
+
+	if globals.verbose || globals.trace {
+		transport = &ccclient.LogTransport{Transport: transport, Log: verbosef, Bodies: globals.trace}
+	}
+
+	return ccclient.NewWithTransport(&ccclient.RetryTransport{
+		Transport: transport,
+		Retries:   globals.retries,
+		Delay:     globals.retryDelay,
+		Context:   interrupt,
+	})
 }
 
-func fatalIf(err error) {
+// warnRateLimited tells why the command pauses, unless --quiet. Like the
+// logs of --verbose, it goes to the stderr of the process.
+func warnRateLimited(wait time.Duration) {
+	if !globals.quiet {
+		fmt.Fprintf(os.Stderr, "Rate limit of the Cloud Controller reached, waiting %s\n", wait)
+	}
+}
+
+func fetchEnvByGuid(cliConnection plugin.CliConnection, appName string, guid string) map[string]interface{} {
+
+	env, err := ccClient(cliConnection).GetAppEnv(guid)
+
+	// The app may have been deleted and pushed again since its GUID was
+	// cached.
+	if err != nil && errorCode(err) == errorNotFound && forgetCachedGuid(guid) {
+		return fetchEnvByGuid(cliConnection, appName, resolveApp(cliConnection, appName).Guid)
+	}
+
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fail(fmt.Sprintf("Failed to retrieve enviroment for '%s'. %s", appName, err), err)
 	}
+
+	return env
 }
 
-func (c *GetEnvPlugin) GetMetadata() plugin.PluginMetadata {
-	return plugin.PluginMetadata{
-		Name: "Get-Env",
-		Commands: []plugin.Command{
-			{
-				Name:     "get-env",
-				HelpText: "Get value from the environment from an env by a JSON path expression.",
-				UsageDetails: plugin.Usage{
-					Usage: "cf get-env APP_NAME JSON_PATH",
-				},
-			},
-		},
+func (p *GetEnvPlugin) GetMetadata() plugin.PluginMetadata {
+	metadata := plugin.PluginMetadata{
+		Name:          "Get-Env",
+		Version:       pluginVersion(version),
+		MinCliVersion: minCliVersion,
 	}
+
+	for _, command := range p.registry() {
+		metadata.Commands = append(metadata.Commands, command.Usage())
+	}
+	metadata.Commands = append(metadata.Commands, completeUsage)
+
+	for i := range metadata.Commands {
+		documentGlobalOptions(&metadata.Commands[i].UsageDetails)
+		documentExamples(&metadata.Commands[i])
+	}
+
+	return metadata
 }
@@ -1,16 +1,27 @@
 package main_test
 
 import (
-	"code.cloudfoundry.org/cli/testhelpers/pluginbuilder"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
 	"testing"
 )
 
+// pluginPath is the plugin binary, for the tests that need a process of
+// its own. The others run the plugin in the test process, see runPlugin.
+var pluginPath string
+
 func TestTestRpcServerExample(t *testing.T) {
 	RegisterFailHandler(Fail)
-
-	pluginbuilder.BuildTestBinary("", "get_env")
-
 	RunSpecs(t, "GetEnv Suite")
 }
+
+var _ = BeforeSuite(func() {
+	var err error
+	pluginPath, err = gexec.Build("github.com/thomaseizinger/cf-get-env-plugin")
+	Expect(err).NotTo(HaveOccurred())
+})
+
+var _ = AfterSuite(func() {
+	gexec.CleanupBuildArtifacts()
+})
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"flag"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"os"
+	"strings"
+	"time"
+)
+
+// globalOptions apply to every command. They are taken out of the arguments
+// before the command parses its own flags.
+type globalOptions struct {
+	retries     int
+	retryDelay  time.Duration
+	timeout     time.Duration
+	maxDuration time.Duration
+	errorFormat string
+	noColor     bool
+	quiet       bool
+	verbose     bool
+	trace       bool
+	dryRun      bool
+	noCache     bool
+	cacheTTL    time.Duration
+	rateLimit   float64
+	full        bool
+	guid        string
+	orgGuid     string
+	spaceGuid   string
+
+	// schema is parsed with the flags of the command, see schemaFlag.
+	schema bool
+}
+
+var globals = globalOptions{
+	retries:     3,
+	retryDelay:  500 * time.Millisecond,
+	timeout:     ccclient.DefaultTimeout,
+	errorFormat: "text",
+	cacheTTL:    5 * time.Minute,
+}
+
+func globalFlagSet() *flag.FlagSet {
+
+	flags := newFlagSet("global")
+	flags.IntVar(&globals.retries, "retries", globals.retries, "")
+	flags.DurationVar(&globals.retryDelay, "retry-delay", globals.retryDelay, "")
+	flags.DurationVar(&globals.timeout, "timeout", globals.timeout, "")
+	flags.DurationVar(&globals.maxDuration, "max-duration", globals.maxDuration, "")
+	flags.StringVar(&globals.errorFormat, "error-format", "text", "")
+	flags.BoolVar(&globals.noColor, "no-color", false, "")
+	flags.BoolVar(&globals.quiet, "q", false, "")
+	flags.BoolVar(&globals.quiet, "quiet", false, "")
+	flags.BoolVar(&globals.verbose, "v", false, "")
+	flags.BoolVar(&globals.verbose, "verbose", false, "")
+	flags.BoolVar(&globals.trace, "trace", false, "")
+	flags.BoolVar(&globals.dryRun, "dry-run", false, "")
+	flags.BoolVar(&globals.noCache, "no-cache", false, "")
+	flags.DurationVar(&globals.cacheTTL, "cache-ttl", globals.cacheTTL, "")
+	flags.Float64Var(&globals.rateLimit, "rate-limit", 0, "")
+	flags.BoolVar(&globals.full, "full", false, "")
+	flags.StringVar(&globals.guid, "guid", "", "")
+	flags.StringVar(&globals.guid, "app-guid", "", "")
+	flags.StringVar(&globals.orgGuid, "org-guid", "", "")
+	flags.StringVar(&globals.spaceGuid, "space-guid", "", "")
+
+	return flags
+}
+
+// extractGlobalFlags sets the global options found in args, in `--name value`
+// or `--name=value` form, and returns the remaining arguments. Arguments
+// after "--" belong to a command run by the plugin and are left alone.
+func extractGlobalFlags(args []string) []string {
+
+	flags := globalFlagSet()
+	var remaining []string
+
+	if err := applyConfig(flags); err != nil {
+		failUsage("%s", err)
+	}
+
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			remaining = append(remaining, args[i:]...)
+			break
+		}
+
+		name := strings.TrimLeft(args[i], "-")
+		value := ""
+		hasValue := false
+
+		if separator := strings.Index(name, "="); separator >= 0 {
+			name, value, hasValue = name[:separator], name[separator+1:], true
+		}
+
+		global := flags.Lookup(name)
+
+		if !strings.HasPrefix(args[i], "-") || global == nil {
+			remaining = append(remaining, args[i])
+			continue
+		}
+
+		if isBoolFlag(global) && !hasValue {
+			value, hasValue = "true", true
+		}
+
+		if !hasValue {
+			if i+1 >= len(args) {
+				failUsage("Flag --%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		if err := flags.Set(name, value); err != nil {
+			failUsage("Invalid value '%s' for flag --%s: %s", value, name, err)
+		}
+	}
+
+	if globals.timeout <= 0 {
+		failUsage("Timeout must be positive")
+	}
+
+	if globals.rateLimit < 0 {
+		failUsage("Rate limit must not be negative")
+	}
+
+	if globals.errorFormat != "text" && globals.errorFormat != "json" {
+		failUsage("Unsupported error format '%s'. Supported formats: text, json", globals.errorFormat)
+	}
+
+	if globals.quiet && (globals.verbose || globals.trace) {
+		failUsage("--quiet can't be combined with --verbose or --trace")
+	}
+
+	return remaining
+}
+
+// verbosef logs a line to stderr with --verbose or --trace, so that it
+// doesn't mix with the output of the command.
+func verbosef(format string, args ...interface{}) {
+	if globals.verbose || globals.trace {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
+
+// maxDurationGrace is how long a command has to stop after --max-duration
+// has interrupted it, before the process ends at once.
+const maxDurationGrace = 10 * time.Second
+
+// enforceMaxDuration interrupts the command once --max-duration has passed,
+// so that scripted invocations don't hang on a slow Cloud Controller. The
+// command then fails with the interrupted requests, or prints its partial
+// results, either way with the error of the deadline. The returned func
+// stops the clock once the command is done.
+func enforceMaxDuration(ui UI) (stop func()) {
+
+	if globals.maxDuration <= 0 {
+		return func() {}
+	}
+
+	err := fmt.Errorf("Command did not finish within %s", globals.maxDuration)
+
+	timer := time.AfterFunc(globals.maxDuration, func() {
+		interruptWith(err)
+	})
+
+	// A command that doesn't stop when interrupted can't be unwound from
+	// here, so the process ends at once, like with a second Ctrl-C.
+	deadline := time.AfterFunc(globals.maxDuration+maxDurationGrace, func() {
+		writeFailure(ui.ErrOut(), err)
+		os.Exit(exitCode(err))
+	})
+
+	return func() {
+		timer.Stop()
+		deadline.Stop()
+	}
+}
+
+var globalOptionsHelp = map[string]string{
+	"retries":      "Number of retries of failed API requests (default 3)",
+	"retry-delay":  "Wait before the first retry, doubled for every further one (default 500ms)",
+	"timeout":      "Time limit of each API request (default 60s)",
+	"max-duration": "Time limit of the whole command, unlimited by default",
+	"error-format": "Print failures as 'text' (default) or as 'json' objects",
+	"no-color":     "Print without color. Color is also off with NO_COLOR or CF_COLOR=false, and when not printing to a terminal",
+	"quiet":        "Print only the output of the command, without informational lines and progress. -q is an alias",
+	"verbose":      "Log every API request with its outcome and duration to stderr. -v is an alias",
+	"trace":        "Like --verbose, and also log the request and response bodies",
+	"dry-run":      "Print the changes and the API requests of commands that change apps or secrets without making them",
+	"no-cache":     "Look up app GUIDs and the apps of spaces without the cache in ~/.cf/plugins/get-env-cache.json",
+	"cache-ttl":    "How long looked up app GUIDs and apps of spaces are cached (default 5m). 0 turns caching off",
+	"rate-limit":   "Maximum number of API requests per second, unlimited by default. Throttling by the Cloud Controller is waited out either way",
+	"full":         "Print long values in tables whole instead of truncating them to the width of the terminal",
+	"guid":         "GUID of the app of commands for a single app, e.g. from the JSON output of list-apps, to skip looking the app up by name. --app-guid is an alias",
+	"org-guid":     "GUID of the org to use instead of the targeted one or --org, without looking up names",
+	"space-guid":   "GUID of the space to use instead of the targeted one or --space, without looking up names",
+	"schema":       "Print the JSON Schema of the JSON output of the command, for the given flags, instead of running it",
+}
+
+func documentGlobalOptions(usage *plugin.Usage) {
+
+	if usage.Options == nil {
+		usage.Options = map[string]string{}
+	}
+
+	for name, help := range globalOptionsHelp {
+		if _, own := usage.Options[name]; !own {
+			usage.Options[name] = help
+		}
+	}
+}
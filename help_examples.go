@@ -0,0 +1,135 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"strings"
+)
+
+// examplesHeader separates the examples from the usage, which completion
+// reads the flags of.
+const examplesHeader = "\n\nEXAMPLES:\n"
+
+// commandExamples are shown below the usage by `cf help COMMAND`.
+var commandExamples = map[string][]string{
+	"get-env": {
+		"cf get-env my-app                           # user-provided variables",
+		"cf get-env my-app DB_HOST -n                # raw value of a variable",
+		"cf get-env my-app '$.VCAP_SERVICES..uri'    # JSON path into the env",
+		"cf get-env my-app --format dotenv --out .env",
+		"eval \"$(cf get-env my-app --format shell)\"",
+		"cf get-env my-app --section all --format yaml",
+		"cf get-env app1 app2 app3 --format json",
+		"cf apps | awk 'NR>4 {print $1}' | cf get-env --stdin",
+		"cf get-env my-app --watch --interval 1m",
+	},
+	"copy-env": {
+		"cf copy-env my-app my-app-staging --exclude DB_PASSWORD --dry-run",
+		"cf copy-env my-app my-app-staging --overwrite -f --restage",
+	},
+	"get-service-env": {
+		"cf get-service-env my-app my-db --field '$.credentials.uri' --show-secrets",
+	},
+	"find-env": {
+		"cf find-env DB_HOST",
+		"cf find-env 'FEATURE_.*' --value-regex '^on$' --all-orgs --format csv",
+		"cf find-env DB_HOST --targets targets.txt --stream ndjson",
+	},
+	"env-matrix": {
+		"cf env-matrix --differences",
+		"cf env-matrix --format csv > matrix.csv",
+	},
+	"env-audit": {
+		"cf env-audit --fail-on high",
+		"cf env-audit --format prometheus > audit.prom",
+	},
+	"set-env-file": {
+		"cf set-env-file my-app .env --dry-run",
+		"cf set-env-file my-app .env --prune -f --restart",
+		"cf set-env-file my-app .env --encode-base64 TLS_CERT=cert.pem",
+	},
+	"env-export": {
+		"cf env-export my-app --to vault --vault-path secret/my-app",
+		"cf env-export my-app --to aws-secretsmanager --per-key --aws-region eu-west-1",
+	},
+	"env-hash": {
+		"cf env-hash my-app",
+		"cf env-hash my-app --expect \"$(cf env-hash my-app-staging)\"",
+	},
+	"env-history": {
+		"cf env-history my-app --format json",
+	},
+	"env-import": {
+		"cf env-import my-app --from vault --path secret/my-app --dry-run",
+		"cf env-import my-app --from gcp-secretmanager --path my-app --gcp-project my-project --restage",
+	},
+	"env-apply": {
+		"cf env-apply plan.yml --dry-run",
+		"cf env-apply plan.yml -f --restart",
+	},
+	"env-validate": {
+		"cf env-validate my-app --rules rules.yml",
+		"cf env-validate my-app --json-schema VCAP_SERVICES=schema.json --format json",
+	},
+	"cert-expiry-scan": {
+		"cf cert-expiry-scan --days 30",
+	},
+	"env-drift": {
+		"cf env-drift my-app -f manifest.yml --fail-on any",
+	},
+	"render-env": {
+		"cf render-env my-app application.properties.tmpl --out application.properties",
+	},
+	"run-with-env": {
+		"cf run-with-env my-app -- npm start",
+		"cf run-with-env my-app --with-services -- ./gradlew bootRun",
+	},
+	"get-env-group": {
+		"cf get-env-group running --format yaml",
+	},
+	"set-env-group": {
+		"cf set-env-group running HTTP_PROXY http://proxy:3128",
+		"cf set-env-group staging --file staging.env --prune",
+	},
+	"unset-env-matching": {
+		"cf unset-env-matching my-app '^FEATURE_FLAG_' --dry-run",
+		"cf unset-env-matching my-app --keys-from obsolete.txt -f --restage",
+	},
+	"env-snapshot": {
+		"cf env-snapshot my-app --save my-app.env.json",
+		"cf env-snapshot my-app --save my-app.env.age --encrypt age1...",
+	},
+	"env-restore": {
+		"cf env-restore my-app my-app.env.json --diff",
+		"cf env-restore my-app my-app.env.age --identity key.txt -f",
+	},
+	"diff-env": {
+		"cf diff-env my-app my-app-staging",
+		"cf diff-env my-app --target-a staging --target-b production --show-values",
+	},
+	"get-env-plugin-update": {
+		"cf get-env-plugin-update",
+		"cf get-env-plugin-update --install",
+	},
+	"list-apps": {
+		"cf list-apps --crashed",
+		"cf list-apps --all --format csv --columns name,state,memory",
+		"cf list-apps --format template --template '{{.Name}}: {{.State}}'",
+		"cf list-apps --format json | jq -r '.apps[].name'",
+		"cf list-apps --schema > apps.schema.json",
+	},
+}
+
+func documentExamples(command *plugin.Command) {
+
+	examples, ok := commandExamples[command.Name]
+	if !ok {
+		return
+	}
+
+	command.UsageDetails.Usage += examplesHeader + "   " + strings.Join(examples, "\n   ")
+}
+
+// usageLine is the usage without the examples.
+func usageLine(usage plugin.Usage) string {
+	return strings.SplitN(usage.Usage, examplesHeader, 2)[0]
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"io"
+	"strings"
+	"time"
+)
+
+// The history of env changes is kept as a JSON array in an annotation of
+// the app, so it lives and dies with the app and needs no extra storage.
+// Annotation values are limited to 5000 characters, the oldest entries are
+// dropped to stay below it.
+const (
+	historyAnnotation = "cf-get-env-plugin/env-history"
+	historyMaxLength  = 5000
+)
+
+// envHistoryEntry records who changed which variables of an app, and when.
+// It never contains values.
+type envHistoryEntry struct {
+	Time    time.Time `json:"at"`
+	User    string    `json:"by"`
+	Command string    `json:"cmd"`
+	Added   []string  `json:"added,omitempty"`
+	Changed []string  `json:"changed,omitempty"`
+	Removed []string  `json:"removed,omitempty"`
+}
+
+// historySchema is the schema of the JSON output of env-history.
+var historySchema = formatters.Schema{Name: "history", Version: 1, Results: []envHistoryEntry{}}
+
+// historyOptions are the flags of commands that change env variables.
+// Tracking is opt-in, usually with `track-history: true` in the config file.
+type historyOptions struct {
+	track bool
+}
+
+func addHistoryFlags(flags *flag.FlagSet) *historyOptions {
+
+	options := &historyOptions{}
+	flags.BoolVar(&options.track, "track-history", false, "")
+
+	return options
+}
+
+// record appends the change from before to after to the history of the app.
+// The env has already been changed at this point, so failing to record it
+// is only a warning.
+func (options *historyOptions) record(ui UI, cliConnection plugin.CliConnection, appName string, guid string, command string, before, after map[string]interface{}) {
+
+	if !options.track {
+		return
+	}
+
+	diff := diffEnv(before, after)
+
+	if diff.empty() {
+		return
+	}
+
+	user, _ := cliConnection.Username()
+
+	entry := envHistoryEntry{
+		Time:    time.Now().UTC().Truncate(time.Second),
+		User:    user,
+		Command: command,
+		Added:   diff.OnlyInB,
+		Changed: diff.Changed,
+		Removed: diff.OnlyInA,
+	}
+
+	if err := appendHistory(cliConnection, guid, entry); err != nil {
+		fmt.Fprintf(ui.Out(), "Warning: failed to record the change in the history of '%s': %s\n", appName, err)
+	}
+}
+
+func appendHistory(cliConnection plugin.CliConnection, guid string, entry envHistoryEntry) error {
+
+	client := ccClient(cliConnection)
+
+	annotations, err := client.GetAppAnnotations(guid)
+
+	if err != nil {
+		return err
+	}
+
+	history := append(readHistory(annotations), entry)
+
+	for {
+		encoded, err := json.Marshal(history)
+
+		if err != nil {
+			return err
+		}
+
+		if len(encoded) <= historyMaxLength || len(history) == 1 {
+			return client.SetAppAnnotation(guid, historyAnnotation, string(encoded))
+		}
+
+		history = history[1:]
+	}
+}
+
+// readHistory decodes the history annotation. An annotation that isn't
+// valid JSON is treated as an empty history rather than failing every
+// change of the app.
+func readHistory(annotations map[string]string) []envHistoryEntry {
+
+	var history []envHistoryEntry
+
+	if value, ok := annotations[historyAnnotation]; ok {
+		json.Unmarshal([]byte(value), &history)
+	}
+
+	return history
+}
+
+type envHistoryCommand struct {
+	format string
+	tmpl   listTemplate
+}
+
+func (c *envHistoryCommand) Name() string {
+	return "env-history"
+}
+
+func (c *envHistoryCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "env-history",
+		HelpText: "Show the env changes of an app recorded with --track-history, newest first.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf env-history APP_NAME [--format table|json|ndjson|template [--template TEMPLATE | --template-file FILE]]",
+			Options: map[string]string{
+				"format":        "Output format (default table)",
+				"template":      "Go text/template printed for every change with --format template, e.g. '{{.Time}} {{.User}} {{.Command}}'",
+				"template-file": "Read the template of --format template from FILE",
+			},
+		},
+	}
+}
+
+func (c *envHistoryCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("env-history")
+	flags.StringVar(&c.format, "format", formatTable, "")
+	c.tmpl.register(flags)
+
+	return flags
+}
+
+func (c *envHistoryCommand) schema(args []string) formatters.Schema {
+	return historySchema
+}
+
+func (c *envHistoryCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 1 {
+		failUsage("App name must be provided")
+	}
+
+	if c.format != formatTable && c.format != formatJson && c.format != formatNdjson && c.format != formatTemplate {
+		failUsage("Unsupported format '%s'. Supported formats: table, json, ndjson, template", c.format)
+	}
+
+	fatalIf(c.tmpl.setup(c.format))
+
+	appName := args[0]
+	app := resolveApp(cliConnection, appName)
+
+	annotations, err := ccClient(cliConnection).GetAppAnnotations(app.Guid)
+	fatalIf(err)
+
+	printResult(ui.Out(), newFormatter(c.format, c.tmpl), envHistory{app: appName, entries: readHistory(annotations)})
+}
+
+// envHistory is the result of env-history, the recorded changes of app.
+// Lines are printed from the oldest change, like the annotation holds them,
+// so that they can be appended to a log.
+type envHistory struct {
+	app     string
+	entries []envHistoryEntry
+}
+
+func (h envHistory) Items() interface{} {
+	return h.entries
+}
+
+func (h envHistory) Schema() formatters.Schema {
+	return historySchema
+}
+
+// Print prints the changes as a table, the latest first.
+func (h envHistory) Print(out io.Writer) error {
+
+	if len(h.entries) == 0 {
+		_, err := fmt.Fprintf(out, "No env changes of '%s' recorded. Changes are recorded with --track-history\n", h.app)
+		return err
+	}
+
+	var rows [][]string
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		entry := h.entries[i]
+		rows = append(rows, []string{entry.Time.Format(time.RFC3339), entry.User, entry.Command,
+			strings.Join(entry.Added, ", "), strings.Join(entry.Changed, ", "), strings.Join(entry.Removed, ", ")})
+	}
+
+	return formatters.Human{}.Format(out, formatters.Table{Columns: []string{"time", "user", "command", "added", "changed", "removed"}, Cells: rows})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"io"
+	"os"
+)
+
+// RunWithConnection runs the plugin with args, like the CLI does through
+// Run, but inside the calling process: output goes to out, and the exit
+// code is returned instead of ending the process. Warnings still go to
+// stderr and input is read from stdin. It is meant for tests, which can
+// then hand in a fake connection and measure coverage.
+//
+// Every call starts from the defaults of the global options and with empty
+// caches. Calls must not overlap.
+func RunWithConnection(cliConnection plugin.CliConnection, args []string, out io.Writer) (code int) {
+
+	resetState()
+
+	exit = func(status int) {
+		panic(exitPanic{code: status})
+	}
+
+	defer func() {
+		exit = os.Exit
+
+		if recovered := recover(); recovered != nil {
+			exited, ok := recovered.(exitPanic)
+			if !ok {
+				panic(recovered)
+			}
+			code = exited.code
+		}
+	}()
+
+	new(GetEnvPlugin).run(&terminalUI{out: out, errOut: os.Stderr}, cliConnection, args)
+
+	return 0
+}
+
+// exitPanic is what exit panics with in process, to end the command with
+// its exit code. Workers of inParallel hand it on to the goroutine of the
+// command, where RunWithConnection recovers it.
+type exitPanic struct {
+	code int
+}
+
+var defaultGlobals = globals
+
+// resetState forgets what an earlier command of the process left behind.
+func resetState() {
+	globals = defaultGlobals
+	interrupt, cancelInterrupt = context.WithCancel(context.Background())
+	interruptCause.err = nil
+	stdin = bufio.NewReader(os.Stdin)
+	cache = &diskCache{served: map[string]string{}}
+	sharedClient = nil
+	loadedConfig = nil
+	colorEnabled = false
+	guidAppName = ""
+}
@@ -0,0 +1,283 @@
+package main_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	main "github.com/thomaseizinger/cf-get-env-plugin"
+
+	"code.cloudfoundry.org/cli/plugin/models"
+	"code.cloudfoundry.org/cli/testhelpers/rpcserver/rpcserverfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+// pluginRun is the outcome of running the plugin in the test process. It
+// is matched like a session of the plugin binary: gbytes.Say looks at the
+// output and gexec.Exit at the exit code.
+type pluginRun struct {
+	Out      *gbytes.Buffer
+	Err      *gbytes.Buffer
+	exitCode int
+}
+
+func (r *pluginRun) Buffer() *gbytes.Buffer {
+	return r.Out
+}
+
+func (r *pluginRun) ExitCode() int {
+	return r.exitCode
+}
+
+// pluginInput is what a run of the plugin reads besides its arguments.
+type pluginInput struct {
+	stdin string
+
+	// env are set, as "NAME=value", for the duration of the run.
+	env []string
+}
+
+// runPlugin runs the plugin with args, the command and its arguments, in
+// the test process. The CLI is played by handlers, like for the binary.
+func runPlugin(handlers *rpcserverfakes.FakeHandlers, args ...string) *pluginRun {
+	return runPluginWith(handlers, pluginInput{}, args...)
+}
+
+func runPluginWith(handlers *rpcserverfakes.FakeHandlers, input pluginInput, args ...string) *pluginRun {
+
+	run := &pluginRun{Out: gbytes.NewBuffer(), Err: gbytes.NewBuffer()}
+
+	for _, variable := range input.env {
+		name := strings.SplitN(variable, "=", 2)[0]
+		previous, set := os.LookupEnv(name)
+		Expect(os.Setenv(name, strings.TrimPrefix(variable, name+"="))).To(Succeed())
+
+		if set {
+			defer os.Setenv(name, previous)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+
+	stdinReader, stdinWriter, err := os.Pipe()
+	Expect(err).NotTo(HaveOccurred())
+	go func() {
+		io.WriteString(stdinWriter, input.stdin)
+		stdinWriter.Close()
+	}()
+
+	stderrReader, stderrWriter, err := os.Pipe()
+	Expect(err).NotTo(HaveOccurred())
+	stderrCopied := make(chan struct{})
+	go func() {
+		io.Copy(io.MultiWriter(run.Err, GinkgoWriter), stderrReader)
+		close(stderrCopied)
+	}()
+
+	stdin, stderr := os.Stdin, os.Stderr
+	os.Stdin, os.Stderr = stdinReader, stderrWriter
+
+	run.exitCode = main.RunWithConnection(&handlerConnection{handlers}, args, io.MultiWriter(run.Out, GinkgoWriter))
+
+	os.Stdin, os.Stderr = stdin, stderr
+	stderrWriter.Close()
+	<-stderrCopied
+	stdinReader.Close()
+
+	return run
+}
+
+// handlerConnection makes the calls of the plugin to the CLI like the
+// connection of the plugin binary does over RPC, so that the RPC fakes
+// serve both.
+type handlerConnection struct {
+	handlers *rpcserverfakes.FakeHandlers
+}
+
+func (c *handlerConnection) CliCommandWithoutTerminalOutput(args ...string) ([]string, error) {
+	return c.callCliCommand(true, args...)
+}
+
+func (c *handlerConnection) CliCommand(args ...string) ([]string, error) {
+	return c.callCliCommand(false, args...)
+}
+
+func (c *handlerConnection) callCliCommand(silently bool, args ...string) ([]string, error) {
+
+	var success bool
+	c.handlers.DisableTerminalOutput(silently, &success)
+
+	success = false
+	err := c.handlers.CallCoreCommand(args, &success)
+
+	var output []string
+	outputErr := c.handlers.GetOutputAndReset(success, &output)
+
+	if err != nil {
+		return output, err
+	}
+
+	if !success {
+		return output, errors.New("Error executing cli core command")
+	}
+
+	if outputErr != nil {
+		return output, errors.New("something completely unexpected happened")
+	}
+
+	return output, nil
+}
+
+func (c *handlerConnection) GetCurrentOrg() (plugin_models.Organization, error) {
+	var result plugin_models.Organization
+	err := c.handlers.GetCurrentOrg("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) GetCurrentSpace() (plugin_models.Space, error) {
+	var result plugin_models.Space
+	err := c.handlers.GetCurrentSpace("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) Username() (string, error) {
+	var result string
+	err := c.handlers.Username("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) UserGuid() (string, error) {
+	var result string
+	err := c.handlers.UserGuid("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) UserEmail() (string, error) {
+	var result string
+	err := c.handlers.UserEmail("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) IsLoggedIn() (bool, error) {
+	var result bool
+	err := c.handlers.IsLoggedIn("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) IsSSLDisabled() (bool, error) {
+	var result bool
+	err := c.handlers.IsSSLDisabled("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) HasOrganization() (bool, error) {
+	var result bool
+	err := c.handlers.HasOrganization("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) HasSpace() (bool, error) {
+	var result bool
+	err := c.handlers.HasSpace("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) ApiEndpoint() (string, error) {
+	var result string
+	err := c.handlers.ApiEndpoint("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) ApiVersion() (string, error) {
+	var result string
+	err := c.handlers.ApiVersion("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) HasAPIEndpoint() (bool, error) {
+	var result bool
+	err := c.handlers.HasAPIEndpoint("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) LoggregatorEndpoint() (string, error) {
+	var result string
+	err := c.handlers.LoggregatorEndpoint("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) DopplerEndpoint() (string, error) {
+	var result string
+	err := c.handlers.DopplerEndpoint("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) AccessToken() (string, error) {
+	var result string
+	err := c.handlers.AccessToken("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) GetApp(appName string) (plugin_models.GetAppModel, error) {
+	var result plugin_models.GetAppModel
+	err := c.handlers.GetApp(appName, &result)
+	return result, err
+}
+
+func (c *handlerConnection) GetApps() ([]plugin_models.GetAppsModel, error) {
+	var result []plugin_models.GetAppsModel
+	err := c.handlers.GetApps("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) GetOrgs() ([]plugin_models.GetOrgs_Model, error) {
+	var result []plugin_models.GetOrgs_Model
+	err := c.handlers.GetOrgs("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) GetSpaces() ([]plugin_models.GetSpaces_Model, error) {
+	var result []plugin_models.GetSpaces_Model
+	err := c.handlers.GetSpaces("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) GetOrgUsers(orgName string, args ...string) ([]plugin_models.GetOrgUsers_Model, error) {
+	var result []plugin_models.GetOrgUsers_Model
+	err := c.handlers.GetOrgUsers(append([]string{orgName}, args...), &result)
+	return result, err
+}
+
+func (c *handlerConnection) GetSpaceUsers(orgName string, spaceName string) ([]plugin_models.GetSpaceUsers_Model, error) {
+	var result []plugin_models.GetSpaceUsers_Model
+	err := c.handlers.GetSpaceUsers([]string{orgName, spaceName}, &result)
+	return result, err
+}
+
+func (c *handlerConnection) GetServices() ([]plugin_models.GetServices_Model, error) {
+	var result []plugin_models.GetServices_Model
+	err := c.handlers.GetServices("", &result)
+	return result, err
+}
+
+func (c *handlerConnection) GetService(serviceInstance string) (plugin_models.GetService_Model, error) {
+	var result plugin_models.GetService_Model
+	err := c.handlers.GetService(serviceInstance, &result)
+	return result, err
+}
+
+func (c *handlerConnection) GetOrg(orgName string) (plugin_models.GetOrg_Model, error) {
+	var result plugin_models.GetOrg_Model
+	err := c.handlers.GetOrg(orgName, &result)
+	return result, err
+}
+
+func (c *handlerConnection) GetSpace(spaceName string) (plugin_models.GetSpace_Model, error) {
+	var result plugin_models.GetSpace_Model
+	err := c.handlers.GetSpace(spaceName, &result)
+	return result, err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// interrupt is cancelled by the first Ctrl-C during a listing or scan, which
+// aborts the requests in flight. The command then prints what it has got
+// so far, marked as partial, and exits with exitInterrupted. A second Ctrl-C
+// exits at once.
+var interrupt, cancelInterrupt = context.WithCancel(context.Background())
+
+// interruptCause is the error that interrupted the command if it wasn't
+// Ctrl-C, see interruptWith.
+var interruptCause struct {
+	sync.Mutex
+	err error
+}
+
+// interruptWith interrupts the command like Ctrl-C does, except that it
+// fails with err rather than exiting with exitInterrupted.
+func interruptWith(err error) {
+
+	interruptCause.Lock()
+	if interruptCause.err == nil && !interrupted() {
+		interruptCause.err = err
+	}
+	interruptCause.Unlock()
+
+	cancelInterrupt()
+}
+
+// interruptedWith returns the error passed to interruptWith, if that is
+// what interrupted the command.
+func interruptedWith() error {
+
+	interruptCause.Lock()
+	defer interruptCause.Unlock()
+
+	return interruptCause.err
+}
+
+// handleInterrupts is called by the commands that can print partial
+// results. Ctrl-C ends the others right away, as usual. stop hands the
+// signals back once the command is done, so that a command run after it in
+// the same process isn't interrupted through it.
+func handleInterrupts(ui UI) (stop func()) {
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		if _, ok := <-signals; !ok {
+			return
+		}
+		fmt.Fprintln(ui.ErrOut(), "Interrupted, printing the results so far. Press Ctrl-C again to quit at once")
+		cancelInterrupt()
+
+		if _, ok := <-signals; ok {
+			os.Exit(exitInterrupted)
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(signals)
+	}
+}
+
+func interrupted() bool {
+	return interrupt.Err() != nil
+}
+
+// exitIfInterrupted ends a command that printed partial results.
+func exitIfInterrupted(ui UI) {
+
+	// A command ending with a failure reports that instead.
+	if recovered := recover(); recovered != nil {
+		panic(recovered)
+	}
+
+	if !interrupted() {
+		return
+	}
+
+	fatalIf(interruptedWith())
+
+	if !globals.quiet {
+		fmt.Fprintln(ui.ErrOut(), "Interrupted, the results are incomplete")
+	}
+
+	exit(exitInterrupted)
+}
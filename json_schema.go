@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonSchema validates JSON values against a JSON Schema. It implements the
+// keywords used to describe configuration: types, enum and const, object
+// properties, arrays, string and number limits, the combinators and local
+// $refs. Unknown keywords are ignored, as the specification asks.
+type jsonSchema struct {
+	root interface{}
+}
+
+func readJsonSchema(path string) (jsonSchema, error) {
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return jsonSchema{}, err
+	}
+
+	var root interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return jsonSchema{}, fmt.Errorf("Failed to parse schema '%s': %s", path, err)
+	}
+
+	return jsonSchema{root: root}, nil
+}
+
+// validate returns the errors of value, each prefixed with the JSON path of
+// the offending part, e.g. `$.flags[0]: expected boolean, got string`.
+// Errors never contain the value itself.
+func (s jsonSchema) validate(value interface{}) []string {
+
+	var errors []string
+	s.check(s.root, value, "$", &errors)
+
+	return errors
+}
+
+func (s jsonSchema) check(schema interface{}, value interface{}, path string, errors *[]string) {
+
+	fail := func(format string, args ...interface{}) {
+		*errors = append(*errors, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch typed := schema.(type) {
+	case bool:
+		if !typed {
+			fail("no value is allowed")
+		}
+		return
+	case map[string]interface{}:
+	default:
+		return
+	}
+
+	keywords := schema.(map[string]interface{})
+
+	if ref, ok := keywords["$ref"].(string); ok {
+		resolved, err := s.resolve(ref)
+		if err != nil {
+			fail("%s", err)
+			return
+		}
+		s.check(resolved, value, path, errors)
+	}
+
+	if types, ok := keywords["type"]; ok && !matchesType(types, value) {
+		fail("expected %s, got %s", formatTypes(types), jsonType(value))
+		return
+	}
+
+	if allowed, ok := keywords["enum"].([]interface{}); ok && !containsValue(allowed, value) {
+		fail("must be one of %s", formatEnvValue(allowed))
+	}
+
+	if constant, ok := keywords["const"]; ok && !reflect.DeepEqual(constant, value) {
+		fail("must be %s", formatEnvValue(constant))
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		s.checkObject(keywords, typed, path, errors)
+	case []interface{}:
+		s.checkArray(keywords, typed, path, errors)
+	case string:
+		checkString(keywords, typed, fail)
+	case float64:
+		checkNumber(keywords, typed, fail)
+	}
+
+	if all, ok := keywords["allOf"].([]interface{}); ok {
+		for _, nested := range all {
+			s.check(nested, value, path, errors)
+		}
+	}
+
+	if anyOf, ok := keywords["anyOf"].([]interface{}); ok && s.countMatches(anyOf, value) == 0 {
+		fail("must match at least one schema of anyOf")
+	}
+
+	if oneOf, ok := keywords["oneOf"].([]interface{}); ok {
+		if matches := s.countMatches(oneOf, value); matches != 1 {
+			fail("must match exactly one schema of oneOf, matches %d", matches)
+		}
+	}
+
+	if not, ok := keywords["not"]; ok && s.countMatches([]interface{}{not}, value) == 1 {
+		fail("must not match the schema of not")
+	}
+}
+
+func (s jsonSchema) checkObject(keywords map[string]interface{}, object map[string]interface{}, path string, errors *[]string) {
+
+	properties, _ := keywords["properties"].(map[string]interface{})
+
+	if required, ok := keywords["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, present := object[key]; !present {
+					*errors = append(*errors, path+": missing required property "+strconv.Quote(key))
+				}
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(object) {
+		if property, ok := properties[key]; ok {
+			s.check(property, object[key], propertyPath(path, key), errors)
+		} else if additional, ok := keywords["additionalProperties"]; ok {
+			if allowed, isBool := additional.(bool); isBool && !allowed {
+				*errors = append(*errors, path+": unexpected property "+strconv.Quote(key))
+			} else {
+				s.check(additional, object[key], propertyPath(path, key), errors)
+			}
+		}
+	}
+
+	if min, ok := keywords["minProperties"].(float64); ok && float64(len(object)) < min {
+		*errors = append(*errors, fmt.Sprintf("%s: must have at least %v properties", path, min))
+	}
+
+	if max, ok := keywords["maxProperties"].(float64); ok && float64(len(object)) > max {
+		*errors = append(*errors, fmt.Sprintf("%s: must have at most %v properties", path, max))
+	}
+}
+
+func (s jsonSchema) checkArray(keywords map[string]interface{}, array []interface{}, path string, errors *[]string) {
+
+	if items, ok := keywords["items"]; ok {
+		for i, item := range array {
+			s.check(items, item, fmt.Sprintf("%s[%d]", path, i), errors)
+		}
+	}
+
+	if min, ok := keywords["minItems"].(float64); ok && float64(len(array)) < min {
+		*errors = append(*errors, fmt.Sprintf("%s: must have at least %v items", path, min))
+	}
+
+	if max, ok := keywords["maxItems"].(float64); ok && float64(len(array)) > max {
+		*errors = append(*errors, fmt.Sprintf("%s: must have at most %v items", path, max))
+	}
+
+	if unique, ok := keywords["uniqueItems"].(bool); ok && unique {
+		for i := range array {
+			for j := i + 1; j < len(array); j++ {
+				if reflect.DeepEqual(array[i], array[j]) {
+					*errors = append(*errors, fmt.Sprintf("%s: items %d and %d are equal", path, i, j))
+				}
+			}
+		}
+	}
+}
+
+func checkString(keywords map[string]interface{}, value string, fail func(format string, args ...interface{})) {
+
+	length := float64(utf8.RuneCountInString(value))
+
+	if min, ok := keywords["minLength"].(float64); ok && length < min {
+		fail("must be at least %v characters long", min)
+	}
+
+	if max, ok := keywords["maxLength"].(float64); ok && length > max {
+		fail("must be at most %v characters long", max)
+	}
+
+	if expression, ok := keywords["pattern"].(string); ok {
+		pattern, err := regexp.Compile(expression)
+		if err != nil {
+			fail("invalid pattern %s in the schema: %s", expression, err)
+		} else if !pattern.MatchString(value) {
+			fail("doesn't match %s", expression)
+		}
+	}
+}
+
+func checkNumber(keywords map[string]interface{}, value float64, fail func(format string, args ...interface{})) {
+
+	if min, ok := keywords["minimum"].(float64); ok && value < min {
+		fail("must be at least %v", min)
+	}
+
+	if max, ok := keywords["maximum"].(float64); ok && value > max {
+		fail("must be at most %v", max)
+	}
+
+	if min, ok := keywords["exclusiveMinimum"].(float64); ok && value <= min {
+		fail("must be greater than %v", min)
+	}
+
+	if max, ok := keywords["exclusiveMaximum"].(float64); ok && value >= max {
+		fail("must be less than %v", max)
+	}
+
+	if divisor, ok := keywords["multipleOf"].(float64); ok && divisor > 0 && math.Mod(value, divisor) != 0 {
+		fail("must be a multiple of %v", divisor)
+	}
+}
+
+func (s jsonSchema) countMatches(schemas []interface{}, value interface{}) int {
+
+	matches := 0
+	for _, schema := range schemas {
+		var errors []string
+		s.check(schema, value, "$", &errors)
+		if len(errors) == 0 {
+			matches++
+		}
+	}
+
+	return matches
+}
+
+// resolve looks up a $ref within the schema, like #/definitions/flag or
+// #/$defs/flag. References to other documents aren't supported.
+func (s jsonSchema) resolve(ref string) (interface{}, error) {
+
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported $ref %s, only references within the schema are supported", ref)
+	}
+
+	current := s.root
+
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("$ref %s not found in the schema", ref)
+		}
+
+		if current, ok = object[token]; !ok {
+			return nil, fmt.Errorf("$ref %s not found in the schema", ref)
+		}
+	}
+
+	return current, nil
+}
+
+func matchesType(types interface{}, value interface{}) bool {
+
+	switch typed := types.(type) {
+	case string:
+		return typed == jsonType(value) || typed == "number" && jsonType(value) == "integer"
+	case []interface{}:
+		for _, nested := range typed {
+			if matchesType(nested, value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func formatTypes(types interface{}) string {
+
+	names, ok := types.([]interface{})
+	if !ok {
+		return fmt.Sprint(types)
+	}
+
+	formatted := make([]string, len(names))
+	for i, name := range names {
+		formatted[i] = fmt.Sprint(name)
+	}
+	sort.Strings(formatted)
+
+	return strings.Join(formatted, " or ")
+}
+
+// jsonType returns the JSON Schema type of a decoded JSON value. Whole
+// numbers are integers.
+func jsonType(value interface{}) string {
+
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if typed == math.Trunc(typed) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, candidate := range values {
+		if reflect.DeepEqual(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+var identifierKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// propertyPath appends key to a JSON path, bracketed unless it is a plain
+// identifier.
+func propertyPath(path string, key string) string {
+	if identifierKey.MatchString(key) {
+		return path + "." + key
+	}
+	return path + "[" + strconv.Quote(key) + "]"
+}
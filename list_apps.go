@@ -0,0 +1,1050 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// appSummary is the machine-readable representation of an app printed by
+// `list-apps --output json|yaml`. Field order and names are part of the
+// output contract, so only ever add to it.
+type appSummary struct {
+	Name       string                  `json:"name" yaml:"name"`
+	State      string                  `json:"state" yaml:"state"`
+	Guid       string                  `json:"guid" yaml:"guid"`
+	Instances  int                     `json:"instances" yaml:"instances"`
+	Memory     int                     `json:"memory" yaml:"memory"`
+	DiskQuota  int                     `json:"disk_quota" yaml:"disk_quota"`
+	UpdatedAt  string                  `json:"updated_at,omitempty" yaml:"updated_at,omitempty"`
+	URLs       []string                `json:"urls,omitempty" yaml:"urls,omitempty"`
+	Labels     map[string]string       `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Lifecycle  string                  `json:"lifecycle" yaml:"lifecycle"`
+	Image      string                  `json:"docker_image,omitempty" yaml:"docker_image,omitempty"`
+	Org        string                  `json:"org,omitempty" yaml:"org,omitempty"`
+	Space      string                  `json:"space,omitempty" yaml:"space,omitempty"`
+	Uploaded   string                  `json:"uploaded_at,omitempty" yaml:"uploaded_at,omitempty"`
+	LastEvent  *ccclient.EventModel    `json:"last_event,omitempty" yaml:"last_event,omitempty"`
+	Processes  []ccclient.ProcessModel `json:"processes,omitempty" yaml:"processes,omitempty"`
+	Foundation string                  `json:"foundation,omitempty" yaml:"foundation,omitempty"`
+}
+
+type listAppsOptions struct {
+	started     bool
+	stopped     bool
+	crashed     bool
+	output      string
+	concurrency int
+	org         string
+	space       string
+	nameFilter  *regexp.Regexp
+	buildpack   string
+	stack       string
+	stackGuid   string
+	sort        string
+	reverse     bool
+	columns     []string
+	routeFilter string
+	label       string
+	lifecycle   string
+	all         bool
+	stale       ageValue
+	summary     bool
+	events      bool
+	process     string
+	targets     string
+	stream      string
+	template    listTemplate
+
+	// spaces are listed once for all pages with --stream.
+	spaces map[string]ccclient.SpaceModel
+}
+
+// appOrders are the orders of `list-apps --sort`. Each one breaks ties by name.
+var appOrders = map[string]func(a, b ccclient.AppModel) bool{
+	"name": func(a, b ccclient.AppModel) bool {
+		return a.Entity.Name < b.Entity.Name
+	},
+	"state": func(a, b ccclient.AppModel) bool {
+		return a.Entity.State < b.Entity.State
+	},
+	"memory": func(a, b ccclient.AppModel) bool {
+		return a.Entity.Memory < b.Entity.Memory
+	},
+	"instances": func(a, b ccclient.AppModel) bool {
+		return a.Entity.Instances < b.Entity.Instances
+	},
+	"updated": func(a, b ccclient.AppModel) bool {
+		return a.Metadata.UpdatedAt < b.Metadata.UpdatedAt
+	},
+}
+
+// listAppsCommand holds the flags of list-apps, see parseOptions.
+type listAppsCommand struct {
+	options       listAppsOptions
+	nameFilter    string
+	columns       string
+	dockerOnly    bool
+	buildpackOnly bool
+}
+
+func (c *listAppsCommand) Name() string {
+	return "list-apps"
+}
+
+func (c *listAppsCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "list-apps",
+		HelpText: "List the apps visible to the current user.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf list-apps [--started | --stopped] [--crashed] [--format table|json|yaml|csv|ndjson|prometheus|template [--template TEMPLATE | --template-file FILE]] [--concurrency N] [--org ORG] [--space SPACE] [--name-filter REGEX] [--buildpack NAME] [--stack NAME] [--sort name|state|memory|instances|updated [--reverse]] [--columns NAME,...] [--route-filter DOMAIN] [--label SELECTOR] [--docker-only | --buildpack-only] [--all] [--stale AGE] [--summary] [--events] [--process TYPE] [--targets FILE] [--stream ndjson]",
+			Options: map[string]string{
+				"buildpack":      "Only list apps whose configured or detected buildpack contains NAME",
+				"stack":          "Only list apps running on the stack NAME",
+				"sort":           "Sort the apps by name, state, memory, instances or time of the last update",
+				"reverse":        "Reverse the order of --sort",
+				"columns":        "Comma-separated columns of the table (default name,state,instances,memory,disk). label:KEY shows the label KEY. processes and health-check show every process of the app",
+				"label":          "Only list apps matching the label selector, e.g. 'team=payments,env!=sandbox'. The selected labels are shown as columns",
+				"route-filter":   "Only list apps with a route on DOMAIN or one of its subdomains",
+				"name-filter":    "Only list apps whose name matches REGEX",
+				"crashed":        "Only list apps with at least one crashed or down instance",
+				"started":        "Only list started apps",
+				"stopped":        "Only list stopped apps",
+				"docker-only":    "Only list apps running a Docker image",
+				"buildpack-only": "Only list apps staged with a buildpack",
+				"format":         "Output format (default table). --output is an alias",
+				"concurrency":    "Number of pages fetched at the same time (default 4). Pages fetched through cf curl, when the CLI provides no API endpoint or access token, are still fetched one at a time",
+				"org":            "List the apps of ORG",
+				"space":          "List the apps of SPACE",
+				"all":            "List the apps of all spaces visible to the user, with their org and space",
+				"stale":          "Only list apps that were neither updated nor uploaded within AGE, e.g. 90d, 2w or 36h",
+				"summary":        "Print the instances and memory of the apps by state and the memory quota of the org instead of the apps",
+				"process":        "Only list apps running a process of TYPE, e.g. worker, with the instances, memory and disk of that process",
+				"events":         "Show the latest crash, stop or update of stopped and crashed apps, with its reason and actor",
+				"targets":        "List the apps of several foundations, with a foundation column. FILE is a YAML list of entries like {api: api.example.com, org: ORG, space: SPACE, token: TOKEN}. api is an endpoint or a target saved by the cf-targets plugin, whose login is used without token. Without space, all spaces of org are listed",
+				"stream":         "Print the apps of every page as soon as it is listed, as a JSON object per line (ndjson) like those of --format json. Can't be sorted",
+				"template":       "Go text/template printed for every app with --format template, with the fields of --format json, e.g. '{{.Name}}: {{.State}}'",
+				"template-file":  "Read the template of --format template from FILE",
+			},
+		},
+	}
+}
+
+func (c *listAppsCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("list-apps")
+	flags.BoolVar(&c.options.started, "started", false, "")
+	flags.BoolVar(&c.options.stopped, "stopped", false, "")
+	flags.BoolVar(&c.options.crashed, "crashed", false, "")
+	flags.StringVar(&c.options.output, "output", "", "")
+	flags.StringVar(&c.options.output, "format", "", "")
+	flags.IntVar(&c.options.concurrency, "concurrency", 4, "")
+	flags.StringVar(&c.options.org, "org", "", "")
+	flags.StringVar(&c.options.space, "space", "", "")
+	flags.StringVar(&c.nameFilter, "name-filter", "", "")
+	flags.StringVar(&c.options.buildpack, "buildpack", "", "")
+	flags.StringVar(&c.options.stack, "stack", "", "")
+	flags.StringVar(&c.options.sort, "sort", "", "")
+	flags.BoolVar(&c.options.reverse, "reverse", false, "")
+	flags.StringVar(&c.options.routeFilter, "route-filter", "", "")
+	flags.StringVar(&c.columns, "columns", strings.Join(defaultAppColumns, ","), "")
+	flags.StringVar(&c.options.label, "label", "", "")
+	flags.BoolVar(&c.dockerOnly, "docker-only", false, "")
+	flags.BoolVar(&c.buildpackOnly, "buildpack-only", false, "")
+	flags.BoolVar(&c.options.all, "all", false, "")
+	flags.Var(&c.options.stale, "stale", "")
+	flags.BoolVar(&c.options.summary, "summary", false, "")
+	flags.BoolVar(&c.options.events, "events", false, "")
+	flags.StringVar(&c.options.process, "process", "", "")
+	flags.StringVar(&c.options.targets, "targets", "", "")
+	flags.StringVar(&c.options.stream, "stream", "", "")
+	c.options.template.register(flags)
+
+	return flags
+}
+
+// schema is that of the apps, or of their usage with --summary.
+func (c *listAppsCommand) schema(args []string) formatters.Schema {
+
+	if c.options.summary {
+		return usageSchema
+	}
+
+	return appsSchema
+}
+
+func (c *listAppsCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	options := c.parseOptions()
+
+	stopInterrupts := handleInterrupts(ui)
+	defer stopInterrupts()
+	defer exitIfInterrupted(ui)
+
+	if options.targets != "" {
+		listTargetApps(ui, cliConnection, options)
+		return
+	}
+
+	client := ccClient(cliConnection)
+	client.Concurrency = options.concurrency
+
+	if options.output == "" && options.stream == "" && !globals.quiet {
+		endpoint, err := cliConnection.ApiEndpoint()
+		fatalIf(err)
+
+		announceListings(ui, client, endpoint)
+	}
+
+	scope := resolveScope(cliConnection, options.org, options.space)
+	scope.LabelSelector = options.label
+
+	if options.stream != "" {
+		out := &ndjsonWriter{out: ui.Out()}
+		streamApps(out, client, options, nil, func() error {
+			_, err := client.ListApps(scope)
+			return err
+		})
+		out.markPartial()
+		return
+	}
+
+	// The pages listed before an interrupt are printed.
+	apps, err := client.ListApps(scope)
+	if !interrupted() {
+		fatalIf(err)
+	}
+
+	apps = selectApps(client, apps, options)
+
+	if options.sort != "" {
+		sortApps(apps, appOrders[options.sort], options.reverse)
+	}
+
+	if options.summary {
+		printAppsUsage(ui, cliConnection, client, scope, apps, options.output)
+		return
+	}
+
+	printApps(ui, apps, options)
+}
+
+// listTargetApps lists the apps of the spaces of the --targets file, in
+// the order of the file unless sorted.
+func listTargetApps(ui UI, cliConnection plugin.CliConnection, options listAppsOptions) {
+
+	var apps []ccclient.AppModel
+	out := &ndjsonWriter{out: ui.Out()}
+
+	for _, space := range targetSpaces(cliConnection, options.targets) {
+		if interrupted() {
+			break
+		}
+
+		client := space.foundation.client
+		client.Concurrency = options.concurrency
+
+		if options.output == "" && options.stream == "" && !globals.quiet {
+			announceListings(ui, client, space.foundation.endpoint)
+		}
+
+		if options.stream != "" {
+			space := space
+			streamApps(out, client, options, space.describe, func() error {
+				_, err := space.listApps(ccclient.AppFilters{LabelSelector: options.label})
+				return err
+			})
+			continue
+		}
+
+		listed, err := space.listApps(ccclient.AppFilters{LabelSelector: options.label})
+		if interrupted() {
+			break
+		}
+		fatalIf(err)
+
+		apps = append(apps, selectApps(client, listed, options)...)
+	}
+
+	if options.sort != "" {
+		sortApps(apps, appOrders[options.sort], options.reverse)
+	}
+
+	if options.stream == "" {
+		printApps(ui, apps, options)
+	} else {
+		out.markPartial()
+	}
+}
+
+// streamApps prints the apps of every page of the listing run by list as
+// soon as the page has arrived, described by describe if given, and its
+// apps have been selected.
+//
+// The pages arrive in the workers of the client, so they are handed over to
+// the goroutine of the command, in which selecting the apps may fail.
+func streamApps(out *ndjsonWriter, client *ccclient.Client, options listAppsOptions, describe func(apps []ccclient.AppModel), list func() error) {
+
+	if needsAppSpaces(options) {
+		var err error
+		options.spaces, err = client.ListSpaces()
+		fatalIf(err)
+	}
+
+	pages := make(chan []ccclient.AppModel)
+	stopped := make(chan struct{})
+	defer close(stopped)
+
+	// Once the command has failed, the rest of the listing is dropped.
+	client.OnPage = func(page []ccclient.AppModel) {
+		select {
+		case pages <- append([]ccclient.AppModel(nil), page...):
+		case <-stopped:
+		}
+	}
+
+	var err error
+	go func() {
+		defer close(pages)
+		err = list()
+	}()
+
+	for apps := range pages {
+		if describe != nil {
+			describe(apps)
+		}
+
+		options.stackGuid = stackGuid(client, apps, options)
+
+		for _, summary := range appSummaries(selectApps(client, apps, options)) {
+			out.write(summary)
+		}
+	}
+	client.OnPage = nil
+
+	if !interrupted() {
+		fatalIf(err)
+	}
+}
+
+func announceListings(ui UI, client *ccclient.Client, endpoint string) {
+	client.OnList = func(path string) {
+		fmt.Fprintf(ui.Out(), "curling %s/%s\n", endpoint, path)
+	}
+}
+
+// selectApps applies the filters of the options to the listed apps and
+// fetches what their columns need, with as few requests as possible.
+func selectApps(client *ccclient.Client, apps []ccclient.AppModel, options listAppsOptions) []ccclient.AppModel {
+
+	options.stackGuid = stackGuid(client, apps, options)
+	apps = filterApps(apps, options)
+
+	if options.process != "" || containsColumn(options.columns, "processes") || containsColumn(options.columns, "health-check") {
+		fetchAppProcesses(client, apps, options.concurrency)
+	}
+
+	if options.process != "" {
+		apps = filterAppsByProcess(apps, options.process)
+	}
+
+	if needsAppSpaces(options) {
+		fetchAppSpaces(client, apps, options.spaces)
+	}
+
+	if options.routeFilter != "" || containsColumn(options.columns, "urls") {
+		fetchAppURLs(client, apps)
+	}
+
+	if options.routeFilter != "" {
+		apps = filterAppsByRoute(apps, options.routeFilter)
+	}
+
+	if options.crashed {
+		apps = filterCrashedApps(client, apps)
+	}
+
+	if options.events || containsColumn(options.columns, "event") || containsColumn(options.columns, "reason") || containsColumn(options.columns, "actor") {
+		fetchAppEvents(client, apps, options)
+	}
+
+	return apps
+}
+
+// needsAppSpaces reports whether the apps need the names of their org and
+// space. Apps of --targets are listed by space, whose names are known.
+func needsAppSpaces(options listAppsOptions) bool {
+	return options.targets == "" && (options.all || containsColumn(options.columns, "org") || containsColumn(options.columns, "space"))
+}
+
+func printApps(ui UI, apps []ccclient.AppModel, options listAppsOptions) {
+	printResult(ui.Out(), newFormatter(options.output, options.template), appListing{apps: apps, columns: options.columns})
+}
+
+// parseOptions validates the flags and turns them into the options of the
+// listing.
+func (c *listAppsCommand) parseOptions() listAppsOptions {
+
+	options := c.options
+	columns := c.columns
+
+	var err error
+
+	switch {
+	case c.dockerOnly && c.buildpackOnly:
+		fatalIf(usageErrorf("--docker-only and --buildpack-only can't be combined"))
+	case c.dockerOnly:
+		options.lifecycle = "docker"
+	case c.buildpackOnly:
+		options.lifecycle = "buildpack"
+	}
+
+	if options.all && (options.org != "" || options.space != "") {
+		fatalIf(usageErrorf("--all can't be combined with --org or --space"))
+	}
+
+	if options.targets != "" && (options.all || options.org != "" || options.space != "" || options.summary) {
+		fatalIf(usageErrorf("--targets can't be combined with --all, --org, --space or --summary"))
+	}
+
+	fatalIf(validateStream(options.stream))
+
+	if options.stream != "" && (options.output != "" || options.sort != "" || options.summary) {
+		fatalIf(usageErrorf("--stream can't be combined with --output, --sort or --summary"))
+	}
+
+	if c.nameFilter != "" {
+		options.nameFilter, err = regexp.Compile(c.nameFilter)
+		fatalIf(err)
+	}
+
+	if options.concurrency < 1 {
+		fatalIf(usageErrorf("Concurrency must be at least 1, got %d", options.concurrency))
+	}
+
+	if options.output == formatTable {
+		options.output = ""
+	}
+
+	if options.output == formatJson || options.output == formatNdjson || options.stream != "" {
+		globals.errorFormat = "json"
+	}
+
+	switch options.output {
+	case "", formatJson, formatYaml, formatCsv, formatNdjson, formatPrometheus, formatTemplate:
+	default:
+		fatalIf(usageErrorf("Unsupported output '%s'. Supported outputs: table, json, yaml, csv, ndjson, prometheus, template", options.output))
+	}
+
+	fatalIf(options.template.setup(options.output))
+
+	if options.summary && options.output != "" && options.output != formatJson && options.output != formatPrometheus {
+		fatalIf(usageErrorf("--summary is printed as table, json or prometheus"))
+	}
+
+	if options.output == formatPrometheus && !options.summary {
+		fatalIf(usageErrorf("--format prometheus prints the readings of --summary"))
+	}
+
+	// The default columns show where the apps are with --all or --targets
+	// and the labels selected by --label.
+	defaultColumns := columns == strings.Join(defaultAppColumns, ",")
+
+	if options.all && defaultColumns {
+		columns = "org,space," + columns
+	}
+
+	if options.targets != "" && defaultColumns {
+		columns = "foundation,org,space," + columns
+	}
+
+	if options.events && defaultColumns {
+		columns += ",event,reason,actor"
+	}
+
+	if options.label != "" {
+		keys, err := labelSelectorKeys(options.label)
+		fatalIf(err)
+
+		if defaultColumns {
+			for _, key := range keys {
+				columns += "," + labelColumnPrefix + key
+			}
+		}
+	}
+
+	options.columns, err = parseAppColumns(columns)
+	fatalIf(err)
+
+	if _, ok := appOrders[options.sort]; options.sort != "" && !ok {
+		fatalIf(usageErrorf("Unsupported sort '%s'. Supported sorts: name, state, memory, instances, updated", options.sort))
+	}
+
+	return options
+}
+
+// sortApps sorts apps by less, then by name.
+func sortApps(apps []ccclient.AppModel, less func(a, b ccclient.AppModel) bool, reverse bool) {
+
+	sort.SliceStable(apps, func(i, j int) bool {
+		a, b := apps[i], apps[j]
+		if reverse {
+			a, b = b, a
+		}
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a.Entity.Name < b.Entity.Name
+	})
+}
+
+func filterApps(apps []ccclient.AppModel, options listAppsOptions) []ccclient.AppModel {
+
+	var filtered []ccclient.AppModel
+	staleBefore := time.Now().Add(-time.Duration(options.stale))
+
+	for _, app := range apps {
+		if options.started && app.Entity.State != "STARTED" {
+			continue
+		}
+		if options.stopped && app.Entity.State != "STOPPED" {
+			continue
+		}
+		if options.nameFilter != nil && !options.nameFilter.MatchString(app.Entity.Name) {
+			continue
+		}
+		if options.buildpack != "" && !usesBuildpack(app.Entity, options.buildpack) {
+			continue
+		}
+		if options.stack != "" && !usesStack(app.Entity, options) {
+			continue
+		}
+		if options.lifecycle != "" && app.Entity.Lifecycle() != options.lifecycle {
+			continue
+		}
+		if options.stale > 0 && !lastChanged(app).Before(staleBefore) {
+			continue
+		}
+		filtered = append(filtered, app)
+	}
+
+	return filtered
+}
+
+// lastChanged returns when the app was last updated or had its package
+// uploaded. Apps without timestamps count as changed now, so that --stale
+// doesn't list them.
+func lastChanged(app ccclient.AppModel) time.Time {
+
+	var last time.Time
+
+	for _, timestamp := range []string{app.Metadata.UpdatedAt, app.Entity.PackageUpdatedAt} {
+		if parsed, err := time.Parse(time.RFC3339, timestamp); err == nil && parsed.After(last) {
+			last = parsed
+		}
+	}
+
+	if last.IsZero() {
+		return time.Now()
+	}
+
+	return last
+}
+
+// filterCrashedApps keeps the apps with at least one crashed instance. It
+// needs a stats request per app, so it runs after all other filters.
+func filterCrashedApps(client *ccclient.Client, apps []ccclient.AppModel) []ccclient.AppModel {
+
+	var crashed []ccclient.AppModel
+
+	for _, app := range apps {
+		states, err := client.InstanceStates(app.Metadata.Guid)
+		fatalIf(err)
+
+		for _, state := range states {
+			if state == "CRASHED" || state == "DOWN" {
+				crashed = append(crashed, app)
+				break
+			}
+		}
+	}
+
+	return crashed
+}
+
+// fetchAppSpaces sets the space and org names of the apps, from spaces if
+// already listed. A single listing of all spaces is cheaper than a request
+// per app.
+func fetchAppSpaces(client *ccclient.Client, apps []ccclient.AppModel, spaces map[string]ccclient.SpaceModel) {
+
+	if spaces == nil {
+		var err error
+		spaces, err = client.ListSpaces()
+		fatalIf(err)
+	}
+
+	for i := range apps {
+		space := spaces[apps[i].Entity.SpaceGuid]
+		apps[i].Entity.SpaceName = space.Name
+		apps[i].Entity.OrgName = space.OrgName
+	}
+}
+
+// fetchAppURLs looks up the routes of every app. It needs a request per
+// app, so it runs after the filters that don't.
+func fetchAppURLs(client *ccclient.Client, apps []ccclient.AppModel) {
+
+	for i := range apps {
+		urls, err := client.AppURLs(apps[i])
+		fatalIf(err)
+
+		apps[i].Entity.URLs = urls
+	}
+}
+
+// fetchAppProcesses looks up the processes of every app. It needs a request
+// per app, so it runs after the filters that don't.
+func fetchAppProcesses(client *ccclient.Client, apps []ccclient.AppModel, concurrency int) {
+
+	inParallel(len(apps), concurrency, func(index int) {
+		processes, err := client.AppProcesses(apps[index].Metadata.Guid)
+		fatalIf(err)
+
+		apps[index].Entity.Processes = processes
+	})
+}
+
+// filterAppsByProcess keeps the apps running a process of the given type.
+// Their instances, memory and disk become those of that process, so that
+// the table and its totals describe only the selected processes.
+func filterAppsByProcess(apps []ccclient.AppModel, processType string) []ccclient.AppModel {
+
+	var filtered []ccclient.AppModel
+
+	for _, app := range apps {
+		process := findProcess(app.Entity.Processes, processType)
+		if process == nil {
+			continue
+		}
+
+		app.Entity.Processes = []ccclient.ProcessModel{*process}
+		app.Entity.Instances = process.Instances
+		app.Entity.Memory = process.MemoryInMb
+		app.Entity.DiskQuota = process.DiskInMb
+		filtered = append(filtered, app)
+	}
+
+	return filtered
+}
+
+// fetchAppEvents looks up the latest crash, stop or update of the stopped
+// apps, and of all apps kept by --crashed, since those are the ones whose
+// events explain their state. It needs a request per app, so it runs after
+// all filters.
+func fetchAppEvents(client *ccclient.Client, apps []ccclient.AppModel, options listAppsOptions) {
+
+	inParallel(len(apps), options.concurrency, func(index int) {
+		app := &apps[index]
+
+		if app.Entity.State != "STOPPED" && !options.crashed {
+			return
+		}
+
+		event, err := client.LatestAppEvent(app.Metadata.Guid)
+		fatalIf(err)
+
+		app.Entity.LastEvent = event
+	})
+}
+
+// filterAppsByRoute keeps the apps with a route on domain or on one of its
+// subdomains, so that the owner of a host name can be found.
+func filterAppsByRoute(apps []ccclient.AppModel, domain string) []ccclient.AppModel {
+
+	domain = strings.ToLower(domain)
+	var filtered []ccclient.AppModel
+
+	for _, app := range apps {
+		for _, url := range app.Entity.URLs {
+			host := strings.ToLower(ccclient.URLHost(url))
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				filtered = append(filtered, app)
+				break
+			}
+		}
+	}
+
+	return filtered
+}
+
+// usesBuildpack matches the configured as well as the detected buildpack,
+// since apps often leave the buildpack to detection.
+func usesBuildpack(entity ccclient.EntityModel, buildpack string) bool {
+	buildpack = strings.ToLower(buildpack)
+	return strings.Contains(strings.ToLower(entity.Buildpack), buildpack) ||
+		strings.Contains(strings.ToLower(entity.DetectedBuildpack), buildpack)
+}
+
+func usesStack(entity ccclient.EntityModel, options listAppsOptions) bool {
+	if entity.StackGuid != "" {
+		return entity.StackGuid == options.stackGuid
+	}
+	return entity.StackName == options.stack
+}
+
+// stackGuid returns the GUID of the stack of --stack, looking it up unless
+// the options have it already or the apps name their stack.
+func stackGuid(client *ccclient.Client, apps []ccclient.AppModel, options listAppsOptions) string {
+
+	if options.stack == "" || options.stackGuid != "" || !hasStackGuids(apps) {
+		return options.stackGuid
+	}
+
+	guid, err := client.FindGuid("Stack", options.stack, "v2/stacks")
+	fatalIf(err)
+
+	return guid
+}
+
+func hasStackGuids(apps []ccclient.AppModel) bool {
+	for _, app := range apps {
+		if app.Entity.StackGuid != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// appColumn is a column of the list-apps table. Columns with a total add
+// up the resources reserved by all instances. Columns with a color paint
+// every cell, so that the escape codes don't break the alignment.
+type appColumn struct {
+	value func(app ccclient.AppModel) string
+	total func(apps []ccclient.AppModel) string
+	color func(app ccclient.AppModel) string
+}
+
+var appColumns = map[string]appColumn{
+	"name": {value: func(app ccclient.AppModel) string { return app.Entity.Name }},
+	"guid": {value: func(app ccclient.AppModel) string { return app.Metadata.Guid }},
+	"state": {
+		value: func(app ccclient.AppModel) string { return strings.ToLower(app.Entity.State) },
+		color: func(app ccclient.AppModel) string { return stateColor(app.Entity.State) },
+	},
+	"instances": {
+		value: func(app ccclient.AppModel) string { return strconv.Itoa(app.Entity.Instances) },
+		total: func(apps []ccclient.AppModel) string {
+			return strconv.Itoa(sumApps(apps, func(entity ccclient.EntityModel) int { return entity.Instances }))
+		},
+	},
+	"memory": {
+		value: func(app ccclient.AppModel) string { return formatMegabytes(app.Entity.Memory) },
+		total: func(apps []ccclient.AppModel) string {
+			return formatMegabytes(sumApps(apps, func(entity ccclient.EntityModel) int { return entity.Memory * entity.Instances }))
+		},
+	},
+	"disk": {
+		value: func(app ccclient.AppModel) string { return formatMegabytes(app.Entity.DiskQuota) },
+		total: func(apps []ccclient.AppModel) string {
+			return formatMegabytes(sumApps(apps, func(entity ccclient.EntityModel) int { return entity.DiskQuota * entity.Instances }))
+		},
+	},
+	"buildpack": {value: func(app ccclient.AppModel) string {
+		if app.Entity.Buildpack != "" {
+			return app.Entity.Buildpack
+		}
+		return app.Entity.DetectedBuildpack
+	}},
+	"stack":        {value: func(app ccclient.AppModel) string { return app.Entity.StackName }},
+	"updated":      {value: func(app ccclient.AppModel) string { return app.Metadata.UpdatedAt }},
+	"uploaded":     {value: func(app ccclient.AppModel) string { return app.Entity.PackageUpdatedAt }},
+	"urls":         {value: func(app ccclient.AppModel) string { return strings.Join(app.Entity.URLs, ", ") }},
+	"lifecycle":    {value: func(app ccclient.AppModel) string { return app.Entity.Lifecycle() }},
+	"image":        {value: func(app ccclient.AppModel) string { return app.Entity.DockerImage }},
+	"org":          {value: func(app ccclient.AppModel) string { return app.Entity.OrgName }},
+	"space":        {value: func(app ccclient.AppModel) string { return app.Entity.SpaceName }},
+	"foundation":   {value: func(app ccclient.AppModel) string { return app.Entity.Foundation }},
+	"processes":    {value: func(app ccclient.AppModel) string { return formatProcesses(app.Entity.Processes) }},
+	"health-check": {value: func(app ccclient.AppModel) string { return formatHealthChecks(app.Entity.Processes) }},
+	"event": {value: func(app ccclient.AppModel) string {
+		return appEventValue(app, func(event ccclient.EventModel) string { return eventName(event.Type) + " " + event.Timestamp })
+	}},
+	"reason": {value: func(app ccclient.AppModel) string {
+		return appEventValue(app, func(event ccclient.EventModel) string { return event.Reason })
+	}},
+	"actor": {value: func(app ccclient.AppModel) string {
+		return appEventValue(app, func(event ccclient.EventModel) string { return event.Actor })
+	}},
+}
+
+func appEventValue(app ccclient.AppModel, value func(event ccclient.EventModel) string) string {
+	if app.Entity.LastEvent == nil {
+		return ""
+	}
+	return value(*app.Entity.LastEvent)
+}
+
+// eventName shortens the event types of v2 and v3 alike, e.g.
+// audit.app.process.crash to crash.
+func eventName(eventType string) string {
+	return eventType[strings.LastIndex(eventType, ".")+1:]
+}
+
+// labelColumnPrefix names the columns of labels, e.g. label:team.
+const labelColumnPrefix = "label:"
+
+// lookupAppColumn returns the column of --columns called name, which is
+// either one of appColumns or the column of a label.
+func lookupAppColumn(name string) (appColumn, bool) {
+
+	if key := strings.TrimPrefix(name, labelColumnPrefix); key != name && key != "" {
+		return appColumn{value: func(app ccclient.AppModel) string { return app.Entity.Labels[key] }}, true
+	}
+
+	column, ok := appColumns[name]
+	return column, ok
+}
+
+// labelRequirement matches a requirement of a label selector: a key, a
+// negated key, an equality or an in/notin set.
+var labelRequirement = regexp.MustCompile(`^\s*!?([A-Za-z0-9][-A-Za-z0-9_./]*)\s*(?:(?:==?|!=)\s*[-A-Za-z0-9_.]*|\s(?:in|notin)\s*\([^()]*\))?\s*$`)
+
+// labelSelectorKeys returns the label keys of selector in order, failing on
+// selectors the Cloud Controller would reject.
+func labelSelectorKeys(selector string) ([]string, error) {
+
+	var keys []string
+	seen := map[string]bool{}
+
+	depth, start := 0, 0
+	requirements := []string{}
+
+	for i, char := range selector {
+		switch char {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				requirements = append(requirements, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	requirements = append(requirements, selector[start:])
+
+	for _, requirement := range requirements {
+		match := labelRequirement.FindStringSubmatch(requirement)
+
+		if match == nil {
+			return nil, usageErrorf("Invalid label selector requirement '%s'", strings.TrimSpace(requirement))
+		}
+
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			keys = append(keys, match[1])
+		}
+	}
+
+	return keys, nil
+}
+
+func containsColumn(columns []string, name string) bool {
+	for _, column := range columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultAppColumns are the columns of `cf apps`.
+var defaultAppColumns = []string{"name", "state", "instances", "memory", "disk"}
+
+// parseAppColumns parses the comma-separated column names of --columns.
+func parseAppColumns(list string) ([]string, error) {
+
+	var columns []string
+
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+
+		if _, ok := lookupAppColumn(name); !ok {
+			return nil, usageErrorf("Unknown column '%s'. Valid columns: %s", name, strings.Join(sortedColumnNames(), ", "))
+		}
+
+		columns = append(columns, name)
+	}
+
+	return columns, nil
+}
+
+func sortedColumnNames() []string {
+
+	names := make([]string, 0, len(appColumns))
+	for name := range appColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func sumApps(apps []ccclient.AppModel, value func(entity ccclient.EntityModel) int) int {
+
+	sum := 0
+	for _, app := range apps {
+		sum += value(app.Entity)
+	}
+
+	return sum
+}
+
+// printAppsTable prints apps like `cf apps`, followed by the totals of the
+// columns that have one.
+func printAppsTable(out io.Writer, apps []ccclient.AppModel, columns []string) {
+
+	table := tabwriter.NewWriter(out, 0, 4, 3, ' ', 0)
+
+	plain := func(cells []string) []string {
+		for i, name := range columns {
+			if column, _ := lookupAppColumn(name); column.color != nil {
+				cells[i] = paint(colorDefault, cells[i])
+			}
+		}
+		return cells
+	}
+
+	fmt.Fprintln(table, strings.Join(plain(append([]string(nil), columns...)), "\t"))
+
+	for _, app := range apps {
+		cells := make([]string, len(columns))
+		for i, name := range columns {
+			column, _ := lookupAppColumn(name)
+			cells[i] = column.value(app)
+			if column.color != nil {
+				cells[i] = paint(column.color(app), cells[i])
+			}
+		}
+		fmt.Fprintln(table, strings.Join(cells, "\t"))
+	}
+
+	totals := make([]string, len(columns))
+	hasTotals := false
+
+	for i, name := range columns {
+		if column, _ := lookupAppColumn(name); column.total != nil {
+			totals[i] = column.total(apps)
+			hasTotals = true
+		}
+	}
+
+	if hasTotals {
+		if totals[0] == "" {
+			totals[0] = "total"
+		}
+		fmt.Fprintln(table, strings.Join(plain(totals), "\t"))
+	}
+
+	table.Flush()
+}
+
+// formatMegabytes formats a size in megabytes the way cf does, e.g. 512M or 1G.
+func formatMegabytes(megabytes int) string {
+
+	if megabytes >= 1024 && megabytes%1024 == 0 {
+		return fmt.Sprintf("%dG", megabytes/1024)
+	}
+
+	return fmt.Sprintf("%dM", megabytes)
+}
+
+func appSummaries(apps []ccclient.AppModel) []appSummary {
+
+	summaries := make([]appSummary, 0, len(apps))
+
+	for _, app := range apps {
+		summaries = append(summaries, appSummary{
+			Name:       app.Entity.Name,
+			State:      app.Entity.State,
+			Guid:       app.Metadata.Guid,
+			Instances:  app.Entity.Instances,
+			Memory:     app.Entity.Memory,
+			DiskQuota:  app.Entity.DiskQuota,
+			UpdatedAt:  app.Metadata.UpdatedAt,
+			URLs:       app.Entity.URLs,
+			Labels:     app.Entity.Labels,
+			Lifecycle:  app.Entity.Lifecycle(),
+			Image:      app.Entity.DockerImage,
+			Org:        app.Entity.OrgName,
+			Space:      app.Entity.SpaceName,
+			Uploaded:   app.Entity.PackageUpdatedAt,
+			LastEvent:  app.Entity.LastEvent,
+			Processes:  app.Entity.Processes,
+			Foundation: app.Entity.Foundation,
+		})
+	}
+
+	return summaries
+}
+
+// appsSchema is the schema of the JSON output of list-apps.
+var appsSchema = formatters.Schema{Name: "apps", Version: 1, Results: []appSummary{}}
+
+// appListing is the result of list-apps, the apps with the columns of the
+// table.
+type appListing struct {
+	apps    []ccclient.AppModel
+	columns []string
+}
+
+func (l appListing) Items() interface{} {
+	return appSummaries(l.apps)
+}
+
+func (l appListing) Schema() formatters.Schema {
+	return appsSchema
+}
+
+func (l appListing) Print(out io.Writer) error {
+	printAppsTable(out, l.apps, l.columns)
+	return nil
+}
+
+// Header and Rows are the CSV of the fields of appSummary, in the same order.
+func (l appListing) Header() []string {
+	return []string{"name", "state", "guid", "instances", "memory", "disk_quota", "updated_at"}
+}
+
+func (l appListing) Rows() [][]string {
+
+	var rows [][]string
+
+	for _, summary := range appSummaries(l.apps) {
+		rows = append(rows, []string{
+			summary.Name,
+			summary.State,
+			summary.Guid,
+			strconv.Itoa(summary.Instances),
+			strconv.Itoa(summary.Memory),
+			strconv.Itoa(summary.DiskQuota),
+			summary.UpdatedAt,
+		})
+	}
+
+	return rows
+}
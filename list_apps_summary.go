@@ -0,0 +1,160 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// stateUsage adds up the apps of one state. Memory is reserved by every
+// instance, so it is the memory of the apps times their instances.
+type stateUsage struct {
+	State     string `json:"state"`
+	Apps      int    `json:"apps"`
+	Instances int    `json:"instances"`
+	Memory    int    `json:"memory"`
+}
+
+// appsUsageReport is the result of `list-apps --summary`.
+type appsUsageReport struct {
+	States []stateUsage           `json:"states"`
+	Quota  ccclient.OrgQuotaModel `json:"quota"`
+}
+
+// usageSchema is the schema of the JSON output of list-apps --summary.
+var usageSchema = formatters.Schema{Name: "usage", Version: 1, Results: appsUsageReport{}}
+
+func summarizeApps(apps []ccclient.AppModel) []stateUsage {
+
+	byState := map[string]*stateUsage{}
+
+	for _, app := range apps {
+		usage, ok := byState[app.Entity.State]
+		if !ok {
+			usage = &stateUsage{State: app.Entity.State}
+			byState[app.Entity.State] = usage
+		}
+
+		usage.Apps++
+		usage.Instances += app.Entity.Instances
+		usage.Memory += app.Entity.Memory * app.Entity.Instances
+	}
+
+	states := make([]stateUsage, 0, len(byState))
+	for _, usage := range byState {
+		states = append(states, *usage)
+	}
+
+	sort.Slice(states, func(i, j int) bool { return states[i].State < states[j].State })
+
+	return states
+}
+
+// printAppsUsage prints the usage of the listed apps by state and the quota
+// of the org the apps are in.
+func printAppsUsage(ui UI, cliConnection plugin.CliConnection, client *ccclient.Client, scope ccclient.AppFilters, apps []ccclient.AppModel, output string) {
+
+	orgGuid := scope.OrgGuid
+
+	if orgGuid == "" {
+		org, err := targetedOrg(cliConnection)
+		fatalIf(err)
+		orgGuid = org.Guid
+	}
+
+	quota, err := client.OrgQuota(orgGuid)
+	fatalIf(err)
+
+	printResult(ui.Out(), newFormatter(output, listTemplate{}), appsUsageReport{States: summarizeApps(apps), Quota: quota})
+}
+
+func (r appsUsageReport) Schema() formatters.Schema {
+	return usageSchema
+}
+
+func (r appsUsageReport) gauges() []*gauge {
+	return usageGauges(r.States, r.Quota)
+}
+
+func (r appsUsageReport) Print(out io.Writer) error {
+	writeAppsUsage(out, r.States, r.Quota)
+	return nil
+}
+
+// usageGauges are the readings of `list-apps --summary --format prometheus`,
+// labelled with the org and the lowercase state of the apps, like
+// cf_apps_total{org="payments",state="started"}. Unlimited quotas have no
+// limit gauges.
+func usageGauges(states []stateUsage, quota ccclient.OrgQuotaModel) []*gauge {
+
+	apps := &gauge{name: "cf_apps_total", help: "Number of apps, by state."}
+	instances := &gauge{name: "cf_app_instances_total", help: "Number of app instances, by state of their app."}
+	memory := &gauge{name: "cf_app_memory_megabytes", help: "Memory reserved by the instances of the apps, by state."}
+
+	for _, usage := range states {
+		state := strings.ToLower(usage.State)
+		apps.add(float64(usage.Apps), "org", quota.OrgName, "state", state)
+		instances.add(float64(usage.Instances), "org", quota.OrgName, "state", state)
+		memory.add(float64(usage.Memory), "org", quota.OrgName, "state", state)
+	}
+
+	used := &gauge{name: "cf_org_memory_used_megabytes", help: "Memory used by the org."}
+	used.add(float64(quota.MemoryUsed), "org", quota.OrgName, "quota", quota.QuotaName)
+
+	gauges := []*gauge{apps, instances, memory, used}
+
+	if quota.MemoryLimit >= 0 {
+		limit := &gauge{name: "cf_org_memory_limit_megabytes", help: "Memory limit of the quota of the org."}
+		limit.add(float64(quota.MemoryLimit), "org", quota.OrgName, "quota", quota.QuotaName)
+		gauges = append(gauges, limit)
+	}
+
+	if quota.InstanceLimit >= 0 {
+		limit := &gauge{name: "cf_org_app_instance_limit", help: "App instance limit of the quota of the org."}
+		limit.add(float64(quota.InstanceLimit), "org", quota.OrgName, "quota", quota.QuotaName)
+		gauges = append(gauges, limit)
+	}
+
+	return gauges
+}
+
+func writeAppsUsage(out io.Writer, states []stateUsage, quota ccclient.OrgQuotaModel) {
+
+	var total stateUsage
+
+	table := tabwriter.NewWriter(out, 0, 4, 3, ' ', 0)
+	fmt.Fprintf(table, "%s\tapps\tinstances\tmemory\n", paint(colorDefault, "state"))
+	for _, usage := range states {
+		fmt.Fprintf(table, "%s\t%d\t%d\t%s\n", paint(stateColor(usage.State), strings.ToLower(usage.State)), usage.Apps, usage.Instances, formatMegabytes(usage.Memory))
+
+		total.Apps += usage.Apps
+		total.Instances += usage.Instances
+		total.Memory += usage.Memory
+	}
+	fmt.Fprintf(table, "%s\t%d\t%d\t%s\n", paint(colorDefault, "total"), total.Apps, total.Instances, formatMegabytes(total.Memory))
+	table.Flush()
+
+	fmt.Fprintln(out)
+
+	memoryLimit := "unlimited"
+	if quota.MemoryLimit >= 0 {
+		memoryLimit = formatMegabytes(quota.MemoryLimit)
+	}
+
+	instanceLimit := "unlimited"
+	if quota.InstanceLimit >= 0 {
+		instanceLimit = strconv.Itoa(quota.InstanceLimit)
+	}
+
+	fmt.Fprintf(out, "Org %s (quota %s): %s of %s memory used", quota.OrgName, quota.QuotaName, formatMegabytes(quota.MemoryUsed), memoryLimit)
+	if quota.MemoryLimit > 0 {
+		fmt.Fprintf(out, " (%d%%)", quota.MemoryUsed*100/quota.MemoryLimit)
+	}
+	fmt.Fprintf(out, ", app instance limit %s\n", instanceLimit)
+}
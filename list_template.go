@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"strings"
+	"text/template"
+)
+
+// formatTemplate prints every element of a listing with --template or
+// --template-file, like `docker ps --format`. The fields are those of the
+// JSON output, with Go names, e.g. `{{.Name}}: {{.State}}` for list-apps.
+const formatTemplate = "template"
+
+// listTemplate holds the --template and --template-file flags of the
+// listing commands.
+type listTemplate struct {
+	text   string
+	file   string
+	parsed *template.Template
+}
+
+var listTemplateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+func (t *listTemplate) register(flags *flag.FlagSet) {
+	flags.StringVar(&t.text, "template", "", "")
+	flags.StringVar(&t.file, "template-file", "", "")
+}
+
+// setup parses the template of --format template, which needs exactly one
+// of --template and --template-file.
+func (t *listTemplate) setup(format string) error {
+
+	given := t.text != "" || t.file != ""
+
+	if format != formatTemplate {
+		if given {
+			return usageErrorf("--template and --template-file need --format template")
+		}
+		return nil
+	}
+
+	if t.text != "" && t.file != "" {
+		return usageErrorf("Only one of --template and --template-file can be given")
+	}
+
+	if !given {
+		return usageErrorf("--format template needs --template or --template-file")
+	}
+
+	text := t.text
+	if t.file != "" {
+		source, err := ioutil.ReadFile(t.file)
+		if err != nil {
+			return err
+		}
+		text = strings.TrimSuffix(string(source), "\n")
+	}
+
+	var err error
+	t.parsed, err = template.New("template").Option("missingkey=error").Funcs(listTemplateFuncs).Parse(text)
+	if err != nil {
+		return usageErrorf("Invalid template: %s", err)
+	}
+
+	return nil
+}
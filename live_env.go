@@ -0,0 +1,84 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// envLine matches the first line of a variable in the output of `env`.
+// Other lines continue the value of the previous variable.
+var envLine = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+
+// liveEnv runs `env` in the first instance of the app over `cf ssh`, which
+// shows the variables the running process actually got.
+func liveEnv(cliConnection plugin.CliConnection, appName string) (map[string]string, error) {
+
+	output, err := cliConnection.CliCommandWithoutTerminalOutput("ssh", appName, "-c", "env")
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to run env in '%s' over cf ssh: %s", appName, err)
+	}
+
+	return parseEnvOutput(strings.Join(output, "\n")), nil
+}
+
+func parseEnvOutput(output string) map[string]string {
+
+	vars := map[string]string{}
+	last := ""
+
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		if match := envLine.FindStringSubmatch(line); match != nil {
+			last = match[1]
+			vars[last] = match[2]
+		} else if last != "" {
+			vars[last] += "\n" + line
+		}
+	}
+
+	return vars
+}
+
+// writeLiveDiff compares the user-provided variables to those the instance
+// sees. Variables that differ were changed since the instance was started.
+// Variables only set in the instance aren't compared, since the platform
+// sets many of them.
+func writeLiveDiff(out io.Writer, appName string, desired map[string]interface{}, live map[string]string, hide bool) {
+
+	var missing, changed []string
+
+	for _, key := range sortedKeys(desired) {
+		value, ok := live[key]
+
+		switch {
+		case !ok:
+			missing = append(missing, key)
+		case value != formatEnvValue(desired[key]):
+			changed = append(changed, key)
+		}
+	}
+
+	if len(missing) == 0 && len(changed) == 0 {
+		fmt.Fprintf(out, "The instances of '%s' see the desired environment\n", appName)
+		return
+	}
+
+	format := func(key string, value string) string {
+		if hide && secretKey.MatchString(key) {
+			return mask(value)
+		}
+		return value
+	}
+
+	for _, key := range missing {
+		fmt.Fprintf(out, "+ %s (not seen by the instances)\n", key)
+	}
+	for _, key := range changed {
+		fmt.Fprintf(out, "~ %s: %s (live) -> %s (desired)\n", paint(colorYellow, key), format(key, live[key]), format(key, formatEnvValue(desired[key])))
+	}
+
+	fmt.Fprintf(out, "\nUse 'cf restage %s' to apply the desired environment\n", appName)
+}
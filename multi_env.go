@@ -0,0 +1,104 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"io"
+	"strings"
+)
+
+// multiAppFormats can hold the environments of several apps.
+var multiAppFormats = []string{formatTable, formatDotenv, formatShell, formatYaml, formatJson}
+
+func (p *GetEnvPlugin) validateMultiApp() {
+
+	if p.query != "" || p.watch || p.mergeInto != "" {
+		failUsage("--query, --watch and --merge-into take a single app")
+	}
+
+	for _, format := range multiAppFormats {
+		if p.format == format {
+			return
+		}
+	}
+
+	failUsage("Format '%s' takes a single app. Formats for several apps: %s", p.format, strings.Join(multiAppFormats, ", "))
+}
+
+// getEnvs fetches the env of every app with a pool of workers and prints
+// them in the order the apps were given.
+func (p *GetEnvPlugin) getEnvs(cliConnection plugin.CliConnection) {
+
+	// Apps are resolved one after another, since the CLI runs one command
+	// at a time for RPC calls like GetApp.
+	scope := resolveScope(cliConnection, p.org, p.space)
+	guids := make([]string, len(p.appNames))
+	for i, name := range p.appNames {
+		guids[i] = findAppGuid(cliConnection, scope, name)
+	}
+
+	envs := make([]map[string]interface{}, len(p.appNames))
+
+	inParallel(len(guids), p.concurrency, func(index int) {
+		envs[index] = p.fetchEnv(cliConnection, p.appNames[index], guids[index])
+	})
+
+	if p.shouldRedact() {
+		for i, env := range envs {
+			envs[i] = redactEnv(env)
+		}
+	}
+
+	out, done := p.openOut()
+	defer done()
+
+	p.writeEnvs(out, envs)
+}
+
+// writeEnvs prints the structured formats as one object keyed by app name,
+// and the others one app after another under a header.
+func (p *GetEnvPlugin) writeEnvs(out io.Writer, envs []map[string]interface{}) {
+
+	if p.format == formatYaml || p.format == formatJson {
+		keyed := make(map[string]interface{}, len(envs))
+		for i, env := range envs {
+			keyed[p.appNames[i]] = p.document(env)
+		}
+
+		if p.format == formatYaml {
+			writeYaml(out, keyed)
+		} else {
+			writeJson(out, envsSchema.Of(keyed))
+		}
+		return
+	}
+
+	for i, env := range envs {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+
+		if p.format == formatTable {
+			fmt.Fprintf(out, "%s:\n", p.appNames[i])
+		} else {
+			fmt.Fprintf(out, "# %s\n", p.appNames[i])
+		}
+
+		p.writeEnvTo(out, env)
+	}
+}
+
+// findAppGuid resolves the app in the targeted space, or in scope if it
+// isn't empty.
+func findAppGuid(cliConnection plugin.CliConnection, scope ccclient.AppFilters, appName string) string {
+
+	if scope.Empty() || globals.guid != "" {
+		return resolveApp(cliConnection, appName).Guid
+	}
+
+	guid, err := ccClient(cliConnection).FindGuid("App", appName, "v2/apps", scope.V2Filters()...)
+	fatalIf(err)
+
+	return guid
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"io"
+	"sync"
+)
+
+// formatNdjson is the --format of listing and scanning commands printing a
+// JSON object per line, for `jq -c`, log shippers and tools processing the
+// lines in parallel. It is also the --stream mode of list-apps and find-env,
+// which print every result as soon as it is found.
+const formatNdjson = "ndjson"
+
+func validateStream(stream string) error {
+
+	if stream != "" && stream != formatNdjson {
+		return usageErrorf("Unsupported stream '%s'. Supported streams: ndjson", stream)
+	}
+
+	return nil
+}
+
+// ndjsonWriter writes values one per line, whole lines at a time, so that
+// the workers of a scan can share it.
+type ndjsonWriter struct {
+	lock sync.Mutex
+	out  io.Writer
+}
+
+func (w *ndjsonWriter) write(value interface{}) {
+
+	encoded, err := json.Marshal(value)
+	fatalIf(err)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.out.Write(append(encoded, '\n'))
+}
+
+// markPartial ends the output with a PartialMarker after an interrupt.
+func (w *ndjsonWriter) markPartial() {
+	if interrupted() {
+		w.write(formatters.PartialMarker{Partial: true})
+	}
+}
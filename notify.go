@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// notification is POSTed to --notify-url when env-drift finds drift,
+// env-audit finds problems or `get-env --watch` sees changes. Like the
+// findings of env-audit, it names keys but never contains values.
+type notification struct {
+	Event    string         `json:"event"`
+	App      string         `json:"app,omitempty"`
+	Space    string         `json:"space,omitempty"`
+	Source   string         `json:"source,omitempty"`
+	Added    []string       `json:"added,omitempty"`
+	Removed  []string       `json:"removed,omitempty"`
+	Changed  []string       `json:"changed,omitempty"`
+	Findings []auditFinding `json:"findings,omitempty"`
+	Time     time.Time      `json:"time"`
+	Message  string         `json:"message"`
+}
+
+// notificationTemplates are the default messages by event. --notify-template
+// replaces them.
+var notificationTemplates = map[string]string{
+	"drift":  `Environment of '{{.App}}' drifted from {{.Source}}: {{len .Added}} added, {{len .Removed}} removed, {{len .Changed}} changed`,
+	"audit":  `{{len .Findings}} problems found in the environment of space {{.Space}}`,
+	"change": `Environment of '{{.App}}' changed:{{if .Added}} added {{join .Added ", "}}{{end}}{{if .Removed}} removed {{join .Removed ", "}}{{end}}{{if .Changed}} changed {{join .Changed ", "}}{{end}}`,
+}
+
+// notifier holds the --notify-url, --notify-slack and --notify-template
+// flags of the commands that notify.
+type notifier struct {
+	url      string
+	slack    string
+	template string
+	parsed   *template.Template
+}
+
+func (n *notifier) register(flags *flag.FlagSet) {
+	flags.StringVar(&n.url, "notify-url", "", "")
+	flags.StringVar(&n.slack, "notify-slack", "", "")
+	flags.StringVar(&n.template, "notify-template", "", "")
+}
+
+func (n *notifier) enabled() bool {
+	return n.url != "" || n.slack != ""
+}
+
+// validate parses --notify-template, so that a broken template fails before
+// anything is checked rather than when there is something to report.
+func (n *notifier) validate() error {
+
+	if n.template == "" {
+		return nil
+	}
+
+	if !n.enabled() {
+		return usageErrorf("--notify-template needs --notify-url or --notify-slack")
+	}
+
+	var err error
+	n.parsed, err = template.New("notify-template").Option("missingkey=error").Funcs(notificationFuncs).Parse(n.template)
+	if err != nil {
+		return usageErrorf("Invalid --notify-template: %s", err)
+	}
+
+	return nil
+}
+
+var notificationFuncs = template.FuncMap{"join": strings.Join}
+
+// notify renders the message of event and POSTs it, as the JSON of the
+// notification to --notify-url and as the text of a message to
+// --notify-slack.
+func (n *notifier) notify(event notification) error {
+
+	if !n.enabled() {
+		return nil
+	}
+
+	tmpl := n.parsed
+	if tmpl == nil {
+		tmpl = template.Must(template.New(event.Event).Funcs(notificationFuncs).Parse(notificationTemplates[event.Event]))
+	}
+
+	var message bytes.Buffer
+	if err := tmpl.Execute(&message, event); err != nil {
+		return fmt.Errorf("Failed to render the notification: %s", err)
+	}
+
+	event.Message = message.String()
+	event.Time = time.Now().UTC()
+
+	if n.url != "" {
+		if err := postJson(n.url, event); err != nil {
+			return err
+		}
+	}
+
+	if n.slack != "" {
+		if err := postJson(n.slack, map[string]string{"text": event.Message}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// postJson POSTs payload to url. Webhook URLs often carry their secret, so
+// errors only name the host.
+func postJson(url string, payload interface{}) error {
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	verbosef("Notifying %s", endpointHost(url))
+
+	client := &http.Client{Timeout: globals.timeout}
+
+	response, err := client.Post(url, "application/json", bytes.NewReader(encoded))
+	if err != nil {
+		return fmt.Errorf("Failed to notify %s: %s", endpointHost(url), strings.Replace(err.Error(), url, endpointHost(url), -1))
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("Failed to notify %s: status %d", endpointHost(url), response.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+)
+
+// inParallel calls work for every index below count, with at most
+// concurrency calls at the same time, and returns once all are done. After an
+// interrupt, the remaining indices are skipped.
+//
+// A call of work that fails, or exits in process, ends the others before
+// their next index, and inParallel then ends the same way in the goroutine
+// it was called in, so that the command ends along with its workers.
+func inParallel(count int, concurrency int, work func(index int)) {
+
+	indices := make(chan int)
+	failed := make(chan struct{})
+	var ending interface{}
+	var once sync.Once
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					if !isEnding(recovered) {
+						panic(recovered)
+					}
+					once.Do(func() {
+						ending = recovered
+						close(failed)
+					})
+				}
+			}()
+
+			for index := range indices {
+				select {
+				case <-failed:
+					continue
+				default:
+				}
+
+				work(index)
+			}
+		}()
+	}
+
+produce:
+	for index := 0; index < count && !interrupted(); index++ {
+		select {
+		case indices <- index:
+		case <-failed:
+			break produce
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	if ending != nil {
+		panic(ending)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"fmt"
+	"time"
+)
+
+// lastEnvChange returns when the env of the app was last changed: the
+// newest entry of its history if it is tracked, see env-history, and
+// otherwise the last update of the app, which also counts changes other
+// than to the env.
+func lastEnvChange(cliConnection plugin.CliConnection, guid string) (time.Time, error) {
+
+	client := ccClient(cliConnection)
+
+	if annotations, err := client.GetAppAnnotations(guid); err == nil {
+		if history := readHistory(annotations); len(history) > 0 {
+			return history[len(history)-1].Time, nil
+		}
+	}
+
+	app, err := client.GetApp(guid)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if app.Metadata.UpdatedAt == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, app.Metadata.UpdatedAt)
+}
+
+// pendingRestage returns when the env of the app was changed if an instance
+// has been running since before, and so doesn't see the change yet. Stopped
+// apps see the current env once they are started.
+func pendingRestage(cliConnection plugin.CliConnection, guid string) (time.Time, bool, error) {
+
+	uptimes, err := ccClient(cliConnection).InstanceUptimes(guid)
+	if err != nil || len(uptimes) == 0 {
+		return time.Time{}, false, err
+	}
+
+	changed, err := lastEnvChange(cliConnection, guid)
+	if err != nil || changed.IsZero() {
+		return time.Time{}, false, err
+	}
+
+	oldest := uptimes[0]
+	for _, uptime := range uptimes[1:] {
+		if uptime > oldest {
+			oldest = uptime
+		}
+	}
+
+	started := time.Now().Add(-oldest)
+
+	return changed, started.Before(changed), nil
+}
+
+// warnPendingRestage warns on stderr, so that the printed env can still be
+// piped, when the env changed after the instances of the app were started.
+// With failOnPending the warning is an error instead.
+func warnPendingRestage(ui UI, cliConnection plugin.CliConnection, appName string, guid string, failOnPending bool) {
+
+	changed, pending, err := pendingRestage(cliConnection, guid)
+
+	if err != nil {
+		verbosef("Failed to check whether '%s' needs a restage: %s", appName, err)
+		if failOnPending {
+			fatalIf(err)
+		}
+		return
+	}
+
+	if !pending {
+		return
+	}
+
+	message := fmt.Sprintf("The environment of '%s' changed at %s, after its instances were started. Use 'cf restage %s' so that they see the changes", appName, changed.Format(time.RFC3339), appName)
+
+	if failOnPending {
+		fatalIf(fmt.Errorf("%s", message))
+	}
+
+	fmt.Fprintln(ui.ErrOut(), paint(colorYellow, "Warning: "+message))
+}
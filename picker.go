@@ -0,0 +1,296 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const pickerRows = 10
+
+var errPickerAborted = errors.New("No app selected")
+
+// canPick reports whether an app can be picked interactively: the picker
+// reads keys from stdin and draws on stderr, so stdout may be redirected.
+func canPick() bool {
+	return isTerminal(os.Stdin) && isTerminal(os.Stderr)
+}
+
+// pickApp lets the user choose one of the apps of the targeted space, or of
+// the space given by --org and --space.
+func pickApp(cliConnection plugin.CliConnection, orgName string, spaceName string) string {
+
+	scope := resolveScope(cliConnection, orgName, spaceName)
+
+	if scope.SpaceGuid == "" {
+		space, err := targetedSpace(cliConnection)
+		fatalIf(err)
+		scope.SpaceGuid = space.Guid
+	}
+
+	apps, err := ccClient(cliConnection).ListApps(scope)
+	fatalIf(err)
+
+	if len(apps) == 0 {
+		fatalIf(errors.New("There are no apps in the space"))
+	}
+
+	names := make([]string, 0, len(apps))
+	for _, app := range apps {
+		names = append(names, app.Entity.Name)
+	}
+	sort.Strings(names)
+
+	name, err := pickFuzzy(names)
+
+	if err == errRawModeUnavailable {
+		name, err = pickNumbered(names)
+	}
+
+	fatalIf(err)
+
+	return name
+}
+
+// fuzzyScore matches query as a case-insensitive subsequence of candidate.
+// Lower scores are better: they count the characters skipped between the
+// matched ones, so contiguous matches come first.
+func fuzzyScore(query string, candidate string) (int, bool) {
+
+	query = strings.ToLower(query)
+	candidate = strings.ToLower(candidate)
+
+	score, last := 0, -1
+
+	for _, char := range query {
+		index := strings.IndexRune(candidate[last+1:], char)
+		if index < 0 {
+			return 0, false
+		}
+
+		if last >= 0 {
+			score += index
+		} else {
+			score += index * 2
+		}
+		last += index + 1
+	}
+
+	return score, true
+}
+
+// fuzzyFilter returns the names matching query, best matches first.
+func fuzzyFilter(query string, names []string) []string {
+
+	type match struct {
+		name  string
+		score int
+	}
+
+	var matches []match
+	for _, name := range names {
+		if score, ok := fuzzyScore(query, name); ok {
+			matches = append(matches, match{name, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score < matches[j].score
+	})
+
+	filtered := make([]string, len(matches))
+	for i, match := range matches {
+		filtered[i] = match.name
+	}
+
+	return filtered
+}
+
+var errRawModeUnavailable = errors.New("raw mode unavailable")
+
+// pickFuzzy narrows names down as the user types and moves the selection
+// with the arrow keys. The terminal is put into raw mode with stty, so this
+// needs a Unix-like system.
+func pickFuzzy(names []string) (string, error) {
+
+	restore, err := rawMode()
+
+	if err != nil {
+		return "", errRawModeUnavailable
+	}
+
+	defer restore()
+
+	query, selected, drawn := "", 0, 0
+
+	for {
+		matches := fuzzyFilter(query, names)
+		if selected >= len(matches) {
+			selected = len(matches) - 1
+		}
+		if selected < 0 {
+			selected = 0
+		}
+
+		drawn = drawPicker(os.Stderr, query, matches, len(names), selected, drawn)
+
+		key, err := readKey()
+
+		if err != nil {
+			return "", err
+		}
+
+		switch key {
+		case "enter":
+			clearPicker(os.Stderr, drawn)
+			if len(matches) == 0 {
+				return "", errPickerAborted
+			}
+			return matches[selected], nil
+		case "escape", "interrupt":
+			clearPicker(os.Stderr, drawn)
+			return "", errPickerAborted
+		case "up":
+			selected--
+		case "down":
+			selected++
+		case "backspace":
+			if query != "" {
+				runes := []rune(query)
+				query = string(runes[:len(runes)-1])
+			}
+		default:
+			query += key
+			selected = 0
+		}
+	}
+}
+
+// drawPicker redraws the picker over the lines drawn last time and returns
+// the number of lines it drew.
+func drawPicker(out io.Writer, query string, matches []string, total int, selected int, drawn int) int {
+
+	clearPicker(out, drawn)
+
+	first := 0
+	if selected >= pickerRows {
+		first = selected - pickerRows + 1
+	}
+
+	lines := 0
+	for i := first; i < len(matches) && i < first+pickerRows; i++ {
+		marker := "  "
+		if i == selected {
+			marker = "> "
+		}
+		fmt.Fprintf(out, "%s%s\r\n", marker, matches[i])
+		lines++
+	}
+
+	fmt.Fprintf(out, "%d/%d app: %s", len(matches), total, query)
+
+	return lines
+}
+
+// clearPicker moves the cursor up over lines and clears everything below.
+func clearPicker(out io.Writer, lines int) {
+	fmt.Fprint(out, "\r")
+	if lines > 0 {
+		fmt.Fprintf(out, "\x1b[%dA", lines)
+	}
+	fmt.Fprint(out, "\x1b[J")
+}
+
+// readKey reads a key press, returning the name of special keys or the
+// typed character.
+func readKey() (string, error) {
+
+	char, _, err := stdin.ReadRune()
+
+	if err != nil {
+		return "", err
+	}
+
+	switch char {
+	case '\r', '\n':
+		return "enter", nil
+	case 3:
+		return "interrupt", nil
+	case 127, 8:
+		return "backspace", nil
+	case 27:
+		if stdin.Buffered() == 0 {
+			return "escape", nil
+		}
+		sequence := make([]byte, 2)
+		if _, err := io.ReadFull(stdin, sequence); err != nil {
+			return "", err
+		}
+		switch string(sequence) {
+		case "[A":
+			return "up", nil
+		case "[B":
+			return "down", nil
+		}
+		return "", nil
+	}
+
+	if !unicode.IsPrint(char) {
+		return "", nil
+	}
+
+	return string(char), nil
+}
+
+// rawMode switches the terminal to unbuffered input without echo and
+// returns a function restoring the previous settings. Ctrl-C is read as a
+// key, so that the settings are restored before exiting.
+func rawMode() (func(), error) {
+
+	state, err := stty("-g")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := stty("-icanon", "-echo", "-isig", "min", "1"); err != nil {
+		return nil, err
+	}
+
+	return func() { stty(strings.TrimSpace(state)) }, nil
+}
+
+func stty(args ...string) (string, error) {
+
+	command := exec.Command("stty", args...)
+	command.Stdin = os.Stdin
+
+	output, err := command.Output()
+
+	return string(output), err
+}
+
+// pickNumbered lists names with numbers and reads the choice from stdin.
+func pickNumbered(names []string) (string, error) {
+
+	for i, name := range names {
+		fmt.Fprintf(os.Stderr, "%d. %s\n", i+1, name)
+	}
+	fmt.Fprint(os.Stderr, "App number: ")
+
+	answer, _ := stdin.ReadString('\n')
+	choice, err := strconv.Atoi(strings.TrimSpace(answer))
+
+	if err != nil || choice < 1 || choice > len(names) {
+		return "", errPickerAborted
+	}
+
+	return names[choice-1], nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"strconv"
+	"strings"
+)
+
+// findProcess returns the process of the given type, or nil if the app
+// doesn't run one.
+func findProcess(processes []ccclient.ProcessModel, processType string) *ccclient.ProcessModel {
+	for i := range processes {
+		if processes[i].Type == processType {
+			return &processes[i]
+		}
+	}
+	return nil
+}
+
+// formatProcesses formats the instances of every process, e.g. web:2, worker:1.
+func formatProcesses(processes []ccclient.ProcessModel) string {
+
+	formatted := make([]string, len(processes))
+	for i, process := range processes {
+		formatted[i] = process.Type + ":" + strconv.Itoa(process.Instances)
+	}
+
+	return strings.Join(formatted, ", ")
+}
+
+// formatHealthChecks formats the health check of every process, with the
+// endpoint of http checks, e.g. web:http /health, worker:process.
+func formatHealthChecks(processes []ccclient.ProcessModel) string {
+
+	formatted := make([]string, len(processes))
+	for i, process := range processes {
+		formatted[i] = process.Type + ":" + process.HealthCheck.Type
+		if process.HealthCheck.Data.Endpoint != "" {
+			formatted[i] += " " + process.HealthCheck.Data.Endpoint
+		}
+	}
+
+	return strings.Join(formatted, ", ")
+}
+
+// appsWithProcess keeps the apps running a process of the given type, e.g.
+// only the apps with a worker when checking the env of workers. It needs a
+// request per app, made by concurrency workers.
+func appsWithProcess(cliConnection plugin.CliConnection, apps []spaceApp, processType string, concurrency int) []spaceApp {
+
+	if processType == "" {
+		return apps
+	}
+
+	keep := make([]bool, len(apps))
+
+	inParallel(len(apps), concurrency, func(index int) {
+		processes, err := apps[index].ccClient(cliConnection).AppProcesses(apps[index].App.Metadata.Guid)
+		fatalIf(err)
+
+		keep[index] = findProcess(processes, processType) != nil
+	})
+
+	var filtered []spaceApp
+	for i, app := range apps {
+		if keep[i] {
+			filtered = append(filtered, app)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// progress reports how many of total items have been processed on stderr,
+// so that it doesn't mix with the results. It stays silent when stderr is
+// not a terminal or with --quiet.
+type progress struct {
+	mutex   sync.Mutex
+	current int
+	total   int
+	enabled bool
+}
+
+func newProgress(total int) *progress {
+	return &progress{total: total, enabled: isTerminal(os.Stderr) && !globals.quiet}
+}
+
+func (p *progress) increment() {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.current++
+
+	if p.enabled {
+		fmt.Fprintf(os.Stderr, "\rScanned %d/%d apps", p.current, p.total)
+	}
+}
+
+func (p *progress) done() {
+	if p.enabled && p.total > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// formatPrometheus prints the readings of env-audit and list-apps --summary
+// as gauges in the text format of Prometheus, for cron jobs pushing them to
+// a Pushgateway and alerts on drift.
+const formatPrometheus = "prometheus"
+
+// measured results have readings, printed by prometheusFormatter.
+type measured interface {
+	gauges() []*gauge
+}
+
+// prometheusFormatter prints the gauges of measured results.
+type prometheusFormatter struct{}
+
+func (prometheusFormatter) Format(out io.Writer, result interface{}) error {
+
+	readings, ok := result.(measured)
+	if !ok {
+		return fmt.Errorf("A %T can't be printed as %s", result, formatPrometheus)
+	}
+
+	writePrometheus(out, readings.gauges())
+	return nil
+}
+
+// gauge is a metric with one sample per set of labels.
+type gauge struct {
+	name    string
+	help    string
+	samples []sample
+}
+
+// sample is a reading of a gauge. labels are name, value pairs, printed in
+// that order.
+type sample struct {
+	labels []string
+	value  float64
+}
+
+func (g *gauge) add(value float64, labels ...string) {
+	g.samples = append(g.samples, sample{labels: labels, value: value})
+}
+
+func writePrometheus(out io.Writer, gauges []*gauge) {
+
+	for _, g := range gauges {
+		fmt.Fprintf(out, "# HELP %s %s\n", g.name, g.help)
+		fmt.Fprintf(out, "# TYPE %s gauge\n", g.name)
+
+		for _, s := range g.samples {
+			fmt.Fprintf(out, "%s%s %s\n", g.name, formatLabels(s.labels), strconv.FormatFloat(s.value, 'f', -1, 64))
+		}
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(labels []string) string {
+
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1])))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
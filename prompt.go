@@ -0,0 +1,22 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// confirm asks a yes/no question through ui and reads the answer from stdin,
+// defaulting to no.
+func confirm(ui UI, question string) bool {
+
+	fmt.Fprintf(ui.Out(), "%s [yN]: ", question)
+
+	answer, _ := stdin.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// readList reads one item per line of path, or of stdin if path is "-", so
+// that app names and keys can be piped from other tools. Blank lines and
+// lines starting with # are skipped.
+func readList(path string) ([]string, error) {
+
+	var in io.Reader = stdin
+
+	if path != "-" {
+		file, err := os.Open(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer file.Close()
+		in = file
+	}
+
+	var items []string
+	scanner := bufio.NewScanner(in)
+
+	for scanner.Scan() {
+		item := strings.TrimSpace(scanner.Text())
+
+		if item != "" && !strings.HasPrefix(item, "#") {
+			items = append(items, item)
+		}
+	}
+
+	return items, scanner.Err()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"regexp"
+)
+
+var secretKey = regexp.MustCompile(`(?i)(PASSWORD|PASSWD|TOKEN|KEY|SECRET)`)
+
+// redactEnv returns a copy of env where values of secret-looking keys and
+// everything inside "credentials" objects (as found in VCAP_SERVICES) are masked.
+func redactEnv(env map[string]interface{}) map[string]interface{} {
+	return redactValue(env, false).(map[string]interface{})
+}
+
+func redactValue(value interface{}, secret bool) interface{} {
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(typed))
+		for key, nested := range typed {
+			redacted[key] = redactValue(nested, secret || key == "credentials" || secretKey.MatchString(key))
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(typed))
+		for i, nested := range typed {
+			redacted[i] = redactValue(nested, secret)
+		}
+		return redacted
+	case string:
+		if secret {
+			return mask(typed)
+		}
+		return typed
+	default:
+		if secret && value != nil {
+			return mask(formatEnvValue(value))
+		}
+		return value
+	}
+}
+
+// mask keeps the first and last two characters of value, which is usually
+// enough to tell two secrets apart. Short values are masked entirely.
+func mask(value string) string {
+
+	runes := []rune(value)
+
+	if len(runes) < 8 {
+		return "****"
+	}
+
+	return string(runes[:2]) + "****" + string(runes[len(runes)-2:])
+}
+
+// shouldRedact masks secrets when asked to, and by default when they would
+// end up on the terminal screen of ui. showSecrets wins, so that it overrides
+// `redact: true` in the config file.
+func shouldRedact(ui UI, redact bool, showSecrets bool, toFile bool) bool {
+
+	if showSecrets {
+		return false
+	}
+
+	if redact {
+		return true
+	}
+
+	return !toFile && outputIsTerminal(ui)
+}
+
+func isTerminal(file *os.File) bool {
+
+	info, err := file.Stat()
+
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"text/template"
+)
+
+// renderEnvCommand executes a text/template with the user-provided variables
+// of an app as data, e.g. `{{.DB_HOST}}`. Referencing a variable the app
+// doesn't define is an error rather than an empty string; optional ones are
+// read with `{{default "5432" (index . "DB_PORT")}}`.
+type renderEnvCommand struct {
+	out string
+}
+
+func (c *renderEnvCommand) Name() string {
+	return "render-env"
+}
+
+func (c *renderEnvCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "render-env",
+		HelpText: "Render a Go text/template with the user-provided environment variables of an app, e.g. {{.DB_HOST}}.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf render-env APP_NAME TEMPLATE [--out FILE]",
+			Options: map[string]string{
+				"out": "Write the result to FILE instead of stdout",
+			},
+		},
+	}
+}
+
+func (c *renderEnvCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("render-env")
+	flags.StringVar(&c.out, "out", "", "")
+
+	return flags
+}
+
+func (c *renderEnvCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 2 {
+		failUsage("App name and template must be provided")
+	}
+
+	appName, path := args[0], args[1]
+
+	source, err := ioutil.ReadFile(path)
+	fatalIf(err)
+
+	env := fetchEnv(cliConnection, appName)
+
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Funcs(envTemplateFuncs(env)).Parse(string(source))
+	fatalIf(err)
+
+	// Render completely before writing, so that a failing template doesn't
+	// leave a truncated file behind.
+	var rendered bytes.Buffer
+	fatalIf(tmpl.Execute(&rendered, userProvidedEnv(env)))
+
+	if c.out == "" {
+		ui.Out().Write(rendered.Bytes())
+		return
+	}
+
+	fatalIf(ioutil.WriteFile(c.out, rendered.Bytes(), 0600))
+}
+
+// envTemplateFuncs gives templates access to the other sections of env.
+func envTemplateFuncs(env map[string]interface{}) template.FuncMap {
+	return template.FuncMap{
+		"section": func(name string) (map[string]interface{}, error) {
+			section, ok := findEnvSection(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown section '%s'", name)
+			}
+			return sectionVars(env, section.key), nil
+		},
+		"json": func(value interface{}) (string, error) {
+			encoded, err := json.Marshal(value)
+			return string(encoded), err
+		},
+		"default": func(fallback interface{}, value interface{}) interface{} {
+			if value == nil || value == "" {
+				return fallback
+			}
+			return value
+		},
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// restartOptions are the flags of commands that change env variables, which
+// only reach the app after a restart or restage.
+type restartOptions struct {
+	restart bool
+	restage bool
+}
+
+func addRestartFlags(flags *flag.FlagSet) *restartOptions {
+
+	options := &restartOptions{}
+	flags.BoolVar(&options.restart, "restart", false, "")
+	flags.BoolVar(&options.restage, "restage", false, "")
+
+	return options
+}
+
+// apply restarts or restages the app as requested, and otherwise offers to
+// restage it when running interactively.
+func (options *restartOptions) apply(ui UI, cliConnection plugin.CliConnection, appName string, guid string) {
+
+	command := ""
+
+	switch {
+	case options.restage:
+		command = "restage"
+	case options.restart:
+		command = "restart"
+	case isTerminal(os.Stdin) && confirm(ui, fmt.Sprintf("Restage '%s' now to apply the changes?", appName)):
+		command = "restage"
+	default:
+		fmt.Fprintf(ui.Out(), "Use 'cf restage %s' to apply the changes\n", appName)
+		return
+	}
+
+	_, err := cliConnection.CliCommand(command, appName)
+	fatalIf(err)
+
+	fatalIf(waitUntilHealthy(ui, cliConnection, appName, guid, 2*time.Second, 5*time.Minute))
+}
+
+// waitUntilHealthy polls the instances of the app until all of them are
+// running, failing as soon as one crashes or when timeout is exceeded. Apps
+// scaled to 0 instances are healthy right away.
+func waitUntilHealthy(ui UI, cliConnection plugin.CliConnection, appName string, guid string, interval time.Duration, timeout time.Duration) error {
+
+	client := ccClient(cliConnection)
+	deadline := time.Now().Add(timeout)
+	desired := -1
+
+	for {
+		states, err := client.InstanceStates(guid)
+
+		if err != nil {
+			return err
+		}
+
+		running := 0
+		for _, state := range states {
+			switch state {
+			case "RUNNING":
+				running++
+			case "CRASHED":
+				return fmt.Errorf("An instance of '%s' crashed", appName)
+			}
+		}
+
+		if len(states) > 0 && running == len(states) {
+			fmt.Fprintf(ui.Out(), "All %d instances of '%s' are running\n", running, appName)
+			return nil
+		}
+
+		// No instances yet can also mean that none are wanted, which only
+		// the app tells.
+		if len(states) == 0 && desired < 0 {
+			app, err := cliConnection.GetApp(appName)
+			if err != nil {
+				return err
+			}
+			desired = app.InstanceCount
+		}
+
+		if len(states) == 0 && desired == 0 {
+			fmt.Fprintf(ui.Out(), "'%s' has no instances to wait for\n", appName)
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Timed out waiting for '%s' to become healthy", appName)
+		}
+
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"os"
+	"os/exec"
+	"os/signal"
+)
+
+// runWithEnvCommand runs a local command with the user-provided variables of
+// an app added to its environment, so that production settings can be
+// reproduced locally. The variables of the app win over local ones.
+type runWithEnvCommand struct {
+	withServices bool
+	command      []string
+}
+
+func (c *runWithEnvCommand) Name() string {
+	return "run-with-env"
+}
+
+func (c *runWithEnvCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "run-with-env",
+		HelpText: "Run a local command with the user-provided environment variables of an app.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf run-with-env APP_NAME [--with-services] -- COMMAND [ARGS...]",
+			Options: map[string]string{
+				"with-services": "Also set VCAP_SERVICES with the credentials of the bound services",
+			},
+		},
+	}
+}
+
+func (c *runWithEnvCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("run-with-env")
+	flags.BoolVar(&c.withServices, "with-services", false, "")
+
+	return flags
+}
+
+func (c *runWithEnvCommand) setCommandLine(command []string) {
+	c.command = command
+}
+
+func (c *runWithEnvCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	command := c.command
+
+	if len(args) != 1 || len(command) == 0 {
+		failUsage("App name and a command after -- must be provided")
+	}
+
+	env := fetchEnv(cliConnection, args[0])
+
+	vars := userProvidedEnv(env)
+	if c.withServices {
+		for key, value := range sectionVars(env, "system_env_json") {
+			vars[key] = value
+		}
+	}
+
+	child := exec.Command(command[0], command[1:]...)
+	child.Env = os.Environ()
+	for _, key := range sortedKeys(vars) {
+		child.Env = append(child.Env, key+"="+formatEnvValue(vars[key]))
+	}
+	child.Stdin = os.Stdin
+	child.Stdout = ui.Out()
+	child.Stderr = ui.ErrOut()
+
+	// Interrupts reach the child through the terminal; the plugin waits
+	// for it to exit instead of dying first. They are caught rather than
+	// ignored, since the child would inherit ignoring them, and handed back
+	// once it has exited.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+
+	err := child.Run()
+
+	signal.Stop(interrupts)
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		exit(exitErr.ExitCode())
+	}
+
+	fatalIf(err)
+}
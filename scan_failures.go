@@ -0,0 +1,133 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// scanFailures collects the apps a scan of many apps failed for, e.g. apps
+// deleted while the scan runs or in spaces the user can't read. With
+// --continue-on-error, the default, the scan goes on with the other apps and
+// lists the failures after the results. --continue-on-error=false stops at
+// the first failure.
+type scanFailures struct {
+	continueOnError bool
+	lock            sync.Mutex
+	failed          []scanFailure
+}
+
+type scanFailure struct {
+	app spaceApp
+	err error
+}
+
+// scanFailureReport is printed for every failure with `--error-format json`.
+type scanFailureReport struct {
+	App        string `json:"app"`
+	Org        string `json:"org,omitempty"`
+	Space      string `json:"space,omitempty"`
+	Foundation string `json:"foundation,omitempty"`
+	errorReport
+}
+
+func addScanFailureFlags(flags *flag.FlagSet) *scanFailures {
+
+	failures := &scanFailures{}
+	flags.BoolVar(&failures.continueOnError, "continue-on-error", true, "")
+
+	return failures
+}
+
+// fetchEnv fetches the user-provided env of app, and reports whether it
+// could. Failures are recorded, or end the scan without --continue-on-error.
+func (f *scanFailures) fetchEnv(cliConnection plugin.CliConnection, app spaceApp) (map[string]interface{}, bool) {
+
+	env, err := app.fetchEnv(cliConnection)
+
+	if err == nil {
+		return userProvidedEnv(env), true
+	}
+
+	// The apps left when interrupted aren't failures.
+	if interrupted() {
+		return nil, false
+	}
+
+	if !f.continueOnError {
+		fail(fmt.Sprintf("Failed to retrieve enviroment for '%s'. %s", app.App.Entity.Name, err), err)
+	}
+
+	verbosef("Skipping app %s: %s", app.App.Entity.Name, err)
+
+	f.lock.Lock()
+	f.failed = append(f.failed, scanFailure{app: app, err: err})
+	f.lock.Unlock()
+
+	return nil, false
+}
+
+// report lists the failures after the results of a scan of total apps, on
+// stderr so that the results stay parseable, and exits. The exit code is
+// exitPartial if some apps were scanned, otherwise that of the failure, and
+// exitInterrupted if the scan didn't finish.
+func (f *scanFailures) report(ui UI, total int) {
+
+	// A scan ending with a failure reports that instead.
+	if recovered := recover(); recovered != nil {
+		panic(recovered)
+	}
+
+	if len(f.failed) > 0 {
+		f.list(ui, total)
+	}
+
+	exitIfInterrupted(ui)
+
+	if len(f.failed) == 0 {
+		return
+	}
+
+	if len(f.failed) < total {
+		exit(exitPartial)
+	}
+
+	exit(exitCode(f.failed[0].err))
+}
+
+func (f *scanFailures) list(ui UI, total int) {
+
+	sort.Slice(f.failed, func(i, j int) bool { return describeScanned(f.failed[i].app) < describeScanned(f.failed[j].app) })
+
+	if globals.errorFormat == "json" {
+		for _, failure := range f.failed {
+			app := failure.app
+			encoded, _ := json.Marshal(scanFailureReport{App: app.App.Entity.Name, Org: app.Org, Space: app.Space, Foundation: app.Foundation, errorReport: newErrorReport(failure.err)})
+			fmt.Fprintln(ui.ErrOut(), string(encoded))
+		}
+	} else {
+		fmt.Fprintf(ui.ErrOut(), "\nErrors (%d of %d apps):\n", len(f.failed), total)
+		for _, failure := range f.failed {
+			fmt.Fprintf(ui.ErrOut(), "  %s: %s\n", describeScanned(failure.app), failure.err)
+		}
+	}
+}
+
+// describeScanned names app with its foundation, org and space, as far as
+// the scan knows them.
+func describeScanned(app spaceApp) string {
+
+	var parts []string
+
+	for _, part := range []string{app.Foundation, app.Org, app.Space, app.App.Entity.Name} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
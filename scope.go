@@ -0,0 +1,77 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"code.cloudfoundry.org/cli/plugin/models"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+)
+
+// resolveScope looks up the GUIDs of the given org and space names, which
+// narrow app lookups to an org or space other than the targeted one. A space
+// without an org is looked up in the targeted org. --org-guid and
+// --space-guid are taken as they are.
+func resolveScope(cliConnection plugin.CliConnection, orgName string, spaceName string) ccclient.AppFilters {
+
+	var scope ccclient.AppFilters
+
+	if orgName != "" && globals.orgGuid != "" {
+		failUsage("Only one of --org and --org-guid can be given")
+	}
+
+	if spaceName != "" && globals.spaceGuid != "" {
+		failUsage("Only one of --space and --space-guid can be given")
+	}
+
+	if orgName == "" && spaceName == "" && globals.orgGuid == "" && globals.spaceGuid == "" {
+		return scope
+	}
+
+	client := ccClient(cliConnection)
+
+	switch {
+	case globals.orgGuid != "":
+		scope.OrgGuid = globals.orgGuid
+	case orgName != "":
+		guid, err := client.FindGuid("Organization", orgName, "v2/organizations")
+		fatalIf(err)
+		scope.OrgGuid = guid
+	case globals.spaceGuid == "":
+		org, err := cliConnection.GetCurrentOrg()
+		fatalIf(err)
+		scope.OrgGuid = org.Guid
+	}
+
+	switch {
+	case globals.spaceGuid != "":
+		scope.SpaceGuid = globals.spaceGuid
+	case spaceName != "":
+		guid, err := client.FindGuid("Space", spaceName, fmt.Sprintf("v2/organizations/%s/spaces", scope.OrgGuid))
+		fatalIf(err)
+		scope.SpaceGuid = guid
+	}
+
+	return scope
+}
+
+// targetedOrg returns the targeted org, or the org of --org-guid. Its name
+// isn't looked up, the GUID stands in for it.
+func targetedOrg(cliConnection plugin.CliConnection) (plugin_models.Organization, error) {
+
+	if globals.orgGuid != "" {
+		return plugin_models.Organization{OrganizationFields: plugin_models.OrganizationFields{Guid: globals.orgGuid, Name: globals.orgGuid}}, nil
+	}
+
+	return cliConnection.GetCurrentOrg()
+}
+
+// targetedSpace returns the targeted space, or the space of --space-guid,
+// like targetedOrg.
+func targetedSpace(cliConnection plugin.CliConnection) (plugin_models.Space, error) {
+
+	if globals.spaceGuid != "" {
+		return plugin_models.Space{SpaceFields: plugin_models.SpaceFields{Guid: globals.spaceGuid, Name: globals.spaceGuid}}, nil
+	}
+
+	return cliConnection.GetCurrentSpace()
+}
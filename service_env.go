@@ -0,0 +1,128 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/gdey/jsonpath"
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"strings"
+)
+
+// fieldSchema is the schema of the JSON output of get-service-env, the field
+// of the binding, which can be anything but a string.
+var fieldSchema = formatters.Schema{Name: "field", Version: 1}
+
+type serviceEnvCommand struct {
+	field       string
+	redact      bool
+	showSecrets bool
+}
+
+func (c *serviceEnvCommand) Name() string {
+	return "get-service-env"
+}
+
+func (c *serviceEnvCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "get-service-env",
+		HelpText: "Get the credentials of a service instance bound to an app.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf get-service-env APP_NAME SERVICE_INSTANCE [--field JSON_PATH] [--redact | --show-secrets]",
+			Options: map[string]string{
+				"field":        "Select a field of the binding, e.g. credentials.uri (default credentials)",
+				"redact":       "Mask secret values. Default when printing to a terminal",
+				"show-secrets": "Print secret values on a terminal",
+			},
+		},
+	}
+}
+
+func (c *serviceEnvCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("get-service-env")
+	flags.StringVar(&c.field, "field", "credentials", "")
+	flags.BoolVar(&c.redact, "redact", false, "")
+	flags.BoolVar(&c.showSecrets, "show-secrets", false, "")
+
+	return flags
+}
+
+func (c *serviceEnvCommand) schema(args []string) formatters.Schema {
+	return fieldSchema
+}
+
+func (c *serviceEnvCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 2 {
+		failUsage("App name and service instance name must be provided")
+	}
+
+	appName, instanceName := args[0], args[1]
+
+	env := fetchEnv(cliConnection, appName)
+
+	if shouldRedact(ui, c.redact, c.showSecrets, false) {
+		env = redactEnv(env)
+	}
+
+	binding, found := findServiceBinding(env, instanceName)
+
+	if !found {
+		ui.Say("Service instance '%s' is not bound to '%s'", instanceName, appName)
+		exit(1)
+	}
+
+	applicator, err := jsonpath.Parse(fieldPath(c.field))
+
+	if err != nil {
+		failUsage("Failed to parse field '%s' as valid JSON-path: %s", c.field, err)
+	}
+
+	selected, err := applicator.Apply(binding)
+
+	if err != nil {
+		ui.Say("Failed to apply JSON path: %s", err)
+		exit(1)
+	}
+
+	if str, ok := selected.(string); ok {
+		fmt.Fprint(ui.Out(), str)
+		return
+	}
+
+	writeJson(ui.Out(), fieldSchema.Of(selected))
+}
+
+// findServiceBinding looks up the named instance in VCAP_SERVICES, which
+// groups bindings by service label: {"p-mysql": [{"name": ..., "credentials": ...}]}.
+func findServiceBinding(env map[string]interface{}, instanceName string) (map[string]interface{}, bool) {
+
+	systemEnv, _ := env["system_env_json"].(map[string]interface{})
+	services, _ := systemEnv["VCAP_SERVICES"].(map[string]interface{})
+
+	for _, bindings := range services {
+		list, _ := bindings.([]interface{})
+
+		for _, binding := range list {
+			typed, ok := binding.(map[string]interface{})
+
+			if ok && typed["name"] == instanceName {
+				return typed, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// fieldPath accepts both `credentials.uri` and a full `$.credentials.uri`.
+func fieldPath(field string) string {
+
+	if strings.HasPrefix(field, "$") {
+		return field
+	}
+
+	return "$." + field
+}
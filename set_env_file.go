@@ -0,0 +1,139 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+)
+
+type setEnvFileCommand struct {
+	prune        bool
+	force        bool
+	encodeBase64 stringsValue
+	restart      *restartOptions
+	history      *historyOptions
+}
+
+func (c *setEnvFileCommand) Name() string {
+	return "set-env-file"
+}
+
+func (c *setEnvFileCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "set-env-file",
+		HelpText: "Set the environment variables of an app from a .env, JSON or YAML file in a single update.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf set-env-file APP_NAME FILE [--prune] [--encode-base64 KEY[=PATH]...] [-f] [--dry-run] [--restart | --restage] [--track-history]",
+			Options: map[string]string{
+				"restart":       "Restart the app after the update",
+				"restage":       "Restage the app after the update",
+				"prune":         "Remove variables that are not in FILE",
+				"encode-base64": "Base64-encode the value of KEY in FILE, or set KEY to the encoded content of the file PATH, e.g. a keystore. Can be given several times",
+				"f":             "Update the app even if its env exceeds the size the Cloud Controller accepts by default. --force is an alias",
+				"track-history": "Record the names of the changed variables in the history of the app, see env-history",
+			},
+		},
+	}
+}
+
+func (c *setEnvFileCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("set-env-file")
+	flags.BoolVar(&c.prune, "prune", false, "")
+	flags.BoolVar(&c.force, "force", false, "")
+	flags.BoolVar(&c.force, "f", false, "")
+	flags.Var(&c.encodeBase64, "encode-base64", "")
+	c.restart = addRestartFlags(flags)
+	c.history = addHistoryFlags(flags)
+
+	return flags
+}
+
+func (c *setEnvFileCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 2 {
+		failUsage("App name and file must be provided")
+	}
+
+	appName, path := args[0], args[1]
+
+	fileEnv, err := readEnvFile(path)
+	fatalIf(err)
+	fatalIf(encodeBase64Vars(fileEnv, c.encodeBase64))
+
+	app := resolveApp(cliConnection, appName)
+	current := userProvidedEnv(fetchEnvByGuid(cliConnection, appName, app.Guid))
+
+	updated, changes := mergeEnv(current, fileEnv, c.prune)
+
+	change := envChangeSet{appName: appName, guid: app.Guid, before: current, after: updated, force: c.force}
+
+	if globals.dryRun {
+		change.writePreview(ui.Out())
+	}
+
+	applied := changes.any() && change.apply(ui, cliConnection)
+
+	if applied {
+		c.history.record(ui, cliConnection, appName, app.Guid, "set-env-file", current, updated)
+	}
+
+	changes.print(ui.Out(), c.prune)
+
+	if applied {
+		c.restart.apply(ui, cliConnection, appName, app.Guid)
+	}
+}
+
+// envChanges counts the variables affected by mergeEnv.
+type envChanges struct {
+	added, changed, unchanged, removed int
+}
+
+func (c envChanges) any() bool {
+	return c.added+c.changed+c.removed > 0
+}
+
+func (c envChanges) print(out io.Writer, prune bool) {
+	fmt.Fprintf(out, "%d added, %d changed, %d unchanged", c.added, c.changed, c.unchanged)
+	if prune {
+		fmt.Fprintf(out, ", %d removed", c.removed)
+	}
+	fmt.Fprintln(out)
+}
+
+// mergeEnv returns current updated with vars. With prune, variables missing
+// from vars are removed.
+func mergeEnv(current map[string]interface{}, vars map[string]string, prune bool) (map[string]interface{}, envChanges) {
+
+	var changes envChanges
+	updated := map[string]interface{}{}
+
+	for key, value := range current {
+		if _, inVars := vars[key]; inVars || !prune {
+			updated[key] = value
+		} else {
+			changes.removed++
+		}
+	}
+
+	for key, value := range vars {
+		existing, exists := current[key]
+
+		switch {
+		case !exists:
+			changes.added++
+		case formatEnvValue(existing) != value:
+			changes.changed++
+		default:
+			changes.unchanged++
+			continue
+		}
+
+		updated[key] = value
+	}
+
+	return updated, changes
+}
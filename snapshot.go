@@ -0,0 +1,239 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// EnvSnapshot is the file format of `env-snapshot`.
+type EnvSnapshot struct {
+	AppName     string                 `json:"app_name"`
+	AppGuid     string                 `json:"app_guid"`
+	Org         string                 `json:"org"`
+	Space       string                 `json:"space"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Environment map[string]interface{} `json:"environment"`
+}
+
+type envSnapshotCommand struct {
+	save         string
+	ageRecipient string
+	pgpKey       string
+}
+
+func (c *envSnapshotCommand) Name() string {
+	return "env-snapshot"
+}
+
+func (c *envSnapshotCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "env-snapshot",
+		HelpText: "Save the user-provided environment of an app, with the app guid, space and time, to a file.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf env-snapshot APP_NAME [--save FILE] [--encrypt AGE_RECIPIENT | --encrypt-pgp KEY_ID]",
+			Options: map[string]string{
+				"save":        "Write the snapshot to FILE instead of stdout",
+				"encrypt":     "Encrypt the snapshot to an age recipient, e.g. age1..., with the age CLI",
+				"encrypt-pgp": "Encrypt the snapshot to a PGP key with gpg",
+			},
+		},
+	}
+}
+
+func (c *envSnapshotCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("env-snapshot")
+	flags.StringVar(&c.save, "save", "", "")
+	flags.StringVar(&c.ageRecipient, "encrypt", "", "")
+	flags.StringVar(&c.pgpKey, "encrypt-pgp", "", "")
+
+	return flags
+}
+
+func (c *envSnapshotCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 1 {
+		failUsage("App name must be provided")
+	}
+
+	if c.ageRecipient != "" && c.pgpKey != "" {
+		failUsage("--encrypt and --encrypt-pgp can't be combined")
+	}
+
+	appName := args[0]
+	app := resolveApp(cliConnection, appName)
+
+	org, err := cliConnection.GetCurrentOrg()
+	fatalIf(err)
+
+	space, err := cliConnection.GetCurrentSpace()
+	fatalIf(err)
+
+	snapshot := EnvSnapshot{
+		AppName:     appName,
+		AppGuid:     app.Guid,
+		Org:         org.Name,
+		Space:       space.Name,
+		Timestamp:   time.Now().UTC(),
+		Environment: userProvidedEnv(fetchEnvByGuid(cliConnection, appName, app.Guid)),
+	}
+
+	encoded, err := json.MarshalIndent(snapshot, "", "  ")
+	fatalIf(err)
+	encoded = append(encoded, '\n')
+
+	encryption := ""
+
+	switch {
+	case c.ageRecipient != "":
+		encryption = "age"
+	case c.pgpKey != "":
+		encryption = "PGP"
+	}
+
+	if encryption != "" {
+		encoded, err = encryptSnapshot(encoded, c.ageRecipient, c.pgpKey)
+		fatalIf(err)
+	}
+
+	if c.save == "" {
+		ui.Out().Write(encoded)
+		return
+	}
+
+	err = ioutil.WriteFile(c.save, encoded, 0600)
+	fatalIf(err)
+
+	if encryption != "" {
+		ui.Say("Saved %d variables of '%s' to %s, encrypted with %s", len(snapshot.Environment), appName, c.save, encryption)
+		return
+	}
+
+	ui.Say("Saved %d variables of '%s' to %s", len(snapshot.Environment), appName, c.save)
+}
+
+type envRestoreCommand struct {
+	diff     bool
+	force    bool
+	identity string
+	history  *historyOptions
+}
+
+func (c *envRestoreCommand) Name() string {
+	return "env-restore"
+}
+
+func (c *envRestoreCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "env-restore",
+		HelpText: "Replace the user-provided environment of an app with a snapshot.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf env-restore APP_NAME FILE [--diff] [-f] [--dry-run] [--identity FILE] [--track-history]",
+			Options: map[string]string{
+				"diff":          "Show the changes and ask for confirmation before restoring",
+				"f":             "Restore without asking for confirmation, even if the env exceeds the size the Cloud Controller accepts by default (alias --force)",
+				"identity":      "age identity file to decrypt a snapshot saved with --encrypt (default AGE_IDENTITY). Snapshots encrypted with PGP are decrypted by gpg",
+				"track-history": "Record the names of the changed variables in the history of the app, see env-history",
+			},
+		},
+	}
+}
+
+func (c *envRestoreCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("env-restore")
+	flags.BoolVar(&c.diff, "diff", false, "")
+	flags.BoolVar(&c.force, "force", false, "")
+	flags.BoolVar(&c.force, "f", false, "")
+	flags.StringVar(&c.identity, "identity", os.Getenv("AGE_IDENTITY"), "")
+	c.history = addHistoryFlags(flags)
+
+	return flags
+}
+
+func (c *envRestoreCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) != 2 {
+		failUsage("App name and snapshot file must be provided")
+	}
+
+	appName, path := args[0], args[1]
+
+	snapshot, err := readSnapshot(path, c.identity)
+	fatalIf(err)
+
+	app := resolveApp(cliConnection, appName)
+
+	if snapshot.AppGuid != "" && snapshot.AppGuid != app.Guid {
+		ui.Say("Warning: snapshot was taken of '%s' (%s), not of this app", snapshot.AppName, snapshot.AppGuid)
+	}
+
+	var current map[string]interface{}
+
+	if c.diff || c.history.track || globals.dryRun {
+		current = userProvidedEnv(fetchEnvByGuid(cliConnection, appName, app.Guid))
+	}
+
+	if c.diff {
+		if diffEnv(current, snapshot.Environment).empty() {
+			ui.Say("Environment of '%s' already matches the snapshot", appName)
+			return
+		}
+
+		writeEnvDiff(ui.Out(), "current", "snapshot", current, snapshot.Environment, false)
+
+		if !c.force && !globals.dryRun && !confirm(ui, "Restore the snapshot?") {
+			ui.Say("Nothing restored")
+			return
+		}
+	}
+
+	change := envChangeSet{appName: appName, guid: app.Guid, before: current, after: snapshot.Environment, force: c.force}
+
+	if globals.dryRun && !c.diff {
+		change.writePreview(ui.Out())
+	}
+
+	if !change.apply(ui, cliConnection) {
+		return
+	}
+
+	c.history.record(ui, cliConnection, appName, app.Guid, "env-restore", current, snapshot.Environment)
+
+	ui.Say("Restored %d variables of '%s' from the snapshot taken %s", len(snapshot.Environment), appName, snapshot.Timestamp.Format(time.RFC3339))
+}
+
+// readSnapshot reads the snapshot at path, decrypting it if it was saved
+// with --encrypt or --encrypt-pgp.
+func readSnapshot(path string, ageIdentity string) (EnvSnapshot, error) {
+
+	var snapshot EnvSnapshot
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return snapshot, err
+	}
+
+	data, err = decryptSnapshot(data, ageIdentity)
+
+	if err != nil {
+		return snapshot, err
+	}
+
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("Failed to parse snapshot '%s': %s", path, err)
+	}
+
+	if snapshot.Environment == nil {
+		snapshot.Environment = map[string]interface{}{}
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Snapshots are encrypted and decrypted by piping them through the age and
+// gpg CLIs, so that the plaintext never touches the disk and the keys stay
+// where those tools already manage them.
+
+const (
+	ageArmorHeader = "-----BEGIN AGE ENCRYPTED FILE-----"
+	ageBinaryMagic = "age-encryption.org/"
+	pgpArmorHeader = "-----BEGIN PGP MESSAGE-----"
+)
+
+// encryptSnapshot encrypts data to the age recipient or the PGP key,
+// whichever is given, as ASCII armor.
+func encryptSnapshot(data []byte, ageRecipient string, pgpKey string) ([]byte, error) {
+
+	if ageRecipient != "" {
+		return pipeThrough(data, "age", "--encrypt", "--armor", "--recipient", ageRecipient)
+	}
+
+	return pipeThrough(data, "gpg", "--batch", "--quiet", "--yes", "--trust-model", "always", "--encrypt", "--armor", "--recipient", pgpKey)
+}
+
+// decryptSnapshot returns data unchanged unless it is encrypted with age or
+// PGP. age needs the identity file of the recipient, gpg finds the private
+// key in its keyring.
+func decryptSnapshot(data []byte, ageIdentity string) ([]byte, error) {
+
+	trimmed := strings.TrimSpace(string(data))
+
+	switch {
+	case strings.HasPrefix(trimmed, ageArmorHeader) || strings.HasPrefix(trimmed, ageBinaryMagic):
+		if ageIdentity == "" {
+			return nil, usageErrorf("The snapshot is encrypted with age, the identity must be given with --identity or AGE_IDENTITY")
+		}
+		return pipeThrough(data, "age", "--decrypt", "--identity", ageIdentity)
+	case strings.HasPrefix(trimmed, pgpArmorHeader):
+		return pipeThrough(data, "gpg", "--batch", "--quiet", "--decrypt")
+	}
+
+	return data, nil
+}
+
+func pipeThrough(input []byte, name string, args ...string) ([]byte, error) {
+
+	var stdout, stderr bytes.Buffer
+
+	command := exec.Command(name, args...)
+	command.Stdin = bytes.NewReader(input)
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+
+	verbosef("Running %s %s", name, strings.Join(args, " "))
+
+	if err := command.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%s failed: %s", name, message)
+		}
+		return nil, fmt.Errorf("%s failed: %s", name, err)
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+)
+
+// targetModel is an entry of the YAML list of the --targets file of
+// list-apps and find-env, like {api: api.prod.example.com, org: payments,
+// space: prod}. api is an endpoint or the name of a saved target, logged in
+// to like the --target-a of diff-env, with token if given. Without space,
+// the apps of all spaces of org are listed, without org those of the space
+// of the saved target, or the targeted space.
+type targetModel struct {
+	API   string `yaml:"api"`
+	Org   string `yaml:"org"`
+	Space string `yaml:"space"`
+	Token string `yaml:"token"`
+}
+
+// targetSpace is a space to list apps in, of one of the --targets.
+type targetSpace struct {
+	foundation foundation
+	org        string
+	space      string
+	spaceGuid  string
+}
+
+func readTargets(path string) ([]targetModel, error) {
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []targetModel
+	if err := yaml.UnmarshalStrict(data, &targets); err != nil {
+		return nil, usageErrorf("Failed to parse %s: %s", path, err)
+	}
+
+	if len(targets) == 0 {
+		return nil, usageErrorf("%s lists no targets", path)
+	}
+
+	for i, target := range targets {
+		if target.API == "" {
+			return nil, usageErrorf("Target %d of %s has no api", i+1, path)
+		}
+
+		if target.Space != "" && target.Org == "" {
+			return nil, usageErrorf("Target %d of %s has a space without org", i+1, path)
+		}
+	}
+
+	return targets, nil
+}
+
+// targetSpaces logs in to the foundations of the --targets file and looks
+// up their spaces, in the order of the file.
+func targetSpaces(cliConnection plugin.CliConnection, path string) []targetSpace {
+
+	targets, err := readTargets(path)
+	fatalIf(err)
+
+	var spaces []targetSpace
+
+	for _, target := range targets {
+		f, err := openFoundation(cliConnection, target.API, target.Token, "a token in "+path)
+		fatalIf(err)
+
+		found, err := f.spaces(cliConnection, target.Org, target.Space)
+		fatalIf(err)
+
+		spaces = append(spaces, found...)
+	}
+
+	return spaces
+}
+
+// spaces looks up the space called spaceName in the org called orgName,
+// or all spaces of the org without spaceName. Without orgName, it is the
+// space of a saved target or the targeted space.
+func (f foundation) spaces(cliConnection plugin.CliConnection, orgName string, spaceName string) ([]targetSpace, error) {
+
+	if orgName == "" {
+		switch {
+		case f.scope.SpaceGuid != "":
+			return []targetSpace{{foundation: f, org: f.org, space: f.space, spaceGuid: f.scope.SpaceGuid}}, nil
+		case f.connection != nil:
+			org, err := cliConnection.GetCurrentOrg()
+			if err != nil {
+				return nil, err
+			}
+
+			space, err := cliConnection.GetCurrentSpace()
+			if err != nil {
+				return nil, err
+			}
+
+			return []targetSpace{{foundation: f, org: org.Name, space: space.Name, spaceGuid: space.Guid}}, nil
+		default:
+			return nil, usageErrorf("No org given for %s, and no space saved for it", f.name)
+		}
+	}
+
+	orgGuid, err := f.client.FindGuid("Organization", orgName, "v2/organizations")
+	if err != nil {
+		return nil, err
+	}
+
+	spacesPath := fmt.Sprintf("v2/organizations/%s/spaces", orgGuid)
+
+	if spaceName != "" {
+		guid, err := f.client.FindGuid("Space", spaceName, spacesPath)
+		if err != nil {
+			return nil, err
+		}
+
+		return []targetSpace{{foundation: f, org: orgName, space: spaceName, spaceGuid: guid}}, nil
+	}
+
+	resources, err := f.client.ListResources(spacesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var spaces []targetSpace
+	for _, resource := range resources {
+		spaces = append(spaces, targetSpace{foundation: f, org: orgName, space: resource.Entity.Name, spaceGuid: resource.Metadata.Guid})
+	}
+
+	return spaces, nil
+}
+
+// listApps lists the apps of the space, with the names of foundation, org
+// and space set.
+func (s targetSpace) listApps(filters ccclient.AppFilters) ([]ccclient.AppModel, error) {
+
+	filters.OrgGuid, filters.SpaceGuid = "", s.spaceGuid
+
+	apps, err := s.foundation.client.ListApps(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	s.describe(apps)
+
+	return apps, nil
+}
+
+// describe sets the names of foundation, org and space of the apps.
+func (s targetSpace) describe(apps []ccclient.AppModel) {
+	for i := range apps {
+		apps[i].Entity.Foundation = s.foundation.name
+		apps[i].Entity.OrgName = s.org
+		apps[i].Entity.SpaceName = s.space
+	}
+}
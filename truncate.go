@@ -0,0 +1,149 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Long values are truncated with an ellipsis in the tables printed to a
+// terminal, so that one certificate doesn't blow out the layout of all
+// other rows. --full prints them whole, and output to files or pipes is
+// never truncated.
+
+// minCellWidth keeps truncated values readable on narrow terminals.
+const minCellWidth = 12
+
+const ellipsis = "…"
+
+// outputWidth returns the width of the terminal out prints to, or 0 if
+// values printed to out aren't truncated.
+func outputWidth(out io.Writer) int {
+
+	if globals.full {
+		return 0
+	}
+
+	file, ok := out.(*os.File)
+	if !ok || !isTerminal(file) {
+		return 0
+	}
+
+	return terminalWidth(file)
+}
+
+// terminalWidth asks stty for the width of the terminal, unless $COLUMNS
+// gives it. Terminals that don't tell are taken to be 80 wide.
+func terminalWidth(file *os.File) int {
+
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+
+	command := exec.Command("stty", "size")
+	command.Stdin = file
+
+	if output, err := command.Output(); err == nil {
+		if fields := strings.Fields(string(output)); len(fields) == 2 {
+			if columns, err := strconv.Atoi(fields[1]); err == nil && columns > 0 {
+				return columns
+			}
+		}
+	}
+
+	return 80
+}
+
+// truncate shortens value to width characters, the last one being an
+// ellipsis. A width of 0 leaves it alone.
+func truncate(value string, width int) string {
+
+	if width <= 0 {
+		return value
+	}
+
+	if width < minCellWidth {
+		width = minCellWidth
+	}
+
+	runes := []rune(value)
+	if len(runes) <= width {
+		return value
+	}
+
+	return string(runes[:width-1]) + ellipsis
+}
+
+// valueLines splits a value into its lines, each truncated to width, for
+// tables to print one below the other.
+func valueLines(value string, width int) []string {
+
+	lines := strings.Split(strings.TrimRight(value, "\r\n"), "\n")
+
+	for i, line := range lines {
+		lines[i] = truncate(strings.TrimSuffix(line, "\r"), width)
+	}
+
+	return lines
+}
+
+// singleLine returns the first line of value, truncated to width, for cells
+// that can't wrap. Further lines are marked with an ellipsis.
+func singleLine(value string, width int) string {
+
+	if width <= 0 {
+		return value
+	}
+
+	lines := valueLines(value, width)
+
+	if len(lines) > 1 && !strings.HasSuffix(lines[0], ellipsis) {
+		return truncate(lines[0]+ellipsis, width)
+	}
+
+	return lines[0]
+}
+
+// columnsWidth returns the width taken by the uncolored columns of rows in
+// a table with padding between columns, to find the width left for the
+// values.
+func columnsWidth(rows [][]string, padding int) int {
+
+	var widths []int
+
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if length := utf8.RuneCountInString(cell); length > widths[i] {
+				widths[i] = length
+			}
+		}
+	}
+
+	total := 0
+	for _, width := range widths {
+		total += width + padding
+	}
+
+	return total
+}
+
+// remainingWidth is what is left of width after used, or 0 if nothing is
+// truncated.
+func remainingWidth(width int, used int) int {
+
+	if width <= 0 {
+		return 0
+	}
+
+	if width-used < minCellWidth {
+		return minCellWidth
+	}
+
+	return width - used
+}
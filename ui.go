@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"io"
+	"os"
+)
+
+// UI is what commands print through, instead of writing to stdout
+// themselves, so that tests can capture the output of a command and
+// --quiet and --error-format are handled in one place. Run hands the UI of
+// the process to Execute, RunWithConnection one writing to its caller.
+type UI interface {
+	// Say prints a line of output, formatted like fmt.Printf.
+	Say(format string, args ...interface{})
+
+	// Warn prints a line to stderr, so that it doesn't mix with output
+	// that is piped on. --quiet silences warnings.
+	Warn(format string, args ...interface{})
+
+	// Failed reports err, as FAILED and its message or as JSON with
+	// --error-format json, and exits with the exit code of err.
+	Failed(err error)
+
+	// Table prints rows aligned in columns below the header. Cells may be
+	// painted, as long as a whole column is.
+	Table(header []string, rows [][]string)
+
+	// Out is the output, for documents like JSON or YAML which are written
+	// by encoders.
+	Out() io.Writer
+
+	// ErrOut is where warnings, errors of scans and verbose logs go.
+	ErrOut() io.Writer
+}
+
+type terminalUI struct {
+	out    io.Writer
+	errOut io.Writer
+}
+
+func (t *terminalUI) Say(format string, args ...interface{}) {
+	fmt.Fprintf(t.out, format+"\n", args...)
+}
+
+func (t *terminalUI) Warn(format string, args ...interface{}) {
+	if !globals.quiet {
+		fmt.Fprintf(t.errOut, format+"\n", args...)
+	}
+}
+
+func (t *terminalUI) Failed(err error) {
+	writeFailure(t.out, err)
+	exit(exitCode(err))
+}
+
+func (t *terminalUI) Table(header []string, rows [][]string) {
+
+	formatters.Human{}.Format(t.out, formatters.Table{Columns: header, Cells: rows})
+}
+
+func (t *terminalUI) Out() io.Writer {
+	return t.out
+}
+
+func (t *terminalUI) ErrOut() io.Writer {
+	return t.errOut
+}
+
+// outputIsTerminal reports whether the output of ui is shown in a terminal,
+// rather than piped on, written to a file or captured.
+func outputIsTerminal(ui UI) bool {
+	file, ok := ui.Out().(*os.File)
+	return ok && isTerminal(file)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"code.cloudfoundry.org/cli/plugin/pluginfakes"
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UI", func() {
+
+	var out, errOut *bytes.Buffer
+	var ui *terminalUI
+
+	BeforeEach(func() {
+		out = new(bytes.Buffer)
+		errOut = new(bytes.Buffer)
+		ui = &terminalUI{out: out, errOut: errOut}
+	})
+
+	AfterEach(func() {
+		globals.quiet = false
+	})
+
+	It("prints tables aligned in columns", func() {
+		ui.Table([]string{"name", "state"}, [][]string{{"app", "STARTED"}, {"longer-app", "STOPPED"}})
+
+		Expect(out.String()).To(Equal("name         state\napp          STARTED\nlonger-app   STOPPED\n"))
+	})
+
+	It("prints warnings to stderr unless quiet", func() {
+		ui.Warn("Skipping '%s'", "app")
+		Expect(errOut.String()).To(Equal("Skipping 'app'\n"))
+		Expect(out.String()).To(BeEmpty())
+
+		globals.quiet = true
+		ui.Warn("Skipping '%s'", "other")
+		Expect(errOut.String()).To(Equal("Skipping 'app'\n"))
+	})
+
+	It("captures the output of a command", func() {
+		sharedClient = nil
+		defer func() { sharedClient = nil }()
+
+		connection := new(pluginfakes.FakeCliConnection)
+		connection.CliCommandWithoutTerminalOutputReturns([]string{`{"FOO":"bar"}`}, nil)
+
+		command := &getEnvGroupCommand{format: formatDotenv, showSecrets: true}
+		command.Execute(context.Background(), ui, connection, []string{"running"})
+
+		Expect(out.String()).To(Equal("FOO=bar\n"))
+		Expect(connection.CliCommandWithoutTerminalOutputArgsForCall(0)).To(Equal([]string{"curl", "/v2/config/environment_variable_groups/running"}))
+	})
+})
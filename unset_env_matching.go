@@ -0,0 +1,132 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"flag"
+	"fmt"
+	"regexp"
+)
+
+type unsetEnvMatchingCommand struct {
+	force    bool
+	keysFrom string
+	restart  *restartOptions
+	history  *historyOptions
+}
+
+func (c *unsetEnvMatchingCommand) Name() string {
+	return "unset-env-matching"
+}
+
+func (c *unsetEnvMatchingCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "unset-env-matching",
+		HelpText: "Remove all environment variables of an app whose name matches a regular expression, or that are listed in a file.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf unset-env-matching APP_NAME (REGEX | --keys-from FILE) [-f] [--dry-run] [--restart | --restage] [--track-history]",
+			Options: map[string]string{
+				"keys-from":     "Remove the variables named in FILE, one per line, instead of those matching REGEX. - reads stdin and needs -f",
+				"restart":       "Restart the app after the update",
+				"restage":       "Restage the app after the update",
+				"f":             "Remove without asking for confirmation (alias --force)",
+				"track-history": "Record the names of the changed variables in the history of the app, see env-history",
+			},
+		},
+	}
+}
+
+func (c *unsetEnvMatchingCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("unset-env-matching")
+	flags.BoolVar(&c.force, "force", false, "")
+	flags.BoolVar(&c.force, "f", false, "")
+	flags.StringVar(&c.keysFrom, "keys-from", "", "")
+	c.restart = addRestartFlags(flags)
+	c.history = addHistoryFlags(flags)
+
+	return flags
+}
+
+func (c *unsetEnvMatchingCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	var matches func(key string) bool
+	var selection string
+
+	if c.keysFrom != "" {
+		if len(args) != 1 {
+			failUsage("--keys-from takes an app name and no pattern")
+		}
+
+		// Confirmations are read from stdin, which holds the keys.
+		if c.keysFrom == "-" && !c.force && !globals.dryRun {
+			failUsage("--keys-from - needs -f, the confirmation can't be read from stdin")
+		}
+
+		keys, err := readList(c.keysFrom)
+		fatalIf(err)
+
+		listed := map[string]bool{}
+		for _, key := range keys {
+			listed[key] = true
+		}
+
+		matches = func(key string) bool { return listed[key] }
+		selection = "listed in " + c.keysFrom
+		if c.keysFrom == "-" {
+			selection = "listed on stdin"
+		}
+	} else {
+		if len(args) != 2 {
+			failUsage("App name and pattern must be provided")
+		}
+
+		pattern, err := regexp.Compile(args[1])
+		fatalIf(err)
+
+		matches = pattern.MatchString
+		selection = fmt.Sprintf("matching '%s'", pattern)
+	}
+
+	appName := args[0]
+
+	app := resolveApp(cliConnection, appName)
+	env := userProvidedEnv(fetchEnvByGuid(cliConnection, appName, app.Guid))
+
+	var matching []string
+	remaining := map[string]interface{}{}
+
+	for _, key := range sortedKeys(env) {
+		if matches(key) {
+			matching = append(matching, key)
+		} else {
+			remaining[key] = env[key]
+		}
+	}
+
+	if len(matching) == 0 {
+		ui.Say("No variables of '%s' %s", appName, selection)
+		return
+	}
+
+	ui.Say("Variables of '%s' %s:", appName, selection)
+	for _, key := range matching {
+		ui.Say("  %s", key)
+	}
+
+	if !c.force && !globals.dryRun && !confirm(ui, fmt.Sprintf("Really remove %d variables?", len(matching))) {
+		ui.Say("Nothing removed")
+		return
+	}
+
+	change := envChangeSet{appName: appName, guid: app.Guid, before: env, after: remaining, force: c.force}
+	if !change.apply(ui, cliConnection) {
+		return
+	}
+
+	c.history.record(ui, cliConnection, appName, app.Guid, "unset-env-matching", env, remaining)
+
+	ui.Say("Removed %d variables from '%s'", len(matching), appName)
+
+	c.restart.apply(ui, cliConnection, appName, app.Guid)
+}
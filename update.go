@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"code.cloudfoundry.org/cli/plugin"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// latestReleaseURL is the GitHub API of the release `cf get-env-plugin-update`
+// compares the installed version to.
+const latestReleaseURL = "https://api.github.com/repos/thomaseizinger/cf-get-env-plugin/releases/latest"
+
+// changelogLines bounds the release notes printed of a newer release. The
+// full notes are linked.
+const changelogLines = 10
+
+type releaseModel struct {
+	TagName string         `json:"tag_name"`
+	Body    string         `json:"body"`
+	HtmlUrl string         `json:"html_url"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	Name string `json:"name"`
+	Url  string `json:"browser_download_url"`
+}
+
+type pluginUpdateCommand struct {
+	install    bool
+	force      bool
+	releaseURL string
+}
+
+func (c *pluginUpdateCommand) Name() string {
+	return "get-env-plugin-update"
+}
+
+func (c *pluginUpdateCommand) Usage() plugin.Command {
+	return plugin.Command{
+		Name:     "get-env-plugin-update",
+		HelpText: "Check GitHub for a newer release of this plugin and print the start of its release notes. With --install, download the binary of this platform, verify its checksum and install it with `cf install-plugin`.",
+		UsageDetails: plugin.Usage{
+			Usage: "cf get-env-plugin-update [--install [-f]] [--release-url URL]",
+			Options: map[string]string{
+				"install":     "Download and install the newer release after verifying its SHA-256 against the published checksums",
+				"f":           "Install without asking for confirmation. --force is an alias",
+				"release-url": "GitHub API URL of the release to compare to, e.g. of a mirror (default the latest release on github.com)",
+			},
+		},
+	}
+}
+
+func (c *pluginUpdateCommand) Flags() *flag.FlagSet {
+
+	flags := newFlagSet("get-env-plugin-update")
+	flags.BoolVar(&c.install, "install", false, "")
+	flags.BoolVar(&c.force, "f", false, "")
+	flags.BoolVar(&c.force, "force", false, "")
+	flags.StringVar(&c.releaseURL, "release-url", latestReleaseURL, "")
+
+	return flags
+}
+
+func (c *pluginUpdateCommand) Execute(ctx context.Context, ui UI, cliConnection plugin.CliConnection, args []string) {
+
+	if len(args) > 0 {
+		failUsage("get-env-plugin-update takes no arguments")
+	}
+
+	client := &http.Client{Timeout: globals.timeout}
+
+	var release releaseModel
+	fatalIf(getJson(client, c.releaseURL, &release))
+
+	if !newerVersion(release.TagName, version) {
+		ui.Say("get-env %s is up to date, the latest release is %s", version, release.TagName)
+		return
+	}
+
+	ui.Say("get-env %s is available, %s is installed:\n", release.TagName, version)
+	writeChangelog(ui.Out(), release)
+
+	if !c.install {
+		ui.Say("\nInstall it with `cf get-env-plugin-update --install`")
+		return
+	}
+
+	binary, ok := release.asset(runtime.GOOS, runtime.GOARCH)
+	if !ok {
+		fatalIf(fmt.Errorf("Release %s has no binary for %s/%s", release.TagName, runtime.GOOS, runtime.GOARCH))
+	}
+
+	checksums, ok := release.checksums()
+	if !ok {
+		fatalIf(fmt.Errorf("Release %s publishes no checksums, not installing %s", release.TagName, binary.Name))
+	}
+
+	if globals.dryRun {
+		ui.Say("\nWould download %s, verify it against %s and install it with `cf install-plugin`", binary.Name, checksums.Name)
+		return
+	}
+
+	if !c.force && !confirm(ui, fmt.Sprintf("\nInstall get-env %s?", release.TagName)) {
+		ui.Say("Nothing installed")
+		return
+	}
+
+	sums, err := readChecksums(client, checksums.Url)
+	fatalIf(err)
+
+	expected, ok := sums[binary.Name]
+	if !ok {
+		fatalIf(fmt.Errorf("%s has no checksum of %s", checksums.Name, binary.Name))
+	}
+
+	path, err := downloadVerified(client, binary, expected)
+	fatalIf(err)
+	defer os.Remove(path)
+
+	_, err = cliConnection.CliCommand("install-plugin", path, "-f")
+	fatalIf(err)
+}
+
+// newerVersion reports whether the release tag is newer than the semantic
+// version current. Development builds are older than any release.
+func newerVersion(tag string, current string) bool {
+
+	latest, installed := pluginVersion(tag), pluginVersion(current)
+
+	if latest.Major != installed.Major {
+		return latest.Major > installed.Major
+	}
+	if latest.Minor != installed.Minor {
+		return latest.Minor > installed.Minor
+	}
+	return latest.Build > installed.Build
+}
+
+// writeChangelog prints the start of the release notes and the link to the
+// release.
+func writeChangelog(out io.Writer, release releaseModel) {
+
+	lines := strings.Split(strings.TrimSpace(strings.Replace(release.Body, "\r\n", "\n", -1)), "\n")
+
+	if len(lines) > changelogLines {
+		lines = append(lines[:changelogLines], "…")
+	}
+
+	for _, line := range lines {
+		fmt.Fprintf(out, "  %s\n", line)
+	}
+
+	if release.HtmlUrl != "" {
+		fmt.Fprintf(out, "\n%s\n", release.HtmlUrl)
+	}
+}
+
+// asset finds the binary of the platform, which is named after it, like
+// get-env-plugin-linux-amd64 or get-env-plugin-windows-amd64.exe.
+func (r releaseModel) asset(goos string, goarch string) (releaseAsset, bool) {
+
+	for _, asset := range r.Assets {
+		name := strings.ToLower(asset.Name)
+		if strings.Contains(name, goos) && strings.Contains(name, goarch) && !strings.HasSuffix(name, ".sha256") {
+			return asset, true
+		}
+	}
+
+	return releaseAsset{}, false
+}
+
+func (r releaseModel) checksums() (releaseAsset, bool) {
+
+	for _, asset := range r.Assets {
+		if strings.Contains(strings.ToLower(asset.Name), "checksums") {
+			return asset, true
+		}
+	}
+
+	return releaseAsset{}, false
+}
+
+// readChecksums reads a checksums file of `sha256sum` lines, like
+// "<hex sum>  get-env-plugin-linux-amd64", by asset name.
+func readChecksums(client *http.Client, url string) (map[string]string, error) {
+
+	body, err := download(client, url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	sums := map[string]string{}
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 {
+			sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+		}
+	}
+
+	return sums, scanner.Err()
+}
+
+// downloadVerified downloads the binary to a temporary file and returns its
+// path if its SHA-256 is the expected one.
+func downloadVerified(client *http.Client, binary releaseAsset, expected string) (string, error) {
+
+	body, err := download(client, binary.Url)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	file, err := ioutil.TempFile("", "get-env-plugin-")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+
+	if _, err := io.Copy(io.MultiWriter(file, hash), body); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("Checksum of %s is %s, expected %s, not installing it", binary.Name, actual, expected)
+	}
+
+	if err := file.Chmod(0700); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), nil
+}
+
+func download(client *http.Client, url string) (io.ReadCloser, error) {
+
+	verbosef("Downloading %s", url)
+
+	response, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("Failed to download %s: status %d", url, response.StatusCode)
+	}
+
+	return response.Body, nil
+}
+
+func getJson(client *http.Client, url string, value interface{}) error {
+
+	body, err := download(client, url)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return json.NewDecoder(body).Decode(value)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/formatters"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+// urlValue matches values shaped like URLs or connection strings, like
+// postgres://user:password@db:5432/app or jdbc:mysql://db/app.
+var urlValue = regexp.MustCompile(`^(jdbc:)?[a-zA-Z][a-zA-Z0-9+.-]*://\S+$`)
+
+// defaultPorts are shown for URLs without a port, so that the summary
+// says where the app connects to.
+var defaultPorts = map[string]string{
+	"http":       "80",
+	"https":      "443",
+	"postgres":   "5432",
+	"postgresql": "5432",
+	"mysql":      "3306",
+	"redis":      "6379",
+	"rediss":     "6379",
+	"mongodb":    "27017",
+	"amqp":       "5672",
+	"amqps":      "5671",
+	"nats":       "4222",
+}
+
+// urlSummary is a URL of an env without its credentials.
+type urlSummary struct {
+	Key      string `json:"key"`
+	Scheme   string `json:"scheme"`
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	Database string `json:"database"`
+}
+
+// urlsSchema is the schema of the JSON output of get-env --summarize-urls.
+var urlsSchema = formatters.Schema{Name: "urls", Version: 1, Results: []urlSummary{}}
+
+// summarizeURL parses value, and returns false unless it is a URL with a
+// host. User, password and query, which may hold credentials too, are
+// left out.
+func summarizeURL(key string, value string) (urlSummary, bool) {
+
+	if !urlValue.MatchString(value) {
+		return urlSummary{}, false
+	}
+
+	parsed, err := url.Parse(strings.TrimPrefix(value, "jdbc:"))
+	if err != nil || parsed.Host == "" {
+		return urlSummary{}, false
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+
+	port := parsed.Port()
+	if port == "" {
+		port = defaultPorts[scheme]
+	}
+
+	summary := urlSummary{Key: key, Scheme: scheme, Host: parsed.Hostname(), Port: port}
+
+	// The path of a web URL is no database.
+	if scheme != "http" && scheme != "https" {
+		summary.Database = strings.Trim(parsed.Path, "/")
+	}
+
+	return summary, true
+}
+
+// envURLs finds the URLs in the variables, also in JSON values like the
+// credentials of VCAP_SERVICES, see envCertificates.
+func envURLs(vars map[string]interface{}) []urlSummary {
+
+	var found []urlSummary
+
+	for _, key := range sortedKeys(vars) {
+		walkStrings(key, vars[key], func(path string, value string) {
+			if summary, ok := summarizeURL(path, value); ok {
+				found = append(found, summary)
+			}
+		})
+	}
+
+	return found
+}
+
+// writeURLSummary prints the URLs of `cf get-env APP --summarize-urls`.
+func (p *GetEnvPlugin) writeURLSummary(env map[string]interface{}) {
+
+	urls := envURLs(p.selectedVars(env))
+
+	out, done := p.openOut()
+	defer done()
+
+	printResult(out, reportFormatter(p.format), envURLSummary{app: p.appName, urls: urls})
+}
+
+// envURLSummary is the result of get-env --summarize-urls, the URLs in the
+// env of app.
+type envURLSummary struct {
+	app  string
+	urls []urlSummary
+}
+
+func (s envURLSummary) Items() interface{} {
+	return s.urls
+}
+
+func (s envURLSummary) Schema() formatters.Schema {
+	return urlsSchema
+}
+
+func (s envURLSummary) Print(out io.Writer) error {
+
+	if len(s.urls) == 0 {
+		_, err := fmt.Fprintf(out, "No URLs found in the env of %s\n", s.app)
+		return err
+	}
+
+	table := tabwriter.NewWriter(out, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(table, "key\tscheme\thost\tport\tdatabase")
+	for _, summary := range s.urls {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", summary.Key, summary.Scheme, summary.Host, summary.Port, summary.Database)
+	}
+
+	return table.Flush()
+}
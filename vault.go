@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/thomaseizinger/cf-get-env-plugin/ccclient"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// vaultClient talks to the KV version 2 secrets engine of HashiCorp Vault,
+// configured like the vault CLI with VAULT_ADDR, VAULT_TOKEN and optionally
+// VAULT_NAMESPACE.
+type vaultClient struct {
+	addr      string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// newVaultClient connects to the Vault at --endpoint, if given, instead of
+// VAULT_ADDR.
+func newVaultClient(options storeOptions) (*vaultClient, error) {
+
+	addr := options.endpoint
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	addr = strings.TrimSuffix(addr, "/")
+	token := os.Getenv("VAULT_TOKEN")
+
+	if addr == "" || token == "" {
+		return nil, errors.New("VAULT_ADDR and VAULT_TOKEN must be set")
+	}
+
+	return &vaultClient{
+		addr:      addr,
+		token:     token,
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		client:    &http.Client{Timeout: globals.timeout},
+	}, nil
+}
+
+// vaultDataPath turns a path like secret/cf/my-app into the API path of its
+// data, assuming the KV engine is mounted at the first segment.
+func vaultDataPath(path string) (string, error) {
+
+	segments := strings.SplitN(strings.Trim(path, "/"), "/", 2)
+
+	if len(segments) < 2 || segments[1] == "" {
+		return "", usageErrorf("Vault path '%s' must start with the mount of the KV engine, e.g. secret/cf/my-app", path)
+	}
+
+	return "/v1/" + segments[0] + "/data/" + segments[1], nil
+}
+
+func (v *vaultClient) do(method string, path string, body interface{}) (int, []byte, error) {
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequest(method, v.addr+path, reader)
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	request.Header.Set("X-Vault-Token", v.token)
+	request.Header.Set("Content-Type", "application/json")
+	if v.namespace != "" {
+		request.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	verbosef("%s %s", method, v.addr+path)
+
+	response, err := v.client.Do(request)
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer response.Body.Close()
+
+	data, err := ioutil.ReadAll(response.Body)
+
+	if err != nil {
+		return response.StatusCode, nil, err
+	}
+
+	if response.StatusCode >= 400 {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		json.Unmarshal(data, &vaultErr)
+
+		if len(vaultErr.Errors) > 0 {
+			return response.StatusCode, nil, fmt.Errorf("Vault responded with status %d: %s", response.StatusCode, strings.Join(vaultErr.Errors, ", "))
+		}
+		return response.StatusCode, nil, fmt.Errorf("Vault responded with status %d", response.StatusCode)
+	}
+
+	return response.StatusCode, data, nil
+}
+
+// writeVars stores vars as the new version of the secret at path.
+func (v *vaultClient) writeVars(path string, vars map[string]string) error {
+
+	apiPath, err := vaultDataPath(path)
+
+	if err != nil {
+		return err
+	}
+
+	_, _, err = v.do("POST", apiPath, map[string]interface{}{"data": vars})
+	return err
+}
+
+// readVars returns the variables of the latest version of the secret at
+// path.
+func (v *vaultClient) readVars(path string) (map[string]string, error) {
+
+	apiPath, err := vaultDataPath(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	status, data, err := v.do("GET", apiPath, nil)
+
+	if status == http.StatusNotFound {
+		return nil, &ccclient.NotFoundError{Kind: "Secret", Name: path}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(data, &secret); err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]string, len(secret.Data.Data))
+	for key, value := range secret.Data.Data {
+		vars[key] = formatEnvValue(value)
+	}
+
+	return vars, nil
+}
+
+// writeValue stores value under the key "value" of the secret at path, as
+// Vault secrets are always objects.
+func (v *vaultClient) writeValue(path string, value string) error {
+	return v.writeVars(path, map[string]string{"value": value})
+}
+
+func (v *vaultClient) keyName(path string, key string) string {
+	return strings.TrimSuffix(path, "/") + "/" + key
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// version and commit are set at build time, e.g. with
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse --short HEAD)"
+//
+// Builds without them, like those of `go get`, report a development version.
+var (
+	version = "0.0.0-dev"
+	commit  = "unknown"
+)
+
+// minCliVersion is the CLI that added the plugin RPC calls for models like
+// GetApp and for the access token.
+var minCliVersion = plugin.VersionType{Major: 6, Minor: 14, Build: 0}
+
+// pluginVersion parses a semantic version like 1.4.0, v1.4.0 or 1.4.0-rc.1 for
+// `cf plugins`, which only shows major, minor and patch. Missing or invalid
+// parts are 0.
+func pluginVersion(semver string) plugin.VersionType {
+
+	core := strings.SplitN(strings.TrimPrefix(semver, "v"), "-", 2)[0]
+	core = strings.SplitN(core, "+", 2)[0]
+
+	var parts [3]int
+	for i, part := range strings.SplitN(core, ".", 3) {
+		parts[i], _ = strconv.Atoi(part)
+	}
+
+	return plugin.VersionType{Major: parts[0], Minor: parts[1], Build: parts[2]}
+}
+
+// writeVersion prints `cf get-env --version`, with the full version the
+// metadata can't hold.
+func writeVersion(out io.Writer) {
+	fmt.Fprintf(out, "get-env %s (commit %s)\n", version, commit)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"code.cloudfoundry.org/cli/plugin"
+	"fmt"
+	"time"
+)
+
+// watchEnv polls the user-provided environment of the app every interval and
+// prints the variables that were added, removed or changed since the last
+// poll. It runs until interrupted.
+func (p *GetEnvPlugin) watchEnv(cliConnection plugin.CliConnection, guid string) {
+
+	previous := userProvidedEnv(fetchEnvByGuid(cliConnection, p.appName, guid))
+	hide := p.shouldRedact()
+
+	fmt.Fprintf(p.ui.Out(), "%s watching %d variables of '%s' every %s\n", timestamp(), len(previous), p.appName, p.interval)
+
+	display := func(env map[string]interface{}, key string) string {
+		if hide {
+			return mask(formatEnvValue(env[key]))
+		}
+		return formatEnvValue(env[key])
+	}
+
+	for {
+		time.Sleep(p.interval)
+
+		current := userProvidedEnv(fetchEnvByGuid(cliConnection, p.appName, guid))
+		diff := diffEnv(previous, current)
+		now := timestamp()
+
+		for _, key := range diff.OnlyInB {
+			fmt.Fprintf(p.ui.Out(), "%s + %s = %s\n", now, key, display(current, key))
+		}
+		for _, key := range diff.OnlyInA {
+			fmt.Fprintf(p.ui.Out(), "%s - %s\n", now, key)
+		}
+		for _, key := range diff.Changed {
+			fmt.Fprintf(p.ui.Out(), "%s ~ %s = %s (was %s)\n", now, paint(colorYellow, key), display(current, key), display(previous, key))
+		}
+
+		// A webhook that is down shouldn't end the watch.
+		if !diff.empty() {
+			event := notification{Event: "change", App: p.appName, Added: diff.OnlyInB, Removed: diff.OnlyInA, Changed: diff.Changed}
+			if err := p.notify.notify(event); err != nil {
+				fmt.Fprintf(p.ui.ErrOut(), "%s %s\n", now, err)
+			}
+		}
+
+		previous = current
+	}
+}
+
+func timestamp() string {
+	return time.Now().Format(time.RFC3339)
+}